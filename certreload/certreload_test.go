@@ -0,0 +1,142 @@
+package certreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for commonName and its key.
+func writeCert(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "first")
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := commonName(t, r); got != "first" {
+		t.Errorf("Expected certificate first, got %q", got)
+	}
+
+	writeCert(t, certFile, keyFile, "second")
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := commonName(t, r); got != "second" {
+		t.Errorf("Expected certificate second after Reload, got %q", got)
+	}
+
+	// A half-written renewal keeps the previous certificate.
+	os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+	if got := commonName(t, r); got != "second" {
+		t.Errorf("Expected certificate second after a failed reload, got %q", got)
+	}
+}
+
+func TestNewMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Error("Expected an error for missing files")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "first")
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	writeCert(t, certFile, keyFile, "renewed")
+	// Coarse file system timestamps may not change within the test.
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for commonName(t, r) != "renewed" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected Watch to reload the renewed certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	writeCert(t, certFile, keyFile, "ca")
+
+	if _, err := LoadCertPool(certFile); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, err := LoadCertPool(keyFile); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+	if _, err := LoadCertPool(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,317 @@
+package coaphandler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type is the CoAP message type (RFC 7252, section 3).
+type Type uint8
+
+const (
+	Confirmable     Type = 0
+	NonConfirmable  Type = 1
+	Acknowledgement Type = 2
+	Reset           Type = 3
+)
+
+// Code is a CoAP request method or response code, encoded as class.detail.
+type Code uint8
+
+// Request methods.
+const (
+	Empty  Code = 0
+	GET    Code = 1
+	POST   Code = 2
+	PUT    Code = 3
+	DELETE Code = 4
+)
+
+// Response codes.
+const (
+	Created                  Code = 2<<5 | 1
+	Deleted                  Code = 2<<5 | 2
+	Changed                  Code = 2<<5 | 4
+	Content                  Code = 2<<5 | 5
+	BadRequest               Code = 4<<5 | 0
+	BadOption                Code = 4<<5 | 2
+	NotFound                 Code = 4<<5 | 4
+	MethodNotAllowed         Code = 4<<5 | 5
+	RequestEntityTooLarge    Code = 4<<5 | 13
+	UnsupportedContentFormat Code = 4<<5 | 15
+	InternalServerError      Code = 5<<5 | 0
+)
+
+// String returns the code in the usual dotted notation, e.g. "2.05".
+func (c Code) String() string {
+	return fmt.Sprintf("%d.%02d", c>>5, c&0x1f)
+}
+
+// IsSuccess reports whether c is a 2.xx response code.
+func (c Code) IsSuccess() bool {
+	return c>>5 == 2
+}
+
+// OptionID is the number of a CoAP option.
+type OptionID uint16
+
+const (
+	OptionIfMatch       OptionID = 1
+	OptionURIHost       OptionID = 3
+	OptionIfNoneMatch   OptionID = 5
+	OptionObserve       OptionID = 6
+	OptionURIPort       OptionID = 7
+	OptionURIPath       OptionID = 11
+	OptionContentFormat OptionID = 12
+	OptionMaxAge        OptionID = 14
+	OptionURIQuery      OptionID = 15
+	OptionAccept        OptionID = 17
+)
+
+// critical reports whether an unrecognized option with this number must
+// cause the message to be rejected.
+func (id OptionID) critical() bool {
+	return id&1 == 1
+}
+
+// Content formats from the CoAP Content-Formats registry.
+const (
+	ContentFormatText = 0
+	ContentFormatJSON = 50
+	ContentFormatCBOR = 60
+)
+
+// Option is a single CoAP option. Repeated options, like Uri-Path, appear
+// once per value.
+type Option struct {
+	ID    OptionID
+	Value []byte
+}
+
+// Message is a CoAP message as sent over UDP.
+type Message struct {
+	Type      Type
+	Code      Code
+	MessageID uint16
+	Token     []byte
+	Options   []Option
+	Payload   []byte
+}
+
+const (
+	coapVersion   = 1
+	payloadMarker = 0xff
+	maxTokenLen   = 8
+)
+
+var errMessageFormat = errors.New("malformed CoAP message")
+
+// Marshal encodes m in the CoAP wire format. Options are written in
+// ascending order of their number; repeated options keep their order.
+func (m Message) Marshal() ([]byte, error) {
+	if len(m.Token) > maxTokenLen {
+		return nil, fmt.Errorf("token of %d bytes exceeds the maximum of %d", len(m.Token), maxTokenLen)
+	}
+
+	b := make([]byte, 4, 4+len(m.Token)+len(m.Payload)+16)
+	b[0] = coapVersion<<6 | byte(m.Type)<<4 | byte(len(m.Token))
+	b[1] = byte(m.Code)
+	binary.BigEndian.PutUint16(b[2:], m.MessageID)
+	b = append(b, m.Token...)
+
+	options := make([]Option, len(m.Options))
+	copy(options, m.Options)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].ID < options[j].ID })
+
+	var previous OptionID
+	for _, opt := range options {
+		delta := int(opt.ID - previous)
+		previous = opt.ID
+		deltaNibble, deltaExt := optionNibble(delta)
+		lengthNibble, lengthExt := optionNibble(len(opt.Value))
+		b = append(b, deltaNibble<<4|lengthNibble)
+		b = append(b, deltaExt...)
+		b = append(b, lengthExt...)
+		b = append(b, opt.Value...)
+	}
+
+	if len(m.Payload) > 0 {
+		b = append(b, payloadMarker)
+		b = append(b, m.Payload...)
+	}
+	return b, nil
+}
+
+// optionNibble returns the 4-bit header value and the extended bytes used to
+// encode an option delta or length.
+func optionNibble(v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), nil
+	case v < 269:
+		return 13, []byte{byte(v - 13)}
+	default:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(v-269))
+		return 14, ext
+	}
+}
+
+// Unmarshal decodes a CoAP message from its wire format.
+func Unmarshal(b []byte) (Message, error) {
+	if len(b) < 4 {
+		return Message{}, fmt.Errorf("%w: %d bytes are too short for a header", errMessageFormat, len(b))
+	}
+	if version := b[0] >> 6; version != coapVersion {
+		return Message{}, fmt.Errorf("%w: unsupported version %d", errMessageFormat, version)
+	}
+	tokenLen := int(b[0] & 0x0f)
+	if tokenLen > maxTokenLen {
+		return Message{}, fmt.Errorf("%w: invalid token length %d", errMessageFormat, tokenLen)
+	}
+
+	m := Message{
+		Type:      Type(b[0] >> 4 & 0x03),
+		Code:      Code(b[1]),
+		MessageID: binary.BigEndian.Uint16(b[2:4]),
+	}
+	b = b[4:]
+	if len(b) < tokenLen {
+		return Message{}, fmt.Errorf("%w: truncated token", errMessageFormat)
+	}
+	if tokenLen > 0 {
+		m.Token = append([]byte(nil), b[:tokenLen]...)
+	}
+	b = b[tokenLen:]
+
+	var id OptionID
+	for len(b) > 0 {
+		if b[0] == payloadMarker {
+			if len(b) == 1 {
+				return Message{}, fmt.Errorf("%w: payload marker without payload", errMessageFormat)
+			}
+			m.Payload = append([]byte(nil), b[1:]...)
+			break
+		}
+
+		header := b[0]
+		b = b[1:]
+		var delta, length int
+		var err error
+		if delta, b, err = readOptionNibble(header>>4, b); err != nil {
+			return Message{}, err
+		}
+		if length, b, err = readOptionNibble(header&0x0f, b); err != nil {
+			return Message{}, err
+		}
+		if len(b) < length {
+			return Message{}, fmt.Errorf("%w: truncated option value", errMessageFormat)
+		}
+		id += OptionID(delta)
+		m.Options = append(m.Options, Option{ID: id, Value: append([]byte(nil), b[:length]...)})
+		b = b[length:]
+	}
+	return m, nil
+}
+
+// readOptionNibble decodes an option delta or length whose 4-bit header
+// value is nibble, consuming extended bytes from b.
+func readOptionNibble(nibble byte, b []byte) (int, []byte, error) {
+	switch nibble {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, fmt.Errorf("%w: truncated option header", errMessageFormat)
+		}
+		return int(b[0]) + 13, b[1:], nil
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, fmt.Errorf("%w: truncated option header", errMessageFormat)
+		}
+		return int(binary.BigEndian.Uint16(b)) + 269, b[2:], nil
+	case 15:
+		return 0, nil, fmt.Errorf("%w: reserved option nibble", errMessageFormat)
+	default:
+		return int(nibble), b, nil
+	}
+}
+
+// Option returns the value of the first option with the given number.
+func (m Message) Option(id OptionID) ([]byte, bool) {
+	for _, opt := range m.Options {
+		if opt.ID == id {
+			return opt.Value, true
+		}
+	}
+	return nil, false
+}
+
+// UintOption returns the first option with the given number decoded as an
+// unsigned integer.
+func (m Message) UintOption(id OptionID) (uint32, bool) {
+	value, ok := m.Option(id)
+	if !ok || len(value) > 4 {
+		return 0, false
+	}
+	var v uint32
+	for _, b := range value {
+		v = v<<8 | uint32(b)
+	}
+	return v, true
+}
+
+// StringOptions returns the values of all options with the given number.
+func (m Message) StringOptions(id OptionID) []string {
+	var values []string
+	for _, opt := range m.Options {
+		if opt.ID == id {
+			values = append(values, string(opt.Value))
+		}
+	}
+	return values
+}
+
+// Path returns the Uri-Path options joined with slashes, without a leading
+// slash.
+func (m Message) Path() string {
+	return strings.Join(m.StringOptions(OptionURIPath), "/")
+}
+
+// SetPath replaces the Uri-Path options with the segments of path.
+func (m *Message) SetPath(path string) {
+	m.removeOption(OptionURIPath)
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment != "" {
+			m.AddOption(OptionURIPath, []byte(segment))
+		}
+	}
+}
+
+// AddOption appends an option.
+func (m *Message) AddOption(id OptionID, value []byte) {
+	m.Options = append(m.Options, Option{ID: id, Value: value})
+}
+
+// SetUintOption replaces all options with the given number by a single
+// option holding v in the shortest unsigned integer encoding.
+func (m *Message) SetUintOption(id OptionID, v uint32) {
+	m.removeOption(id)
+	var value []byte
+	for v > 0 {
+		value = append([]byte{byte(v)}, value...)
+		v >>= 8
+	}
+	m.AddOption(id, value)
+}
+
+func (m *Message) removeOption(id OptionID) {
+	options := m.Options[:0]
+	for _, opt := range m.Options {
+		if opt.ID != id {
+			options = append(options, opt)
+		}
+	}
+	m.Options = options
+}
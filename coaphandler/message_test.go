@@ -0,0 +1,153 @@
+package coaphandler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{
+			name: "empty",
+			msg:  Message{Type: Confirmable, Code: Empty, MessageID: 1},
+		},
+		{
+			name: "request with path and query",
+			msg: Message{
+				Type:      Confirmable,
+				Code:      POST,
+				MessageID: 0xbeef,
+				Token:     []byte{1, 2, 3, 4},
+				Options: []Option{
+					{ID: OptionURIPath, Value: []byte("patch")},
+					{ID: OptionURIPath, Value: []byte("key")},
+					{ID: OptionContentFormat, Value: []byte{ContentFormatJSON}},
+					{ID: OptionURIQuery, Value: []byte("temp=21")},
+				},
+				Payload: []byte(`{"temp":21}`),
+			},
+		},
+		{
+			name: "extended option delta and length",
+			msg: Message{
+				Type:      NonConfirmable,
+				Code:      Content,
+				MessageID: 7,
+				Token:     []byte("12345678"),
+				Options: []Option{
+					{ID: OptionURIPath, Value: []byte(strings.Repeat("a", 20))},
+					{ID: OptionURIPath, Value: []byte(strings.Repeat("b", 300))},
+					{ID: 2048, Value: []byte{1}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.msg.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			got, err := Unmarshal(b)
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if got.Type != tt.msg.Type || got.Code != tt.msg.Code || got.MessageID != tt.msg.MessageID {
+				t.Errorf("header = %v/%v/%v, want %v/%v/%v", got.Type, got.Code, got.MessageID, tt.msg.Type, tt.msg.Code, tt.msg.MessageID)
+			}
+			if !bytes.Equal(got.Token, tt.msg.Token) {
+				t.Errorf("token = %x, want %x", got.Token, tt.msg.Token)
+			}
+			if !bytes.Equal(got.Payload, tt.msg.Payload) {
+				t.Errorf("payload = %q, want %q", got.Payload, tt.msg.Payload)
+			}
+			for _, id := range []OptionID{OptionURIPath, OptionURIQuery, 2048} {
+				if !reflect.DeepEqual(got.StringOptions(id), tt.msg.StringOptions(id)) {
+					t.Errorf("options %d = %q, want %q", id, got.StringOptions(id), tt.msg.StringOptions(id))
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalWireFormat(t *testing.T) {
+	msg := Message{Type: Confirmable, Code: GET, MessageID: 0x1234, Token: []byte{0xab}}
+	msg.SetPath("/d/key/json")
+
+	b, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := []byte{
+		0x41, 0x01, 0x12, 0x34, 0xab,
+		0xb1, 'd',
+		0x03, 'k', 'e', 'y',
+		0x04, 'j', 's', 'o', 'n',
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal = % x, want % x", b, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"too short", []byte{0x40, 0x01}},
+		{"wrong version", []byte{0x80, 0x01, 0x00, 0x01}},
+		{"token length", []byte{0x49, 0x01, 0x00, 0x01}},
+		{"truncated token", []byte{0x42, 0x01, 0x00, 0x01, 0xff}},
+		{"payload marker without payload", []byte{0x40, 0x01, 0x00, 0x01, 0xff}},
+		{"truncated option", []byte{0x40, 0x01, 0x00, 0x01, 0xb5, 'a'}},
+		{"reserved nibble", []byte{0x40, 0x01, 0x00, 0x01, 0xf1, 'a'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal(tt.packet); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestUintOption(t *testing.T) {
+	tests := []struct {
+		value uint32
+		len   int
+	}{
+		{0, 0},
+		{1, 1},
+		{255, 1},
+		{256, 2},
+		{1<<24 - 1, 3},
+	}
+
+	for _, tt := range tests {
+		var msg Message
+		msg.SetUintOption(OptionObserve, tt.value)
+		raw, _ := msg.Option(OptionObserve)
+		if len(raw) != tt.len {
+			t.Errorf("SetUintOption(%d) encoded %d bytes, want %d", tt.value, len(raw), tt.len)
+		}
+		if got, ok := msg.UintOption(OptionObserve); !ok || got != tt.value {
+			t.Errorf("UintOption = %d, %v, want %d", got, ok, tt.value)
+		}
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	if got := Content.String(); got != "2.05" {
+		t.Errorf("Content.String() = %q, want 2.05", got)
+	}
+	if got := NotFound.String(); got != "4.04" {
+		t.Errorf("NotFound.String() = %q, want 4.04", got)
+	}
+}
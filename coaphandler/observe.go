@@ -0,0 +1,243 @@
+package coaphandler
+
+import (
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// Values of the Observe option in a GET request (RFC 7641, section 2).
+const (
+	observeRegister   = 0
+	observeDeregister = 1
+)
+
+// maxObserveSequence is the largest value of the 24-bit Observe option.
+const maxObserveSequence = 1<<24 - 1
+
+// observer is an Observe registration of one client for one resource.
+type observer struct {
+	addr        net.Addr
+	token       []byte
+	downloadKey string
+	render      func() Message
+
+	// The following fields are guarded by Server.mu. lastConfirmed is zero
+	// until the client acknowledged a confirmable notification.
+	seq           uint32
+	messageID     uint16
+	lastConfirmed time.Time
+	acked         chan struct{}
+
+	stopOnce    sync.Once
+	done        chan struct{}
+	unsubscribe func()
+}
+
+func (o *observer) stop() {
+	o.stopOnce.Do(func() {
+		close(o.done)
+		o.unsubscribe()
+	})
+}
+
+func observerKey(addr net.Addr, token []byte) string {
+	return addr.String() + "#" + hex.EncodeToString(token)
+}
+
+// observe answers a GET request for a resource of downloadKey and handles
+// the Observe option: registrations receive a notification rendered by
+// render after every change of the stored data. The source address of a
+// registration is not trusted until the client acknowledged a confirmable
+// notification, so the first notification is always confirmable and an
+// unanswered one ends the registration.
+func (s *Server) observe(addr net.Addr, req Message, downloadKey string, render func() Message) Message {
+	resp := render()
+
+	value, ok := req.UintOption(OptionObserve)
+	if !ok {
+		return resp
+	}
+	key := observerKey(addr, req.Token)
+	switch value {
+	case observeRegister:
+		if !resp.Code.IsSuccess() {
+			s.cancelObserver(key)
+			return resp
+		}
+		if seq, ok := s.registerObserver(key, addr, req.Token, downloadKey, render); ok {
+			resp.SetUintOption(OptionObserve, seq)
+		}
+	case observeDeregister:
+		s.cancelObserver(key)
+	}
+	return resp
+}
+
+// registerObserver adds or replaces the registration identified by key and
+// returns the sequence number for the initial response. It reports false if
+// the server or downloadKey has no room for another registration.
+func (s *Server) registerObserver(key string, addr net.Addr, token []byte, downloadKey string, render func() Message) (uint32, bool) {
+	s.mu.Lock()
+	previous := s.observers[key]
+	delete(s.observers, key)
+	if s.closed || len(s.observers) >= MaxObservers || s.countObserversLocked(downloadKey) >= MaxObserversPerKey {
+		s.mu.Unlock()
+		if previous != nil {
+			previous.stop()
+		}
+		return 0, false
+	}
+
+	changes, unsubscribe := s.DataService.Subscribe(downloadKey)
+	o := &observer{
+		addr:        addr,
+		token:       append([]byte(nil), token...),
+		downloadKey: downloadKey,
+		render:      render,
+		seq:         1,
+		done:        make(chan struct{}),
+		unsubscribe: unsubscribe,
+	}
+	s.observers[key] = o
+	s.mu.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+	go s.notifyLoop(key, o, changes)
+	return o.seq, true
+}
+
+func (s *Server) countObserversLocked(downloadKey string) int {
+	n := 0
+	for _, o := range s.observers {
+		if o.downloadKey == downloadKey {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) cancelObserver(key string) {
+	s.mu.Lock()
+	o := s.observers[key]
+	delete(s.observers, key)
+	s.mu.Unlock()
+	if o != nil {
+		o.stop()
+	}
+}
+
+// removeObserver ends o unless it has already been replaced by a new
+// registration under the same key.
+func (s *Server) removeObserver(key string, o *observer) {
+	s.mu.Lock()
+	if s.observers[key] == o {
+		delete(s.observers, key)
+	}
+	s.mu.Unlock()
+	o.stop()
+}
+
+// cancelObserverByMessageID ends the registration whose latest notification
+// was rejected by the client with a Reset message.
+func (s *Server) cancelObserverByMessageID(addr net.Addr, messageID uint16) {
+	s.mu.Lock()
+	var found *observer
+	for key, o := range s.observers {
+		if o.addr.String() == addr.String() && o.messageID == messageID {
+			found = o
+			delete(s.observers, key)
+			break
+		}
+	}
+	s.mu.Unlock()
+	if found != nil {
+		found.stop()
+	}
+}
+
+// acknowledge records the acknowledgement of a confirmable notification.
+func (s *Server) acknowledge(addr net.Addr, messageID uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.observers {
+		if o.acked != nil && o.addr.String() == addr.String() && o.messageID == messageID {
+			close(o.acked)
+			o.acked = nil
+			o.lastConfirmed = time.Now()
+			return
+		}
+	}
+}
+
+// notifyLoop sends a notification to o after every change until the
+// registration ends. A notification with an error code, such as 4.04 after
+// the data was deleted, ends the registration.
+func (s *Server) notifyLoop(key string, o *observer, changes <-chan struct{}) {
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-changes:
+		}
+
+		notification := o.render()
+
+		s.mu.Lock()
+		if s.observers[key] != o {
+			s.mu.Unlock()
+			return
+		}
+		final := !notification.Code.IsSuccess()
+		if !final {
+			o.seq = (o.seq + 1) & maxObserveSequence
+			notification.SetUintOption(OptionObserve, o.seq)
+		}
+		notification.Type = NonConfirmable
+		notification.Token = o.token
+		notification.MessageID = s.nextMessageIDLocked()
+		o.messageID = notification.MessageID
+		var acked chan struct{}
+		if o.lastConfirmed.IsZero() || !final && time.Since(o.lastConfirmed) >= s.confirmInterval {
+			notification.Type = Confirmable
+			acked = make(chan struct{})
+			o.acked = acked
+		}
+		s.mu.Unlock()
+
+		if acked == nil {
+			s.send(o.addr, notification)
+		} else if !s.sendConfirmable(o, notification, acked) {
+			s.removeObserver(key, o)
+			return
+		}
+		if final {
+			s.removeObserver(key, o)
+			return
+		}
+	}
+}
+
+// sendConfirmable sends m and retransmits it with exponential back-off until
+// it is acknowledged. It reports false if the observer did not answer.
+func (s *Server) sendConfirmable(o *observer, m Message, acked <-chan struct{}) bool {
+	timeout := s.ackTimeout
+	for attempt := 0; attempt <= maxRetransmit; attempt++ {
+		s.send(o.addr, m)
+		timer := time.NewTimer(timeout)
+		select {
+		case <-acked:
+			timer.Stop()
+			return true
+		case <-o.done:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		timeout *= 2
+	}
+	return false
+}
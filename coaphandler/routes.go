@@ -0,0 +1,283 @@
+package coaphandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// NotAcceptable is returned if the Accept option names an unsupported
+// content format.
+const NotAcceptable Code = 4<<5 | 6
+
+// supportedOptions are the critical options understood by the server. Uri-Host
+// and Uri-Port are accepted and ignored.
+var supportedOptions = map[OptionID]bool{
+	OptionURIHost:  true,
+	OptionURIPort:  true,
+	OptionURIPath:  true,
+	OptionURIQuery: true,
+	OptionAccept:   true,
+}
+
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+// handleRequest routes req to the handler of its Uri-Path:
+//
+//	POST|PUT /u/{uploadKey}
+//	POST|PUT /patch/{uploadKey}/{path...}
+//	GET      /d/{downloadKey}/json
+//	GET      /d/{downloadKey}/plain/{path...}
+//
+// The returned message holds the code, options and payload of the response.
+func (s *Server) handleRequest(addr net.Addr, req Message) Message {
+	for _, opt := range req.Options {
+		if opt.ID.critical() && !supportedOptions[opt.ID] {
+			return s.errorResponse(req, BadOption, fmt.Sprintf("unsupported option %d", opt.ID))
+		}
+	}
+
+	segments := req.StringOptions(OptionURIPath)
+	switch {
+	case len(segments) == 2 && segments[0] == "u":
+		if req.Code != POST && req.Code != PUT {
+			return s.errorResponse(req, MethodNotAllowed, "use POST or PUT")
+		}
+		return s.handleWrite(req, segments[1], "", false)
+
+	case len(segments) >= 2 && segments[0] == "patch":
+		if req.Code != POST && req.Code != PUT {
+			return s.errorResponse(req, MethodNotAllowed, "use POST or PUT")
+		}
+		return s.handleWrite(req, segments[1], strings.Join(segments[2:], "/"), true)
+
+	case len(segments) == 3 && segments[0] == "d" && segments[2] == "json":
+		if req.Code != GET {
+			return s.errorResponse(req, MethodNotAllowed, "use GET")
+		}
+		return s.observe(addr, req, segments[1], func() Message {
+			return s.downloadJSON(req, segments[1])
+		})
+
+	case len(segments) >= 4 && segments[0] == "d" && segments[2] == "plain":
+		if req.Code != GET {
+			return s.errorResponse(req, MethodNotAllowed, "use GET")
+		}
+		path := strings.Join(segments[3:], "/")
+		return s.observe(addr, req, segments[1], func() Message {
+			return s.downloadPlain(req, segments[1], path)
+		})
+	}
+
+	return s.errorResponse(req, NotFound, "not found")
+}
+
+// handleWrite stores the values of req with Upload or Patch semantics.
+func (s *Server) handleWrite(req Message, uploadKey, path string, isPatch bool) Message {
+	values, code, err := s.collectValues(req)
+	if err != nil {
+		return s.errorResponse(req, code, err.Error())
+	}
+
+	arrays, err := data.ParseArrayMergeMode(queryValue(req, "arrays"))
+	if err != nil {
+		return s.errorResponse(req, BadRequest, err.Error())
+	}
+
+	var downloadKey string
+	ctx := data.WithSource(context.Background(), data.SourceCoAP)
+	if isPatch {
+		downloadKey, _, err = s.DataService.PatchValues(ctx, uploadKey, path, values, data.MergeOptions{Arrays: arrays})
+	} else {
+		downloadKey, _, err = s.DataService.UploadValues(ctx, uploadKey, values)
+	}
+	if err != nil {
+		return s.errorResponse(req, BadRequest, err.Error())
+	}
+
+	s.StatsInstance.IncrementUploads()
+
+	payload, _ := json.Marshal(map[string]string{"download_key": downloadKey})
+	resp := Message{Code: Changed, Payload: payload}
+	resp.SetUintOption(OptionContentFormat, ContentFormatJSON)
+	return resp
+}
+
+// collectValues returns the values to store. A JSON or CBOR object in the
+// payload may contain nested objects and arrays; without a payload the
+// Uri-Query options are used as key=value pairs. String values are sanitized
+// in all cases.
+func (s *Server) collectValues(req Message) (map[string]interface{}, Code, error) {
+	if len(req.Payload) == 0 {
+		values := make(map[string]interface{})
+		for _, query := range req.StringOptions(OptionURIQuery) {
+			key, value, _ := strings.Cut(query, "=")
+			if key != "" {
+				values[key] = html.EscapeString(value)
+			}
+		}
+		return values, 0, nil
+	}
+
+	if len(req.Payload) > s.MaxPayloadSize {
+		return nil, RequestEntityTooLarge, fmt.Errorf("payload of %d bytes exceeds the maximum of %d", len(req.Payload), s.MaxPayloadSize)
+	}
+
+	format, ok := req.UintOption(OptionContentFormat)
+	if !ok {
+		format = ContentFormatJSON
+	}
+
+	var decoded map[string]interface{}
+	switch format {
+	case ContentFormatJSON:
+		if err := json.Unmarshal(req.Payload, &decoded); err != nil {
+			return nil, BadRequest, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+	case ContentFormatCBOR:
+		if err := cborDecMode.Unmarshal(req.Payload, &decoded); err != nil {
+			return nil, BadRequest, fmt.Errorf("invalid CBOR payload: %w", err)
+		}
+		// Normalize to the types produced by encoding/json.
+		normalized, err := json.Marshal(decoded)
+		if err != nil {
+			return nil, BadRequest, fmt.Errorf("invalid CBOR payload: %w", err)
+		}
+		decoded = nil
+		if err := json.Unmarshal(normalized, &decoded); err != nil {
+			return nil, BadRequest, fmt.Errorf("invalid CBOR payload: %w", err)
+		}
+	default:
+		return nil, UnsupportedContentFormat, fmt.Errorf("unsupported content format %d", format)
+	}
+	if decoded == nil {
+		return nil, BadRequest, fmt.Errorf("invalid payload: expected an object")
+	}
+
+	for k, v := range decoded {
+		decoded[k] = sanitizeValue(v)
+	}
+	return decoded, 0, nil
+}
+
+// sanitizeValue HTML-escapes all strings in a decoded value, like the HTTP
+// upload handlers do.
+func sanitizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return html.EscapeString(val)
+	case map[string]interface{}:
+		for k, elem := range val {
+			val[k] = sanitizeValue(elem)
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = sanitizeValue(elem)
+		}
+	}
+	return v
+}
+
+// downloadJSON returns the stored document as JSON or, if requested with the
+// Accept option, as CBOR.
+func (s *Server) downloadJSON(req Message, downloadKey string) Message {
+	format, ok := req.UintOption(OptionAccept)
+	if !ok {
+		format = ContentFormatJSON
+	}
+	if format != ContentFormatJSON && format != ContentFormatCBOR {
+		return s.errorResponse(req, NotAcceptable, "supported formats are JSON (50) and CBOR (60)")
+	}
+
+	jsonData, err := s.DataService.DownloadJSON(context.Background(), downloadKey)
+	if err != nil {
+		return s.errorResponse(req, NotFound, "invalid download key or data not found")
+	}
+
+	payload := jsonData
+	if format == ContentFormatCBOR {
+		var doc interface{}
+		if err := json.Unmarshal(jsonData, &doc); err != nil {
+			return s.errorResponse(req, InternalServerError, "error decoding JSON")
+		}
+		if payload, err = cbor.Marshal(doc); err != nil {
+			return s.errorResponse(req, InternalServerError, "error encoding CBOR")
+		}
+	}
+
+	s.StatsInstance.IncrementDownloads()
+	return contentResponse(format, payload)
+}
+
+// downloadPlain returns a single value as text. Strings are returned as-is,
+// other values as JSON.
+func (s *Server) downloadPlain(req Message, downloadKey, path string) Message {
+	if format, ok := req.UintOption(OptionAccept); ok && format != ContentFormatText {
+		return s.errorResponse(req, NotAcceptable, "supported format is text (0)")
+	}
+
+	value, err := s.DataService.DownloadField(context.Background(), downloadKey, path)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return s.errorResponse(req, NotFound, "parameter not found")
+		}
+		return s.errorResponse(req, BadRequest, "invalid parameter path")
+	}
+
+	payload, ok := value.(string)
+	if !ok {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return s.errorResponse(req, InternalServerError, "error encoding value")
+		}
+		payload = string(b)
+	}
+
+	s.StatsInstance.IncrementDownloads()
+	return contentResponse(ContentFormatText, []byte(payload))
+}
+
+// contentResponse returns a 2.05 response that must not be cached, since the
+// values can change at any time.
+func contentResponse(format uint32, payload []byte) Message {
+	resp := Message{Code: Content, Payload: payload}
+	resp.SetUintOption(OptionContentFormat, format)
+	resp.SetUintOption(OptionMaxAge, 0)
+	return resp
+}
+
+// errorResponse returns a response with code and a diagnostic payload.
+func (s *Server) errorResponse(req Message, code Code, diagnostic string) Message {
+	slog.Debug("coap: request failed", "code", code.String(), "error", diagnostic, "method", req.Code.String(), "path", "/"+req.Path())
+	s.StatsInstance.IncrementHTTPErrors()
+	return Message{Code: code, Payload: []byte(diagnostic)}
+}
+
+// queryValue returns the value of the first Uri-Query option named key.
+func queryValue(req Message, key string) string {
+	prefix := []byte(key + "=")
+	for _, opt := range req.Options {
+		if opt.ID == OptionURIQuery && bytes.HasPrefix(opt.Value, prefix) {
+			return string(opt.Value[len(prefix):])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,292 @@
+// Package coaphandler serves the upload and download routes over CoAP
+// (RFC 7252) for constrained devices that cannot speak HTTP. Only the subset
+// of the protocol needed by these routes is implemented: piggybacked
+// responses, message deduplication and Observe (RFC 7641). Block-wise
+// transfers are not supported, so payloads must fit into one datagram.
+package coaphandler
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+)
+
+// DefaultPort is the registered UDP port for CoAP.
+const DefaultPort = 5683
+
+// DefaultMaxPayloadSize is used if Config.MaxPayloadSize is zero.
+const DefaultMaxPayloadSize = 1024
+
+// MaxObservers limits the number of concurrent Observe registrations and
+// MaxObserversPerKey those for the resources of one download key. Further GET
+// requests with Observe are answered without registering.
+const (
+	MaxObservers       = 1024
+	MaxObserversPerKey = 16
+)
+
+const (
+	// maxDatagramSize is the largest UDP payload read from the socket.
+	maxDatagramSize = 64 * 1024
+	// recentResponses is the number of responses kept to answer
+	// retransmitted requests without processing them again.
+	recentResponses = 256
+	// confirmInterval is how often a notification is sent as confirmable to
+	// check that the observer is still interested (RFC 7641, section 4.5).
+	confirmInterval = 24 * time.Hour
+	// ackTimeout and maxRetransmit are the transmission parameters of
+	// RFC 7252, section 4.8.
+	ackTimeout    = 2 * time.Second
+	maxRetransmit = 4
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("coap: server closed")
+
+// RateLimiter decides whether another request from a client IP is allowed.
+// middleware.Config implements it.
+type RateLimiter interface {
+	Allow(ip string) bool
+}
+
+// Config holds the dependencies of the CoAP server.
+type Config struct {
+	DataService   *data.Service
+	StatsInstance *stats.Stats
+
+	// RateLimiter limits the requests per client IP. Every request and ping
+	// counts, including retransmissions. Requests over the limit are dropped
+	// without an answer. Nil disables rate limiting.
+	RateLimiter RateLimiter
+
+	// MaxPayloadSize limits the payload of write requests. Zero means
+	// DefaultMaxPayloadSize.
+	MaxPayloadSize int
+}
+
+// Server is a CoAP server. It must be created with NewServer.
+type Server struct {
+	Config
+
+	mu        sync.Mutex
+	conn      net.PacketConn
+	closed    bool
+	messageID uint16
+	observers map[string]*observer
+
+	recent     map[string][]byte
+	recentKeys []string
+
+	confirmInterval time.Duration
+	ackTimeout      time.Duration
+}
+
+// NewServer returns a Server for the given configuration.
+func NewServer(c Config) *Server {
+	if c.MaxPayloadSize == 0 {
+		c.MaxPayloadSize = DefaultMaxPayloadSize
+	}
+	return &Server{
+		Config:          c,
+		messageID:       uint16(time.Now().UnixNano()),
+		observers:       make(map[string]*observer),
+		recent:          make(map[string][]byte),
+		confirmInterval: confirmInterval,
+		ackTimeout:      ackTimeout,
+	}
+}
+
+// ListenAndServe listens on the UDP address addr and serves requests until
+// Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve reads requests from conn until Close is called. Requests are handled
+// in the order they arrive.
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return ErrServerClosed
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.handlePacket(addr, buf[:n])
+	}
+}
+
+// Close stops the server, ends all Observe registrations and closes the
+// connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	observers := s.observers
+	s.observers = make(map[string]*observer)
+	conn := s.conn
+	s.mu.Unlock()
+
+	for _, o := range observers {
+		o.stop()
+	}
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) handlePacket(addr net.Addr, packet []byte) {
+	req, err := Unmarshal(packet)
+	if err != nil {
+		slog.Debug("coap: ignoring malformed message", "error", err, "remote", addr.String())
+		return
+	}
+
+	switch req.Type {
+	case Reset:
+		s.cancelObserverByMessageID(addr, req.MessageID)
+		return
+	case Acknowledgement:
+		s.acknowledge(addr, req.MessageID)
+		return
+	}
+
+	if !s.allow(addr) {
+		return
+	}
+
+	if req.Code == Empty {
+		// An empty confirmable message is a CoAP ping, answered with Reset.
+		if req.Type == Confirmable {
+			s.send(addr, Message{Type: Reset, MessageID: req.MessageID})
+		}
+		return
+	}
+	if req.Code>>5 != 0 {
+		// Responses are never expected from clients.
+		return
+	}
+
+	dedupKey := addr.String() + "#" + strconv.Itoa(int(req.MessageID))
+	if cached, ok := s.recentResponse(dedupKey); ok {
+		s.writeTo(addr, cached)
+		return
+	}
+
+	resp := s.handleRequest(addr, req)
+	resp.Token = req.Token
+	if req.Type == Confirmable {
+		resp.Type = Acknowledgement
+		resp.MessageID = req.MessageID
+	} else {
+		resp.Type = NonConfirmable
+		resp.MessageID = s.nextMessageID()
+	}
+
+	b, err := resp.Marshal()
+	if err != nil {
+		slog.Error("coap: failed to encode response", "error", err, "remote", addr.String())
+		return
+	}
+	s.rememberResponse(dedupKey, b)
+	s.writeTo(addr, b)
+}
+
+func (s *Server) allow(addr net.Addr) bool {
+	ip := hostIP(addr)
+	if s.RateLimiter == nil || s.RateLimiter.Allow(ip) {
+		return true
+	}
+	slog.Error("coap: rate limit exceeded", "remote_addr", ip)
+	s.StatsInstance.IncrementHTTPErrors()
+	s.StatsInstance.RecordRateLimitHit(ip)
+	return false
+}
+
+// hostIP returns the IP of a UDP address without the port.
+func hostIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (s *Server) send(addr net.Addr, m Message) {
+	b, err := m.Marshal()
+	if err != nil {
+		slog.Error("coap: failed to encode message", "error", err, "remote", addr.String())
+		return
+	}
+	s.writeTo(addr, b)
+}
+
+func (s *Server) writeTo(addr net.Addr, b []byte) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if _, err := conn.WriteTo(b, addr); err != nil {
+		slog.Debug("coap: failed to send message", "error", err, "remote", addr.String())
+	}
+}
+
+func (s *Server) nextMessageID() uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextMessageIDLocked()
+}
+
+func (s *Server) nextMessageIDLocked() uint16 {
+	s.messageID++
+	return s.messageID
+}
+
+func (s *Server) recentResponse(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.recent[key]
+	return b, ok
+}
+
+// rememberResponse keeps b for answering retransmissions of the request
+// identified by key. Only the latest recentResponses responses are kept.
+func (s *Server) rememberResponse(key string, b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recentKeys) >= recentResponses {
+		delete(s.recent, s.recentKeys[0])
+		s.recentKeys = s.recentKeys[1:]
+	}
+	s.recent[key] = b
+	s.recentKeys = append(s.recentKeys, key)
+}
@@ -0,0 +1,535 @@
+package coaphandler
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// startTestServer serves on a random localhost UDP port and returns a client
+// connection to it.
+func startTestServer(t *testing.T) (*Server, *data.Service, *testClient) {
+	return startLimitedTestServer(t, nil)
+}
+
+func startLimitedTestServer(t *testing.T, limiter RateLimiter) (*Server, *data.Service, *testClient) {
+	t.Helper()
+
+	si := storage.NewInMemoryStorage()
+	svc := &data.Service{StorageInstance: &si}
+	srv := NewServer(Config{DataService: svc, StatsInstance: stats.NewStats(), RateLimiter: limiter})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(conn) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+
+	return srv, svc, newTestClient(t, conn.LocalAddr())
+}
+
+type testClient struct {
+	t         *testing.T
+	conn      net.Conn
+	messageID uint16
+}
+
+func newTestClient(t *testing.T, addr net.Addr) *testClient {
+	t.Helper()
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn}
+}
+
+func (c *testClient) send(m Message) {
+	c.t.Helper()
+	b, err := m.Marshal()
+	if err != nil {
+		c.t.Fatalf("Marshal failed: %v", err)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+}
+
+func (c *testClient) receive() Message {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, maxDatagramSize)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	m, err := Unmarshal(buf[:n])
+	if err != nil {
+		c.t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return m
+}
+
+// expectSilence fails if a message arrives within a short time.
+func (c *testClient) expectSilence() {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	buf := make([]byte, maxDatagramSize)
+	if n, err := c.conn.Read(buf); err == nil {
+		m, _ := Unmarshal(buf[:n])
+		c.t.Fatalf("unexpected message %v %q", m.Code, m.Payload)
+	}
+}
+
+// request sends a confirmable request and returns the piggybacked response.
+func (c *testClient) request(code Code, path string, build func(m *Message)) Message {
+	c.t.Helper()
+	c.messageID++
+	req := Message{Type: Confirmable, Code: code, MessageID: c.messageID, Token: []byte{byte(c.messageID), 0x42}}
+	req.SetPath(path)
+	if build != nil {
+		build(&req)
+	}
+	c.send(req)
+
+	resp := c.receive()
+	if resp.Type != Acknowledgement || resp.MessageID != req.MessageID || string(resp.Token) != string(req.Token) {
+		c.t.Fatalf("unexpected response header: type %d, id %d, token %x", resp.Type, resp.MessageID, resp.Token)
+	}
+	return resp
+}
+
+func withPayload(format uint32, payload []byte) func(m *Message) {
+	return func(m *Message) {
+		m.SetUintOption(OptionContentFormat, format)
+		m.Payload = payload
+	}
+}
+
+func withQuery(queries ...string) func(m *Message) {
+	return func(m *Message) {
+		for _, q := range queries {
+			m.AddOption(OptionURIQuery, []byte(q))
+		}
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	_, _, client := startTestServer(t)
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	cborPayload, _ := cbor.Marshal(map[string]interface{}{"humidity": 45, "tags": []string{"a"}})
+
+	tests := []struct {
+		name        string
+		code        Code
+		path        string
+		build       func(m *Message)
+		wantCode    Code
+		wantPayload string
+	}{
+		{"upload query", POST, "/u/" + uploadKey, withQuery("temp=21", "unit=<C>"), Changed, downloadKey},
+		{"plain value", GET, "/d/" + downloadKey + "/plain/temp", nil, Content, "21"},
+		{"plain sanitized", GET, "/d/" + downloadKey + "/plain/unit", nil, Content, "&lt;C&gt;"},
+		{"upload JSON", PUT, "/u/" + uploadKey, withPayload(ContentFormatJSON, []byte(`{"temp":22.5,"room":{"name":"kitchen"}}`)), Changed, downloadKey},
+		{"upload replaces", GET, "/d/" + downloadKey + "/plain/unit", nil, NotFound, ""},
+		{"plain nested", GET, "/d/" + downloadKey + "/plain/room/name", nil, Content, "kitchen"},
+		{"plain number", GET, "/d/" + downloadKey + "/plain/temp", nil, Content, "22.5"},
+		{"plain object", GET, "/d/" + downloadKey + "/plain/room", nil, Content, `{"name":"kitchen"}`},
+		{"patch CBOR", POST, "/patch/" + uploadKey + "/sensors", withPayload(ContentFormatCBOR, cborPayload), Changed, downloadKey},
+		{"patch keeps existing", GET, "/d/" + downloadKey + "/plain/room/name", nil, Content, "kitchen"},
+		{"patched value", GET, "/d/" + downloadKey + "/plain/sensors/humidity", nil, Content, "45"},
+		{"patched array", GET, "/d/" + downloadKey + "/plain/sensors/tags/0", nil, Content, "a"},
+		{"patch append", POST, "/patch/" + uploadKey + "/sensors", func(m *Message) {
+			withPayload(ContentFormatJSON, []byte(`{"tags":["b"]}`))(m)
+			withQuery("arrays=append")(m)
+		}, Changed, downloadKey},
+		{"appended value", GET, "/d/" + downloadKey + "/plain/sensors/tags/1", nil, Content, "b"},
+		{"invalid upload key", POST, "/u/invalid", withQuery("temp=1"), BadRequest, ""},
+		{"invalid JSON", POST, "/u/" + uploadKey, withPayload(ContentFormatJSON, []byte(`{`)), BadRequest, ""},
+		{"JSON array", POST, "/u/" + uploadKey, withPayload(ContentFormatJSON, []byte(`[1]`)), BadRequest, ""},
+		{"unsupported format", POST, "/u/" + uploadKey, withPayload(ContentFormatText, []byte(`temp=1`)), UnsupportedContentFormat, ""},
+		{"too large", POST, "/u/" + uploadKey, withPayload(ContentFormatJSON, []byte(`{"a":"`+strings.Repeat("x", DefaultMaxPayloadSize)+`"}`)), RequestEntityTooLarge, ""},
+		{"invalid arrays", POST, "/patch/" + uploadKey, withQuery("temp=1", "arrays=zip"), BadRequest, ""},
+		{"upload with GET", GET, "/u/" + uploadKey, nil, MethodNotAllowed, ""},
+		{"download with POST", POST, "/d/" + downloadKey + "/json", nil, MethodNotAllowed, ""},
+		{"unknown key", GET, "/d/unknown/json", nil, NotFound, ""},
+		{"unknown path", GET, "/d/" + downloadKey + "/plain/missing", nil, NotFound, ""},
+		{"unknown route", GET, "/kp", nil, NotFound, ""},
+		{"unsupported critical option", GET, "/d/" + downloadKey + "/json", func(m *Message) { m.AddOption(27, []byte{0}) }, BadOption, ""},
+		{"not acceptable", GET, "/d/" + downloadKey + "/json", func(m *Message) { m.SetUintOption(OptionAccept, ContentFormatText) }, NotAcceptable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := client.request(tt.code, tt.path, tt.build)
+			if resp.Code != tt.wantCode {
+				t.Fatalf("code = %v, want %v (payload %q)", resp.Code, tt.wantCode, resp.Payload)
+			}
+			if tt.wantPayload == "" {
+				return
+			}
+			got := string(resp.Payload)
+			if tt.wantCode == Changed {
+				var body map[string]string
+				if err := json.Unmarshal(resp.Payload, &body); err != nil {
+					t.Fatalf("invalid response payload %q: %v", resp.Payload, err)
+				}
+				got = body["download_key"]
+			}
+			if got != tt.wantPayload {
+				t.Errorf("payload = %q, want %q", got, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestServerDownloadJSON(t *testing.T) {
+	_, svc, client := startTestServer(t)
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(context.Background(), uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		accept     int
+		wantFormat uint32
+		decode     func([]byte, interface{}) error
+	}{
+		{"default", -1, ContentFormatJSON, json.Unmarshal},
+		{"JSON", ContentFormatJSON, ContentFormatJSON, json.Unmarshal},
+		{"CBOR", ContentFormatCBOR, ContentFormatCBOR, cbor.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := client.request(GET, "/d/"+downloadKey+"/json", func(m *Message) {
+				if tt.accept >= 0 {
+					m.SetUintOption(OptionAccept, uint32(tt.accept))
+				}
+			})
+			if resp.Code != Content {
+				t.Fatalf("code = %v, want 2.05 (payload %q)", resp.Code, resp.Payload)
+			}
+			if format, _ := resp.UintOption(OptionContentFormat); format != tt.wantFormat {
+				t.Errorf("content format = %d, want %d", format, tt.wantFormat)
+			}
+			if maxAge, ok := resp.UintOption(OptionMaxAge); !ok || maxAge != 0 {
+				t.Errorf("Max-Age = %d, %v, want 0", maxAge, ok)
+			}
+			var doc map[string]interface{}
+			if err := tt.decode(resp.Payload, &doc); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if doc["temp"] != "21" || doc["timestamp"] == nil {
+				t.Errorf("unexpected document %v", doc)
+			}
+		})
+	}
+}
+
+func TestServerNonConfirmableAndPing(t *testing.T) {
+	_, _, client := startTestServer(t)
+
+	client.send(Message{Type: NonConfirmable, Code: GET, MessageID: 10, Token: []byte{1}, Options: []Option{{ID: OptionURIPath, Value: []byte("x")}}})
+	resp := client.receive()
+	if resp.Type != NonConfirmable || resp.Code != NotFound || string(resp.Token) != "\x01" {
+		t.Errorf("unexpected NON response: type %d, code %v, token %x", resp.Type, resp.Code, resp.Token)
+	}
+
+	client.send(Message{Type: Confirmable, Code: Empty, MessageID: 11})
+	resp = client.receive()
+	if resp.Type != Reset || resp.MessageID != 11 {
+		t.Errorf("unexpected ping response: type %d, id %d", resp.Type, resp.MessageID)
+	}
+}
+
+func TestServerDeduplicatesRetransmissions(t *testing.T) {
+	_, svc, client := startTestServer(t)
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	changes, cancel := svc.Subscribe(downloadKey)
+	defer cancel()
+
+	req := Message{Type: Confirmable, Code: POST, MessageID: 99, Token: []byte{9}}
+	req.SetPath("/u/" + uploadKey)
+	req.AddOption(OptionURIQuery, []byte("temp=1"))
+
+	client.send(req)
+	first := client.receive()
+	<-changes
+	client.send(req)
+	second := client.receive()
+
+	if first.Code != Changed || second.Code != Changed || second.MessageID != first.MessageID {
+		t.Errorf("unexpected responses %v/%d and %v/%d", first.Code, first.MessageID, second.Code, second.MessageID)
+	}
+	select {
+	case <-changes:
+		t.Error("retransmission was processed again")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestServerObserve(t *testing.T) {
+	_, svc, client := startTestServer(t)
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	token := []byte("obs")
+	register := Message{Type: Confirmable, Code: GET, MessageID: 1, Token: token}
+	register.SetPath("/d/" + downloadKey + "/plain/temp")
+	register.SetUintOption(OptionObserve, observeRegister)
+	client.send(register)
+
+	resp := client.receive()
+	seq, ok := resp.UintOption(OptionObserve)
+	if resp.Code != Content || !ok || string(resp.Payload) != "21" {
+		t.Fatalf("unexpected registration response: %v, observe %v, payload %q", resp.Code, ok, resp.Payload)
+	}
+
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "22"}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	notification := client.receive()
+	next, ok := notification.UintOption(OptionObserve)
+	if notification.Code != Content || string(notification.Payload) != "22" || string(notification.Token) != string(token) {
+		t.Fatalf("unexpected notification: %v %q token %q", notification.Code, notification.Payload, notification.Token)
+	}
+	if !ok || next <= seq {
+		t.Errorf("observe sequence %d not greater than %d", next, seq)
+	}
+	// The first notification confirms the registration.
+	if notification.Type != Confirmable {
+		t.Fatalf("first notification type = %d, want confirmable", notification.Type)
+	}
+	client.send(Message{Type: Acknowledgement, MessageID: notification.MessageID})
+
+	// A write through CoAP notifies as well.
+	writer := newTestClient(t, client.conn.RemoteAddr())
+	if resp := writer.request(POST, "/patch/"+uploadKey, withQuery("temp=23")); resp.Code != Changed {
+		t.Fatalf("patch failed: %v %q", resp.Code, resp.Payload)
+	}
+	if notification := client.receive(); string(notification.Payload) != "23" || notification.Type != NonConfirmable {
+		t.Errorf("notification payload = %q, type %d, want non-confirmable 23", notification.Payload, notification.Type)
+	}
+
+	// Rejecting a notification with Reset ends the registration.
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "24"}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	notification = client.receive()
+	client.send(Message{Type: Reset, MessageID: notification.MessageID})
+	time.Sleep(50 * time.Millisecond)
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "25"}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	client.expectSilence()
+}
+
+func TestServerObserveDeregisterAndDelete(t *testing.T) {
+	srv, svc, client := startTestServer(t)
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	observe := func(token string, value uint32) Message {
+		m := Message{Type: NonConfirmable, Code: GET, MessageID: uint16(len(token)) + uint16(value)<<8, Token: []byte(token)}
+		m.SetPath("/d/" + downloadKey + "/json")
+		m.SetUintOption(OptionObserve, value)
+		client.send(m)
+		return client.receive()
+	}
+
+	if resp := observe("a", observeRegister); resp.Code != Content {
+		t.Fatalf("registration failed: %v", resp.Code)
+	}
+	if resp := observe("a", observeDeregister); resp.Code != Content {
+		t.Fatalf("deregistration failed: %v", resp.Code)
+	}
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "22"}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	client.expectSilence()
+
+	if resp := observe("bb", observeRegister); resp.Code != Content {
+		t.Fatalf("registration failed: %v", resp.Code)
+	}
+	if _, err := svc.Delete(ctx, uploadKey); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	notification := client.receive()
+	if notification.Code != NotFound {
+		t.Errorf("notification code = %v, want 4.04", notification.Code)
+	}
+	if _, ok := notification.UintOption(OptionObserve); ok {
+		t.Error("final notification must not carry the Observe option")
+	}
+	client.send(Message{Type: Acknowledgement, MessageID: notification.MessageID})
+	waitForObservers(t, srv, 0)
+}
+
+// waitForObservers fails if the number of registrations does not drop to
+// want within two seconds.
+func waitForObservers(t *testing.T, srv *Server, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		remaining := len(srv.observers)
+		srv.mu.Unlock()
+		if remaining <= want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d observers remain registered, want %d", remaining, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerConfirmableNotification(t *testing.T) {
+	srv, svc, client := startTestServer(t)
+	srv.confirmInterval = 0
+	srv.ackTimeout = 20 * time.Millisecond
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	register := Message{Type: Confirmable, Code: GET, MessageID: 1, Token: []byte{7}}
+	register.SetPath("/d/" + downloadKey + "/json")
+	register.SetUintOption(OptionObserve, observeRegister)
+	client.send(register)
+	client.receive()
+
+	// An acknowledged notification keeps the registration.
+	svc.Upload(ctx, uploadKey, map[string]string{"temp": "22"})
+	notification := client.receive()
+	if notification.Type != Confirmable {
+		t.Fatalf("notification type = %d, want confirmable", notification.Type)
+	}
+	client.send(Message{Type: Acknowledgement, MessageID: notification.MessageID})
+
+	// An unacknowledged notification is retransmitted and then ends the
+	// registration.
+	svc.Upload(ctx, uploadKey, map[string]string{"temp": "23"})
+	first := client.receive()
+	for i := 0; i < maxRetransmit; i++ {
+		if retransmission := client.receive(); retransmission.MessageID != first.MessageID {
+			t.Fatalf("retransmission has message ID %d, want %d", retransmission.MessageID, first.MessageID)
+		}
+	}
+	waitForObservers(t, srv, 0)
+}
+
+func TestServerObserveUnconfirmed(t *testing.T) {
+	srv, svc, client := startTestServer(t)
+	srv.ackTimeout = 10 * time.Millisecond
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	register := Message{Type: NonConfirmable, Code: GET, MessageID: 1, Token: []byte{3}}
+	register.SetPath("/d/" + downloadKey + "/json")
+	register.SetUintOption(OptionObserve, observeRegister)
+	client.send(register)
+	client.receive()
+
+	// A source that never acknowledges the first notification, e.g. a
+	// spoofed address, gets no further notifications.
+	svc.Upload(ctx, uploadKey, map[string]string{"temp": "22"})
+	for i := 0; i <= maxRetransmit; i++ {
+		if notification := client.receive(); notification.Type != Confirmable {
+			t.Fatalf("notification type = %d, want confirmable", notification.Type)
+		}
+	}
+	waitForObservers(t, srv, 0)
+	svc.Upload(ctx, uploadKey, map[string]string{"temp": "23"})
+	client.expectSilence()
+}
+
+func TestServerObserveLimitPerKey(t *testing.T) {
+	srv, svc, client := startTestServer(t)
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	for i := 0; i <= MaxObserversPerKey; i++ {
+		m := Message{Type: NonConfirmable, Code: GET, MessageID: uint16(i), Token: []byte{byte(i)}}
+		m.SetPath("/d/" + downloadKey + "/json")
+		m.SetUintOption(OptionObserve, observeRegister)
+		client.send(m)
+		resp := client.receive()
+		_, registered := resp.UintOption(OptionObserve)
+		if resp.Code != Content || registered != (i < MaxObserversPerKey) {
+			t.Fatalf("registration %d: code %v, registered %v", i, resp.Code, registered)
+		}
+	}
+
+	srv.mu.Lock()
+	registered := len(srv.observers)
+	srv.mu.Unlock()
+	if registered != MaxObserversPerKey {
+		t.Errorf("%d observers registered, want %d", registered, MaxObserversPerKey)
+	}
+}
+
+// denyLimiter allows the first requests and denies all others.
+type denyLimiter struct{ allowed int }
+
+func (l *denyLimiter) Allow(string) bool {
+	l.allowed--
+	return l.allowed >= 0
+}
+
+func TestServerRateLimit(t *testing.T) {
+	_, _, client := startLimitedTestServer(t, &denyLimiter{allowed: 1})
+
+	if resp := client.request(GET, "/d/x/json", nil); resp.Code != NotFound {
+		t.Fatalf("first request: %v, want 4.04", resp.Code)
+	}
+	client.send(Message{Type: Confirmable, Code: Empty, MessageID: 50})
+	client.expectSilence()
+}
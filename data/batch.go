@@ -0,0 +1,396 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+// MaxBatchSize is the maximum number of entries accepted by a single batch
+// operation.
+const MaxBatchSize = 50
+
+// DownloadRequest selects one download key of a batch read. If Paths is
+// empty, the whole document is returned.
+type DownloadRequest struct {
+	DownloadKey string   `json:"download_key" jsonschema:"The download key to read"`
+	Paths       []string `json:"paths,omitempty" jsonschema:"Optional value paths to return (e.g. 'temp' or 'room1/temp'). If empty the whole document is returned"`
+}
+
+// UnmarshalJSON accepts either an object or a bare download key string, so a
+// batch can be written as ["d_...", "d_..."].
+func (r *DownloadRequest) UnmarshalJSON(b []byte) error {
+	var key string
+	if err := json.Unmarshal(b, &key); err == nil {
+		*r = DownloadRequest{DownloadKey: key}
+		return nil
+	}
+	type plain DownloadRequest
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+// DownloadResult is the outcome of one entry of a batch read. Error is set
+// if the key could not be read at all; PathErrors holds failures of
+// individual paths while the remaining paths are still returned.
+type DownloadResult struct {
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+	PathErrors map[string]string      `json:"path_errors,omitempty"`
+	ExpiresAt  string                 `json:"expires_at,omitempty"`
+	TTLSeconds int64                  `json:"ttl_seconds,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// DownloadMany reads several download keys in a single storage transaction.
+// The result is keyed by the download key as given in the request. A failure
+// of one entry is reported in its DownloadResult and does not affect the
+// others; the error is only set for an invalid batch or a storage failure.
+func (s *Service) DownloadMany(ctx context.Context, requests []DownloadRequest) (map[string]DownloadResult, error) {
+	if len(requests) == 0 {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid batch: no download keys given"))
+	}
+	if len(requests) > MaxBatchSize {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid batch: %d download keys exceed the maximum of %d", len(requests), MaxBatchSize))
+	}
+
+	seen := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		if seen[req.DownloadKey] {
+			return nil, markError(ErrValidation, fmt.Errorf("invalid batch: download key %q is given more than once", req.DownloadKey))
+		}
+		seen[req.DownloadKey] = true
+	}
+
+	// Invalid keys are answered without a lookup, so they cannot address
+	// internal records and Badger does not reject the transaction for an
+	// empty key.
+	results := make(map[string]DownloadResult, len(requests))
+	var valid []DownloadRequest
+	var keys []string
+	for _, req := range requests {
+		if req.DownloadKey == "" {
+			results[req.DownloadKey] = DownloadResult{Error: "missing download key"}
+			continue
+		}
+		key, err := storageDownloadKey(req.DownloadKey)
+		if err != nil {
+			results[req.DownloadKey] = DownloadResult{Error: err.Error()}
+			continue
+		}
+		valid = append(valid, req)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	entries, err := s.StorageInstance.GetJSONMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data: %w", err)
+	}
+
+	now := time.Now()
+	for i, req := range valid {
+		results[req.DownloadKey] = buildDownloadResult(req, entries[i], now)
+	}
+	return results, nil
+}
+
+func buildDownloadResult(req DownloadRequest, entry storage.JSONEntry, now time.Time) DownloadResult {
+	if entry.Err != nil {
+		return DownloadResult{Error: fmt.Sprintf("invalid download key or data not found: %v", entry.Err)}
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(entry.Data, &doc); err != nil {
+		return DownloadResult{Error: fmt.Sprintf("error decoding JSON: %v", err)}
+	}
+
+	var result DownloadResult
+	if !entry.ExpiresAt.IsZero() {
+		result.ExpiresAt = entry.ExpiresAt.Format(time.RFC3339)
+		result.TTLSeconds = RemainingTTLSeconds(entry.ExpiresAt, now)
+	}
+
+	if len(req.Paths) == 0 {
+		result.Data = doc
+		return result
+	}
+
+	result.Values = make(map[string]interface{}, len(req.Paths))
+	for _, path := range req.Paths {
+		value, err := TraverseField(doc, path)
+		if err != nil {
+			if result.PathErrors == nil {
+				result.PathErrors = make(map[string]string)
+			}
+			result.PathErrors[path] = err.Error()
+			continue
+		}
+		result.Values[path] = value
+	}
+	return result
+}
+
+// Write modes of a batch operation.
+const (
+	// WriteModeUpload replaces all data of the key, like Upload.
+	WriteModeUpload = "upload"
+	// WriteModePatch merges the values at the path, like Patch.
+	WriteModePatch = "patch"
+)
+
+// Statuses of a WriteResult.
+const (
+	WriteStatusOK    = "ok"
+	WriteStatusError = "error"
+)
+
+// WriteOperation is a single write of a batch. Mode defaults to
+// WriteModeUpload; Path and Arrays only apply to WriteModePatch.
+type WriteOperation struct {
+	UploadKey string                 `json:"upload_key"`
+	Path      string                 `json:"path,omitempty"`
+	Values    map[string]interface{} `json:"values"`
+	Mode      string                 `json:"mode,omitempty"`
+	Arrays    string                 `json:"arrays,omitempty"`
+}
+
+// WriteResult reports the outcome of the WriteOperation at Index.
+type WriteResult struct {
+	Index       int    `json:"index"`
+	DownloadKey string `json:"download_key,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+// preparedWrite is a validated WriteOperation.
+type preparedWrite struct {
+	index       int
+	downloadKey string
+	op          WriteOperation
+	arrays      ArrayMergeMode
+}
+
+// UploadMany applies several write operations and stores all affected
+// documents in a single storage transaction. Operations are applied in
+// order, so several operations on the same key build on each other. An
+// invalid operation is reported in its WriteResult without affecting the
+// others; if the final transaction fails, all operations are reported as
+// failed. The error is only set for an invalid batch or a failure to read
+// the existing data.
+func (s *Service) UploadMany(ctx context.Context, ops []WriteOperation) ([]WriteResult, error) {
+	if len(ops) == 0 {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid batch: no operations given"))
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid batch: %d operations exceed the maximum of %d", len(ops), MaxBatchSize))
+	}
+
+	results := make([]WriteResult, len(ops))
+	var prepared []preparedWrite
+	var keys []string
+	seen := make(map[string]bool)
+	for i, op := range ops {
+		results[i] = WriteResult{Index: i}
+		p, err := prepareWrite(i, op)
+		if err != nil {
+			results[i].Status = WriteStatusError
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].DownloadKey = p.downloadKey
+		prepared = append(prepared, p)
+		if !seen[p.downloadKey] {
+			seen[p.downloadKey] = true
+			keys = append(keys, p.downloadKey)
+		}
+	}
+	if len(prepared) == 0 {
+		return results, nil
+	}
+
+	docs, metas, err := s.loadDocuments(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	source := SourceFromContext(ctx)
+	written := make(map[string]bool)
+	for _, p := range prepared {
+		doc, err := s.applyWrite(docs[p.downloadKey], metas[p.downloadKey], p, source, now)
+		if err != nil {
+			results[p.index].Status = WriteStatusError
+			results[p.index].Error = err.Error()
+			continue
+		}
+		docs[p.downloadKey] = doc
+		written[p.downloadKey] = true
+		results[p.index].Status = WriteStatusOK
+	}
+
+	var entries []storage.StoreEntry
+	var renew []string
+	for _, key := range keys {
+		if !written[key] {
+			continue
+		}
+		keyEntries, err := documentEntries(key, docs[key], metas[key])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, keyEntries...)
+		renew = append(renew, templatesKey(key))
+	}
+	if len(entries) == 0 {
+		return results, nil
+	}
+
+	if err := s.StorageInstance.StoreMany(ctx, entries, renew...); err != nil {
+		for i := range results {
+			if results[i].Status == WriteStatusOK {
+				results[i].Status = WriteStatusError
+				results[i].Error = fmt.Sprintf("error storing data: %v", err)
+			}
+		}
+		return results, nil
+	}
+	for _, key := range keys {
+		if written[key] {
+			s.notifyChange(key)
+		}
+	}
+	return results, nil
+}
+
+// write applies p as a single write and stores the document, its metadata
+// record and the renewed TTL of its templates in one transaction.
+func (s *Service) write(ctx context.Context, p preparedWrite) (map[string]interface{}, error) {
+	docs, metas, err := s.loadDocuments(ctx, []string{p.downloadKey})
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.applyWrite(docs[p.downloadKey], metas[p.downloadKey], p, SourceFromContext(ctx), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := documentEntries(p.downloadKey, doc, metas[p.downloadKey])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.StorageInstance.StoreMany(ctx, entries, templatesKey(p.downloadKey)); err != nil {
+		return nil, fmt.Errorf("error storing data: %w", err)
+	}
+	s.notifyChange(p.downloadKey)
+	return doc, nil
+}
+
+// documentEntries returns the storage entries of a document and its
+// metadata record.
+func documentEntries(downloadKey string, doc map[string]interface{}, meta *Meta) ([]storage.StoreEntry, error) {
+	raw, err := meta.toMap()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding metadata: %w", err)
+	}
+	return []storage.StoreEntry{
+		{Key: downloadKey, Data: doc},
+		{Key: metaKey(downloadKey), Data: raw},
+	}, nil
+}
+
+// prepareWrite validates op and derives its download key.
+func prepareWrite(index int, op WriteOperation) (preparedWrite, error) {
+	if err := domain.ValidateUploadKey(op.UploadKey); err != nil {
+		return preparedWrite{}, markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
+	}
+	downloadKey, err := domain.DeriveDownloadKey(op.UploadKey)
+	if err != nil {
+		return preparedWrite{}, fmt.Errorf("error deriving download key: %w", err)
+	}
+
+	switch op.Mode {
+	case "":
+		op.Mode = WriteModeUpload
+	case WriteModeUpload, WriteModePatch:
+	default:
+		return preparedWrite{}, markError(ErrValidation, fmt.Errorf("unknown mode %q (expected upload or patch)", op.Mode))
+	}
+	if op.Mode == WriteModeUpload && op.Path != "" {
+		return preparedWrite{}, markError(ErrValidation, fmt.Errorf("path requires mode patch"))
+	}
+
+	arrays, err := ParseArrayMergeMode(op.Arrays)
+	if err != nil {
+		return preparedWrite{}, err
+	}
+	if len(op.Values) == 0 {
+		return preparedWrite{}, markError(ErrValidation, fmt.Errorf("no values given"))
+	}
+
+	return preparedWrite{index: index, downloadKey: downloadKey, op: op, arrays: arrays}, nil
+}
+
+// applyWrite returns the document resulting from applying p to doc and
+// records the write in meta. doc and meta are not modified if the operation
+// fails, so it leaves no partial changes behind.
+func (s *Service) applyWrite(doc map[string]interface{}, meta *Meta, p preparedWrite, source string, now time.Time) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if p.op.Mode == WriteModePatch {
+		if err := convertMap(doc, &result); err != nil {
+			return nil, fmt.Errorf("error copying existing data: %w", err)
+		}
+	}
+
+	values := make(map[string]interface{})
+	if err := convertMap(p.op.Values, &values); err != nil {
+		return nil, fmt.Errorf("error copying values: %w", err)
+	}
+
+	if err := MergeDataAtPathWithOptions(result, p.op.Path, values, MergeOptions{Arrays: p.arrays}); err != nil {
+		return nil, err
+	}
+	meta.applyWrite(source, p.op.Path, valueKeys(values), p.op.Mode == WriteModeUpload, now)
+	applyTimestamps(result, p.op.Path, valueKeys(values), s.TimestampMode, s.TimestampFormat, now, meta.userValue)
+	return result, nil
+}
+
+// loadDocuments reads the documents and metadata records of keys in one
+// transaction. Missing documents and records are returned empty.
+func (s *Service) loadDocuments(ctx context.Context, keys []string) (map[string]map[string]interface{}, map[string]*Meta, error) {
+	lookup := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		lookup = append(lookup, key, metaKey(key))
+	}
+	entries, err := s.StorageInstance.GetJSONMany(ctx, lookup)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving existing data: %w", err)
+	}
+
+	docs := make(map[string]map[string]interface{}, len(keys))
+	metas := make(map[string]*Meta, len(keys))
+	for i, key := range keys {
+		doc := make(map[string]interface{})
+		if entry := entries[2*i]; entry.Err == nil {
+			if err := json.Unmarshal(entry.Data, &doc); err != nil {
+				return nil, nil, fmt.Errorf("error decoding existing data: %w", err)
+			}
+		}
+		meta := &Meta{}
+		if entry := entries[2*i+1]; entry.Err == nil {
+			if err := json.Unmarshal(entry.Data, meta); err != nil {
+				slog.Warn("data: failed to decode metadata", "error", err)
+				meta = &Meta{}
+			}
+		}
+		docs[key] = doc
+		metas[key] = meta
+	}
+	return docs, metas, nil
+}
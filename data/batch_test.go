@@ -0,0 +1,199 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestDownloadMany(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.UploadValues(ctx, uploadKey, map[string]interface{}{
+		"temp":  "21",
+		"rooms": []interface{}{map[string]interface{}{"temp": "19"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	prefixed := domain.AddDownloadPrefix(downloadKey)
+	missing, _ := domain.DeriveDownloadKey(domain.GenerateRandomKey())
+
+	results, err := svc.DownloadMany(ctx, []DownloadRequest{
+		{DownloadKey: downloadKey},
+		{DownloadKey: prefixed, Paths: []string{"temp", "rooms/0/temp", "humidity"}},
+		{DownloadKey: missing},
+		{DownloadKey: ""},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	full := results[downloadKey]
+	if full.Error != "" || full.Data["temp"] != "21" || full.ExpiresAt == "" {
+		t.Errorf("Unexpected full result: %+v", full)
+	}
+
+	paths := results[prefixed]
+	wantValues := map[string]interface{}{"temp": "21", "rooms/0/temp": "19"}
+	if !reflect.DeepEqual(paths.Values, wantValues) {
+		t.Errorf("Expected values %v, got %v", wantValues, paths.Values)
+	}
+	if paths.Data != nil {
+		t.Error("Expected no full document when paths are given")
+	}
+	if !strings.Contains(paths.PathErrors["humidity"], "not found") {
+		t.Errorf("Expected path error for humidity, got %v", paths.PathErrors)
+	}
+
+	if !strings.Contains(results[missing].Error, "not found") {
+		t.Errorf("Expected not found error, got %+v", results[missing])
+	}
+	if results[""].Error == "" {
+		t.Error("Expected error for empty download key")
+	}
+}
+
+func TestDownloadMany_InvalidBatch(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	if _, err := svc.DownloadMany(ctx, nil); err == nil {
+		t.Error("Expected error for empty batch")
+	}
+	if _, err := svc.DownloadMany(ctx, make([]DownloadRequest, MaxBatchSize+1)); err == nil {
+		t.Error("Expected error for oversized batch")
+	}
+	key, _ := domain.DeriveDownloadKey(domain.GenerateRandomKey())
+	if _, err := svc.DownloadMany(ctx, []DownloadRequest{{DownloadKey: key}, {DownloadKey: key, Paths: []string{"temp"}}}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected validation error for a duplicate key, got %v", err)
+	}
+}
+
+func TestDownload_InternalRecords(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := svc.SetTemplate(ctx, uploadKey, "t", "{{.temp}}"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, key := range []string{metaKey(downloadKey), templatesKey(downloadKey)} {
+		if _, err := svc.DownloadJSON(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DownloadJSON(%q): expected not found, got %v", key, err)
+		}
+		if _, err := svc.DownloadField(ctx, key, "write_count"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DownloadField(%q): expected not found, got %v", key, err)
+		}
+		results, err := svc.DownloadMany(ctx, []DownloadRequest{{DownloadKey: key}})
+		if err != nil || results[key].Error == "" || results[key].Data != nil {
+			t.Errorf("DownloadMany(%q): expected an entry error, got %+v, %v", key, results[key], err)
+		}
+	}
+}
+
+func TestDownloadRequest_UnmarshalJSON(t *testing.T) {
+	var reqs []DownloadRequest
+	err := json.Unmarshal([]byte(`["d_abc", {"download_key": "def", "paths": ["temp"]}]`), &reqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []DownloadRequest{{DownloadKey: "d_abc"}, {DownloadKey: "def", Paths: []string{"temp"}}}
+	if !reflect.DeepEqual(reqs, want) {
+		t.Errorf("got %+v, want %+v", reqs, want)
+	}
+}
+
+func TestUploadMany(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := WithSource(context.Background(), SourceHTTP)
+
+	keyA := domain.GenerateRandomKey()
+	keyB := domain.GenerateRandomKey()
+	downloadA, _ := domain.DeriveDownloadKey(keyA)
+	downloadB, _ := domain.DeriveDownloadKey(keyB)
+
+	if _, _, err := svc.Upload(ctx, keyB, map[string]string{"name": "gateway"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	results, err := svc.UploadMany(ctx, []WriteOperation{
+		{UploadKey: keyA, Values: map[string]interface{}{"temp": "21"}},
+		{UploadKey: keyA, Mode: WriteModePatch, Path: "node1", Values: map[string]interface{}{"rssi": "-80"}},
+		{UploadKey: keyB, Mode: WriteModePatch, Values: map[string]interface{}{"nodes": []interface{}{"n1"}}},
+		{UploadKey: "invalid", Values: map[string]interface{}{"temp": "1"}},
+		{UploadKey: keyA, Mode: "replace", Values: map[string]interface{}{"temp": "1"}},
+		{UploadKey: keyA, Path: "node2", Values: map[string]interface{}{"temp": "1"}},
+		{UploadKey: keyA, Values: nil},
+		{UploadKey: keyB, Mode: WriteModePatch, Path: "nodes/5", Values: map[string]interface{}{"temp": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	wantStatus := []string{WriteStatusOK, WriteStatusOK, WriteStatusOK, WriteStatusError, WriteStatusError, WriteStatusError, WriteStatusError, WriteStatusError}
+	for i, want := range wantStatus {
+		if results[i].Index != i || results[i].Status != want {
+			t.Errorf("Result %d: expected status %s, got %+v", i, want, results[i])
+		}
+	}
+	if results[0].DownloadKey != downloadA {
+		t.Errorf("Expected download key %s, got %s", downloadA, results[0].DownloadKey)
+	}
+
+	for _, tc := range []struct {
+		key, path string
+		want      interface{}
+	}{
+		{downloadA, "temp", "21"},
+		{downloadA, "node1/rssi", "-80"},
+		{downloadB, "name", "gateway"},
+		{downloadB, "nodes/0", "n1"},
+	} {
+		got, err := svc.DownloadField(ctx, tc.key, tc.path)
+		if err != nil {
+			t.Fatalf("DownloadField(%s) error: %v", tc.path, err)
+		}
+		if got != tc.want {
+			t.Errorf("DownloadField(%s) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	doc, err := svc.DownloadDocument(ctx, downloadA)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if doc.Meta.WriteCount != 2 || doc.Meta.Source != SourceHTTP {
+		t.Errorf("Unexpected metadata: %+v", doc.Meta)
+	}
+	if _, ok := doc.Meta.PerPathUpdatedAt["node1/rssi"]; !ok {
+		t.Errorf("Expected per-path time for node1/rssi, got %v", doc.Meta.PerPathUpdatedAt)
+	}
+}
+
+func TestUploadMany_InvalidBatch(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	if _, err := svc.UploadMany(ctx, nil); err == nil {
+		t.Error("Expected error for empty batch")
+	}
+	if _, err := svc.UploadMany(ctx, make([]WriteOperation, MaxBatchSize+1)); err == nil {
+		t.Error("Expected error for oversized batch")
+	}
+}
@@ -2,7 +2,9 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/domain"
@@ -13,6 +15,19 @@ import (
 // Both httphandler and mcphandler delegate to this service.
 type Service struct {
 	StorageInstance storage.Storage
+
+	// StaleAfter is the maximum age of a value before Status reports it as
+	// stale. Zero means DefaultStaleAfter.
+	StaleAfter time.Duration
+
+	// TimestampMode and TimestampFormat control the server-generated
+	// timestamps added on every Upload and Patch, regardless of which
+	// frontend performed the write. Zero values mean TimestampModeLegacy and
+	// TimestampFormatRFC3339.
+	TimestampMode   TimestampMode
+	TimestampFormat TimestampFormat
+
+	changes changeBroker
 }
 
 // GenerateKeyPair generates a new upload/download key pair.
@@ -26,10 +41,17 @@ func (s *Service) GenerateKeyPair() (uploadKey, downloadKey string, err error) {
 }
 
 // Upload validates the upload key, replaces all data with the given params
-// (adding a root timestamp), and stores it. Returns the download key and stored data.
+// (adding timestamps according to the TimestampMode), and stores it. Returns
+// the download key and stored data.
 func (s *Service) Upload(ctx context.Context, uploadKey string, params map[string]string) (downloadKey string, storedData map[string]interface{}, err error) {
+	return s.UploadValues(ctx, uploadKey, stringValues(params))
+}
+
+// UploadValues is like Upload but accepts arbitrary JSON values, including
+// nested objects and arrays.
+func (s *Service) UploadValues(ctx context.Context, uploadKey string, values map[string]interface{}) (downloadKey string, storedData map[string]interface{}, err error) {
 	if err := domain.ValidateUploadKey(uploadKey); err != nil {
-		return "", nil, fmt.Errorf("invalid upload key: %w", err)
+		return "", nil, markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
 	}
 
 	downloadKey, err = domain.DeriveDownloadKey(uploadKey)
@@ -37,24 +59,28 @@ func (s *Service) Upload(ctx context.Context, uploadKey string, params map[strin
 		return "", nil, fmt.Errorf("error deriving download key: %w", err)
 	}
 
-	data := make(map[string]interface{})
-	for k, v := range params {
-		data[k] = v
-	}
-	data["timestamp"] = time.Now().UTC().Format(time.RFC3339)
-
-	if err := s.StorageInstance.Store(ctx, downloadKey, data); err != nil {
-		return "", nil, fmt.Errorf("error storing data: %w", err)
+	storedData, err = s.write(ctx, preparedWrite{
+		downloadKey: downloadKey,
+		op:          WriteOperation{UploadKey: uploadKey, Values: values, Mode: WriteModeUpload},
+	})
+	if err != nil {
+		return "", nil, err
 	}
-
-	return downloadKey, data, nil
+	return downloadKey, storedData, nil
 }
 
 // Patch validates the upload key, retrieves existing data, merges the given
-// params at the specified path, adds a root timestamp, and stores the result.
+// params at the specified path, adds timestamps according to the
+// TimestampMode, and stores the result.
 func (s *Service) Patch(ctx context.Context, uploadKey string, path string, params map[string]string) (downloadKey string, storedData map[string]interface{}, err error) {
+	return s.PatchValues(ctx, uploadKey, path, stringValues(params), MergeOptions{})
+}
+
+// PatchValues is like Patch but accepts arbitrary JSON values. Arrays already
+// stored under the same key are combined according to opts.
+func (s *Service) PatchValues(ctx context.Context, uploadKey string, path string, values map[string]interface{}, opts MergeOptions) (downloadKey string, storedData map[string]interface{}, err error) {
 	if err := domain.ValidateUploadKey(uploadKey); err != nil {
-		return "", nil, fmt.Errorf("invalid upload key: %w", err)
+		return "", nil, markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
 	}
 
 	downloadKey, err = domain.DeriveDownloadKey(uploadKey)
@@ -62,44 +88,47 @@ func (s *Service) Patch(ctx context.Context, uploadKey string, path string, para
 		return "", nil, fmt.Errorf("error deriving download key: %w", err)
 	}
 
-	existingData, err := s.StorageInstance.Retrieve(ctx, downloadKey)
+	storedData, err = s.write(ctx, preparedWrite{
+		downloadKey: downloadKey,
+		op:          WriteOperation{UploadKey: uploadKey, Path: path, Values: values, Mode: WriteModePatch},
+		arrays:      opts.Arrays,
+	})
 	if err != nil {
-		return "", nil, fmt.Errorf("error retrieving existing data: %w", err)
-	}
-
-	newData := make(map[string]interface{})
-	for k, v := range params {
-		newData[k] = v
-	}
-
-	MergeDataAtPath(existingData, path, newData)
-
-	existingData["timestamp"] = time.Now().UTC().Format(time.RFC3339)
-
-	if err := s.StorageInstance.Store(ctx, downloadKey, existingData); err != nil {
-		return "", nil, fmt.Errorf("error storing data: %w", err)
+		return "", nil, err
 	}
-
-	return downloadKey, existingData, nil
+	return downloadKey, storedData, nil
 }
 
 // DownloadJSON retrieves the raw JSON bytes for the given download key.
 func (s *Service) DownloadJSON(ctx context.Context, downloadKey string) ([]byte, error) {
-	downloadKey = domain.StripDownloadPrefix(downloadKey)
-	jsonData, err := s.StorageInstance.GetJSON(ctx, downloadKey)
+	jsonData, _, err := s.DownloadJSONWithExpiry(ctx, downloadKey)
+	return jsonData, err
+}
+
+// DownloadJSONWithExpiry retrieves the raw JSON bytes for the given download
+// key together with the time at which the data expires.
+func (s *Service) DownloadJSONWithExpiry(ctx context.Context, downloadKey string) ([]byte, time.Time, error) {
+	downloadKey, err := storageDownloadKey(downloadKey)
 	if err != nil {
-		return nil, fmt.Errorf("invalid download key or data not found: %w", err)
+		return nil, time.Time{}, err
 	}
-	return jsonData, nil
+	jsonData, expiresAt, err := s.StorageInstance.GetJSONWithExpiry(ctx, downloadKey)
+	if err != nil {
+		return nil, time.Time{}, markNotFound(fmt.Errorf("invalid download key or data not found: %w", err))
+	}
+	return jsonData, expiresAt, nil
 }
 
 // DownloadField retrieves a specific field from the stored data by traversing
 // the nested map using the given slash-separated field path.
 func (s *Service) DownloadField(ctx context.Context, downloadKey string, fieldPath string) (interface{}, error) {
-	downloadKey = domain.StripDownloadPrefix(downloadKey)
+	downloadKey, err := storageDownloadKey(downloadKey)
+	if err != nil {
+		return nil, err
+	}
 	data, err := s.StorageInstance.Retrieve(ctx, downloadKey)
 	if err != nil {
-		return nil, fmt.Errorf("invalid download key or data not found: %w", err)
+		return nil, markNotFound(fmt.Errorf("invalid download key or data not found: %w", err))
 	}
 
 	value, err := TraverseField(data, fieldPath)
@@ -109,10 +138,95 @@ func (s *Service) DownloadField(ctx context.Context, downloadKey string, fieldPa
 	return value, nil
 }
 
+// Query evaluates a JSONPath expression (see JSONPath) against the stored
+// data for the given download key.
+func (s *Service) Query(ctx context.Context, downloadKey string, expr string) ([]QueryMatch, error) {
+	query, err := CompileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := s.DownloadJSON(ctx, downloadKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	return query.Evaluate(doc)
+}
+
+// Status retrieves the stored data for the given download key and reports
+// which value paths have not been updated within maxAge. A zero maxAge falls
+// back to the Service's StaleAfter setting.
+func (s *Service) Status(ctx context.Context, downloadKey string, maxAge time.Duration) (StatusReport, error) {
+	downloadKey, err := storageDownloadKey(downloadKey)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	jsonData, err := s.StorageInstance.GetJSON(ctx, downloadKey)
+	if err != nil {
+		return StatusReport{}, markNotFound(fmt.Errorf("invalid download key or data not found: %w", err))
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return StatusReport{}, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	meta, err := s.loadMeta(ctx, downloadKey)
+	if err != nil {
+		return StatusReport{}, err
+	}
+
+	return BuildStatusReport(doc, meta, s.ResolveMaxAge(maxAge), time.Now()), nil
+}
+
+// ResolveMaxAge returns maxAge if positive, otherwise the Service's StaleAfter
+// setting, otherwise DefaultStaleAfter.
+func (s *Service) ResolveMaxAge(maxAge time.Duration) time.Duration {
+	if maxAge > 0 {
+		return maxAge
+	}
+	if s.StaleAfter > 0 {
+		return s.StaleAfter
+	}
+	return DefaultStaleAfter
+}
+
+// Touch validates the upload key and renews the TTL of the associated data
+// without rewriting it. Returns the download key and the new expiry time.
+func (s *Service) Touch(ctx context.Context, uploadKey string) (downloadKey string, expiresAt time.Time, err error) {
+	if err := domain.ValidateUploadKey(uploadKey); err != nil {
+		return "", time.Time{}, markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
+	}
+
+	downloadKey, err = domain.DeriveDownloadKey(uploadKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error deriving download key: %w", err)
+	}
+
+	expiresAt, err = s.StorageInstance.Touch(ctx, downloadKey)
+	if err != nil {
+		return "", time.Time{}, markNotFound(fmt.Errorf("error extending TTL: %w", err))
+	}
+
+	// The metadata record is optional; documents written before it existed
+	// have none.
+	if _, err := s.StorageInstance.Touch(ctx, metaKey(downloadKey)); err != nil {
+		slog.Debug("data: failed to extend metadata TTL", "error", err)
+	}
+	s.renewTemplates(ctx, downloadKey)
+
+	return downloadKey, expiresAt, nil
+}
+
 // Delete validates the upload key and deletes the associated data.
 func (s *Service) Delete(ctx context.Context, uploadKey string) (downloadKey string, err error) {
 	if err := domain.ValidateUploadKey(uploadKey); err != nil {
-		return "", fmt.Errorf("invalid upload key: %w", err)
+		return "", markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
 	}
 
 	downloadKey, err = domain.DeriveDownloadKey(uploadKey)
@@ -123,6 +237,41 @@ func (s *Service) Delete(ctx context.Context, uploadKey string) (downloadKey str
 	if err := s.StorageInstance.Delete(ctx, downloadKey); err != nil {
 		return "", fmt.Errorf("error deleting data: %w", err)
 	}
+	if err := s.StorageInstance.Delete(ctx, metaKey(downloadKey)); err != nil {
+		return "", fmt.Errorf("error deleting metadata: %w", err)
+	}
+	if err := s.StorageInstance.Delete(ctx, templatesKey(downloadKey)); err != nil {
+		return "", fmt.Errorf("error deleting templates: %w", err)
+	}
+	s.notifyChange(downloadKey)
 
 	return downloadKey, nil
 }
+
+// storageDownloadKey validates downloadKey and returns its storage key.
+// Validating keeps internal records like "<downloadKey>:meta" from being read
+// as documents.
+func storageDownloadKey(downloadKey string) (string, error) {
+	if err := domain.ValidateDownloadKey(downloadKey); err != nil {
+		return "", markError(ErrNotFound, fmt.Errorf("invalid download key or data not found: %w", err))
+	}
+	return domain.StripDownloadPrefix(downloadKey), nil
+}
+
+// stringValues converts query-style string params into stored values.
+func stringValues(params map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		values[k] = v
+	}
+	return values
+}
+
+// valueKeys returns the keys of values.
+func valueKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	return keys
+}
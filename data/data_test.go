@@ -3,6 +3,8 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/domain"
@@ -162,7 +164,7 @@ func TestDownloadField(t *testing.T) {
 	svc, si := newTestService()
 	ctx := context.Background()
 
-	downloadKey := "testkey"
+	downloadKey := "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a"
 	si.Store(ctx, downloadKey, map[string]interface{}{
 		"temp": "23",
 		"room": map[string]interface{}{
@@ -286,3 +288,142 @@ func TestTraverseField(t *testing.T) {
 		}
 	})
 }
+
+func TestTraverseField_Arrays(t *testing.T) {
+	data := map[string]interface{}{
+		"sensors": []interface{}{
+			map[string]interface{}{"temp": "20"},
+			map[string]interface{}{"temp": "21"},
+			"raw",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "Index", path: "sensors/0/temp", want: "20"},
+		{name: "Second index", path: "sensors/1/temp", want: "21"},
+		{name: "Negative index", path: "sensors/-1", want: "raw"},
+		{name: "Negative nested", path: "sensors/-2/temp", want: "21"},
+		{name: "Out of range", path: "sensors/3", wantErr: "not found"},
+		{name: "Negative out of range", path: "sensors/-4", wantErr: "not found"},
+		{name: "Non-numeric segment", path: "sensors/first", wantErr: "invalid parameter path"},
+		{name: "Scalar element", path: "sensors/2/temp", wantErr: "invalid parameter path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TraverseField(data, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCollectPaths(t *testing.T) {
+	data := map[string]interface{}{
+		"temp": "23",
+		"sensors": []interface{}{
+			map[string]interface{}{"temp": "20"},
+			"raw",
+		},
+		"room": map[string]interface{}{"humidity": "45"},
+	}
+
+	got := CollectPaths(data, "")
+	want := []string{"room/humidity", "sensors/0/temp", "sensors/1", "temp"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPatchValues_Arrays(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+	uploadKey := domain.GenerateRandomKey()
+
+	downloadKey, _, err := svc.UploadValues(ctx, uploadKey, map[string]interface{}{
+		"sensors": []interface{}{map[string]interface{}{"temp": "20"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, _, err = svc.PatchValues(ctx, uploadKey, "sensors/0", map[string]interface{}{"humidity": "40"}, MergeOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, _, err = svc.PatchValues(ctx, uploadKey, "", map[string]interface{}{
+		"sensors": []interface{}{map[string]interface{}{"temp": "22"}},
+	}, MergeOptions{Arrays: ArrayMergeAppend})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"sensors/0/temp":     "20",
+		"sensors/0/humidity": "40",
+		"sensors/-1/temp":    "22",
+	} {
+		got, err := svc.DownloadField(ctx, downloadKey, path)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", path, err)
+		}
+		if got != want {
+			t.Errorf("Expected %s=%s, got %v", path, want, got)
+		}
+	}
+
+	if _, _, err := svc.PatchValues(ctx, uploadKey, "sensors/5", map[string]interface{}{"temp": "1"}, MergeOptions{}); err == nil {
+		t.Error("Expected error for out-of-range array index")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+
+	if _, _, err := svc.Touch(ctx, uploadKey); err == nil {
+		t.Error("Expected error when no data is stored")
+	}
+
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "23"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	touchedKey, expiresAt, err := svc.Touch(ctx, uploadKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if touchedKey != downloadKey {
+		t.Errorf("Expected download key %s, got %s", downloadKey, touchedKey)
+	}
+
+	_, storedExpiry, err := svc.DownloadJSONWithExpiry(ctx, downloadKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !storedExpiry.Equal(expiresAt) {
+		t.Errorf("Expected expiry %v, got %v", expiresAt, storedExpiry)
+	}
+
+	if _, _, err := svc.Touch(ctx, "invalid"); err == nil {
+		t.Error("Expected error for invalid upload key")
+	}
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+// Errors returned by the Service are marked with one of these kinds, so
+// frontends can test them with errors.Is instead of matching messages. The
+// message of a marked error is unchanged. Storage failures keep their
+// storage errors, e.g. storage.ErrStorageDegraded.
+var (
+	// ErrNotFound marks errors for download keys without data and value
+	// paths that do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidKey marks errors for malformed upload keys.
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrValidation marks errors for invalid paths, queries, options and
+	// batches.
+	ErrValidation = errors.New("validation failed")
+)
+
+// Stable error codes returned by ErrorCode. CodeTooLarge is set by the
+// frontends for request bodies over their size limit, CodeClientCertRequired
+// for writes without a verified TLS client certificate and
+// CodeMethodNotAllowed for writes with GET where only POST is accepted.
+const (
+	CodeNotFound           = "not_found"
+	CodeInvalidKey         = "invalid_key"
+	CodeValidation         = "validation"
+	CodeTooLarge           = "too_large"
+	CodeClientCertRequired = "client_certificate_required"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeStorageDegraded    = "storage_degraded"
+	CodeStorageTimeout     = "storage_timeout"
+	CodeInternal           = "internal"
+)
+
+// ErrorCode classifies err into one of the stable error codes. Errors of
+// unknown kind yield CodeInternal.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrStorageDegraded):
+		return CodeStorageDegraded
+	case errors.Is(err, storage.ErrStorageTimeout), errors.Is(err, context.DeadlineExceeded):
+		return CodeStorageTimeout
+	case errors.Is(err, ErrNotFound), errors.Is(err, storage.ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrInvalidKey):
+		return CodeInvalidKey
+	case errors.Is(err, ErrValidation):
+		return CodeValidation
+	default:
+		return CodeInternal
+	}
+}
+
+// kindError marks err with a kind while keeping its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// markError marks err with kind.
+func markError(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// markNotFound marks err with ErrNotFound if the storage reported a missing
+// key, so timeouts and other storage failures keep their own kind.
+func markNotFound(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return markError(ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+func TestErrorCode(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{
+			name: "unknown download key",
+			call: func() error { _, err := svc.DownloadJSON(ctx, domain.GenerateRandomKey()); return err },
+			want: CodeNotFound,
+		},
+		{
+			name: "missing field",
+			call: func() error { _, err := svc.DownloadField(ctx, downloadKey, "missing"); return err },
+			want: CodeNotFound,
+		},
+		{
+			name: "path through a value",
+			call: func() error { _, err := svc.DownloadField(ctx, downloadKey, "temp/x"); return err },
+			want: CodeValidation,
+		},
+		{
+			name: "invalid upload key",
+			call: func() error { _, _, err := svc.Upload(ctx, "invalid", nil); return err },
+			want: CodeInvalidKey,
+		},
+		{
+			name: "invalid query",
+			call: func() error { _, err := svc.Query(ctx, downloadKey, "$[?("); return err },
+			want: CodeValidation,
+		},
+		{
+			name: "touch without data",
+			call: func() error { _, _, err := svc.Touch(ctx, domain.GenerateRandomKey()); return err },
+			want: CodeNotFound,
+		},
+		{
+			name: "degraded storage",
+			call: func() error { return fmt.Errorf("error storing data: %w", storage.ErrStorageDegraded) },
+			want: CodeStorageDegraded,
+		},
+		{
+			name: "storage timeout",
+			call: func() error { return fmt.Errorf("error storing data: %w", storage.ErrStorageTimeout) },
+			want: CodeStorageTimeout,
+		},
+		{
+			name: "unknown error",
+			call: func() error { return fmt.Errorf("disk on fire") },
+			want: CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCode(tt.call()); got != tt.want {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name:       "validation uses the error message",
+			err:        markError(ErrValidation, fmt.Errorf("invalid path")),
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "invalid path",
+		},
+		{
+			name:       "not found uses the given detail",
+			err:        markError(ErrNotFound, fmt.Errorf("key not found")),
+			wantStatus: http.StatusNotFound,
+			wantDetail: "detail",
+		},
+		{
+			name:       "degraded storage hides the error message",
+			err:        fmt.Errorf("error storing data: %w", storage.ErrStorageDegraded),
+			wantStatus: http.StatusServiceUnavailable,
+			wantDetail: "detail",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ProblemFor(tt.err, "detail")
+			if p.Status != tt.wantStatus || p.Detail != tt.wantDetail {
+				t.Errorf("ProblemFor() = %+v, want status %d and detail %q", p, tt.wantStatus, tt.wantDetail)
+			}
+			if p.Type != ProblemTypePath+p.Code {
+				t.Errorf("Type = %q, want it derived from code %q", p.Type, p.Code)
+			}
+			if pt, ok := LookupProblemType(p.Code); !ok || pt.Description == "" {
+				t.Errorf("problem type %q is not documented", p.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,690 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QueryMatch is a single value selected by a JSONPath query together with
+// its slash-separated path, as accepted by TraverseField.
+type QueryMatch struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// JSONPath is a compiled query in the supported JSONPath subset:
+//
+//	$                 the root document
+//	.name, ['name']   child by name
+//	[0], [-1]         array element by (negative) index
+//	[1:3]             array slice
+//	['a','b'], [0,2]  union of names or indexes (projection)
+//	.*, [*]           all children
+//	..name, ..[...]   the selector applied to all descendants
+//	[?(@.x < 20)]     children matching a filter expression
+//
+// Filter expressions compare a relative path (@, @.name, @[0]) with a number,
+// quoted string, true, false or null using ==, !=, <, <=, > or >=; a bare
+// relative path tests for existence. Conditions combine with && and ||.
+// Strings holding numbers compare numerically, as stored values are usually
+// strings.
+type JSONPath struct {
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	descendant bool
+	sel        selector
+}
+
+type selectorKind int
+
+const (
+	selectNames selectorKind = iota
+	selectIndexes
+	selectWildcard
+	selectSlice
+	selectFilter
+)
+
+type selector struct {
+	kind    selectorKind
+	names   []string
+	indexes []int
+	start   *int
+	end     *int
+	filter  filterExpr
+}
+
+// Limits of a JSONPath query, which is evaluated for unauthenticated
+// readers. A query exceeding them fails with a validation error.
+const (
+	// MaxQueryLength is the maximum length of a query in bytes.
+	MaxQueryLength = 256
+	// MaxQueryDescendantSegments is the maximum number of ".." segments.
+	MaxQueryDescendantSegments = 2
+	// MaxQueryNodes is the maximum number of nodes a query may visit and
+	// select in total.
+	MaxQueryNodes = 10000
+)
+
+// CompileJSONPath parses a JSONPath expression.
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	if len(expr) > MaxQueryLength {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid query: %d bytes exceed the maximum of %d", len(expr), MaxQueryLength))
+	}
+	p := &jsonPathParser{src: strings.TrimSpace(expr)}
+	segments, err := p.parse()
+	if err != nil {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid query %q: %w", expr, err))
+	}
+	descendantSegments := 0
+	for _, seg := range segments {
+		if seg.descendant {
+			descendantSegments++
+		}
+	}
+	if descendantSegments > MaxQueryDescendantSegments {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid query %q: %d recursive descents exceed the maximum of %d", expr, descendantSegments, MaxQueryDescendantSegments))
+	}
+	return &JSONPath{segments: segments}, nil
+}
+
+// Evaluate applies the query to doc and returns all matches in document
+// order (object keys sorted). It fails with a validation error if the query
+// visits more than MaxQueryNodes nodes.
+func (q *JSONPath) Evaluate(doc map[string]interface{}) ([]QueryMatch, error) {
+	budget := queryBudget(MaxQueryNodes)
+	nodes := []QueryMatch{{Path: "", Value: doc}}
+	for _, seg := range q.segments {
+		candidates := nodes
+		if seg.descendant {
+			var err error
+			if candidates, err = descendants(nodes, &budget); err != nil {
+				return nil, err
+			}
+		}
+		var next []QueryMatch
+		for _, node := range candidates {
+			matches := seg.sel.apply(node)
+			if err := budget.spend(len(matches)); err != nil {
+				return nil, err
+			}
+			next = append(next, matches...)
+		}
+		nodes = next
+	}
+	if nodes == nil {
+		nodes = []QueryMatch{}
+	}
+	return nodes, nil
+}
+
+// queryBudget is the number of nodes a query may still visit.
+type queryBudget int
+
+func (b *queryBudget) spend(n int) error {
+	*b -= queryBudget(n)
+	if *b < 0 {
+		return markError(ErrValidation, fmt.Errorf("invalid query: more than %d nodes visited, use a more specific query", MaxQueryNodes))
+	}
+	return nil
+}
+
+// descendants returns nodes and all nodes nested below them in pre-order.
+// Nodes nested in several of the given nodes are returned once.
+func descendants(nodes []QueryMatch, budget *queryBudget) ([]QueryMatch, error) {
+	var result []QueryMatch
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		stack := []QueryMatch{node}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if seen[n.Path] {
+				continue
+			}
+			seen[n.Path] = true
+			if err := budget.spend(1); err != nil {
+				return nil, err
+			}
+			result = append(result, n)
+
+			kids := children(n)
+			for i := len(kids) - 1; i >= 0; i-- {
+				stack = append(stack, kids[i])
+			}
+		}
+	}
+	return result, nil
+}
+
+// children returns the direct children of a map or array node.
+func children(node QueryMatch) []QueryMatch {
+	switch v := node.Value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		result := make([]QueryMatch, 0, len(keys))
+		for _, k := range keys {
+			result = append(result, QueryMatch{Path: joinPath(node.Path, k), Value: v[k]})
+		}
+		return result
+	case []interface{}:
+		result := make([]QueryMatch, 0, len(v))
+		for i, elem := range v {
+			result = append(result, QueryMatch{Path: joinPath(node.Path, strconv.Itoa(i)), Value: elem})
+		}
+		return result
+	}
+	return nil
+}
+
+func (s selector) apply(node QueryMatch) []QueryMatch {
+	switch s.kind {
+	case selectNames:
+		m, ok := node.Value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		var result []QueryMatch
+		for _, name := range s.names {
+			if v, ok := m[name]; ok {
+				result = append(result, QueryMatch{Path: joinPath(node.Path, name), Value: v})
+			}
+		}
+		return result
+	case selectIndexes:
+		a, ok := node.Value.([]interface{})
+		if !ok {
+			return nil
+		}
+		var result []QueryMatch
+		for _, idx := range s.indexes {
+			if i, ok := resolveIndex(idx, len(a)); ok {
+				result = append(result, QueryMatch{Path: joinPath(node.Path, strconv.Itoa(i)), Value: a[i]})
+			}
+		}
+		return result
+	case selectWildcard:
+		return children(node)
+	case selectSlice:
+		a, ok := node.Value.([]interface{})
+		if !ok {
+			return nil
+		}
+		start, end := sliceBounds(s.start, s.end, len(a))
+		var result []QueryMatch
+		for i := start; i < end; i++ {
+			result = append(result, QueryMatch{Path: joinPath(node.Path, strconv.Itoa(i)), Value: a[i]})
+		}
+		return result
+	case selectFilter:
+		var result []QueryMatch
+		for _, child := range children(node) {
+			if s.filter.match(child.Value) {
+				result = append(result, child)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// sliceBounds clamps optional, possibly negative slice bounds to [0, length].
+func sliceBounds(start, end *int, length int) (int, int) {
+	clamp := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += length
+		}
+		return max(0, min(i, length))
+	}
+	return clamp(start, 0), clamp(end, length)
+}
+
+// filterExpr is a disjunction of conjunctions of conditions.
+type filterExpr [][]condition
+
+type condition struct {
+	path    []relativeStep
+	op      string
+	operand interface{}
+}
+
+type relativeStep struct {
+	name  string
+	index int
+	isIdx bool
+}
+
+func (f filterExpr) match(v interface{}) bool {
+	for _, and := range f {
+		ok := true
+		for _, c := range and {
+			if !c.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c condition) match(v interface{}) bool {
+	current := v
+	for _, step := range c.path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if step.isIdx {
+				return false
+			}
+			next, ok := node[step.name]
+			if !ok {
+				return false
+			}
+			current = next
+		case []interface{}:
+			if !step.isIdx {
+				return false
+			}
+			i, ok := resolveIndex(step.index, len(node))
+			if !ok {
+				return false
+			}
+			current = node[i]
+		default:
+			return false
+		}
+	}
+
+	if c.op == "" {
+		return true
+	}
+	return compareValues(current, c.op, c.operand)
+}
+
+// compareValues compares a document value with a filter literal. Numbers and
+// numeric strings compare numerically, other strings lexically; all other
+// values only support == and !=.
+func compareValues(left interface{}, op string, right interface{}) bool {
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			return compareOrdered(l, op, r)
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(l, op, r)
+		}
+	}
+	if isContainer(left) {
+		// Objects and arrays never equal a literal.
+		return op == "!="
+	}
+	switch op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](l T, op string, r T) bool {
+	switch op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+// toNumber converts numbers and numeric strings to float64.
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+func (p *jsonPathParser) parse() ([]pathSegment, error) {
+	if !p.consume("$") {
+		return nil, fmt.Errorf("must start with $")
+	}
+	var segments []pathSegment
+	for p.pos < len(p.src) {
+		var seg pathSegment
+		switch {
+		case p.consume(".."):
+			seg.descendant = true
+			sel, err := p.parseDotOrBracket(true)
+			if err != nil {
+				return nil, err
+			}
+			seg.sel = sel
+		case p.consume("."):
+			sel, err := p.parseDotOrBracket(false)
+			if err != nil {
+				return nil, err
+			}
+			seg.sel = sel
+		case p.peek() == '[':
+			sel, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.sel = sel
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// parseDotOrBracket parses the selector after "." or "..". A bracket is only
+// allowed after "..".
+func (p *jsonPathParser) parseDotOrBracket(allowBracket bool) (selector, error) {
+	if p.consume("*") {
+		return selector{kind: selectWildcard}, nil
+	}
+	if allowBracket && p.peek() == '[' {
+		return p.parseBracket()
+	}
+	name := p.parseIdentifier()
+	if name == "" {
+		return selector{}, fmt.Errorf("expected name at position %d", p.pos)
+	}
+	return selector{kind: selectNames, names: []string{name}}, nil
+}
+
+func (p *jsonPathParser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == '.' || ch == '[' || ch == ']' || ch == ' ' || ch == ')' || strings.ContainsRune("=!<>&|", rune(ch)) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *jsonPathParser) parseBracket() (selector, error) {
+	if !p.consume("[") {
+		return selector{}, fmt.Errorf("expected [ at position %d", p.pos)
+	}
+	p.skipSpaces()
+
+	var sel selector
+	switch {
+	case p.consume("*"):
+		sel = selector{kind: selectWildcard}
+	case p.consume("?("):
+		filter, err := p.parseFilter()
+		if err != nil {
+			return selector{}, err
+		}
+		if !p.consume(")") {
+			return selector{}, fmt.Errorf("expected ) at position %d", p.pos)
+		}
+		sel = selector{kind: selectFilter, filter: filter}
+	case p.peek() == '\'' || p.peek() == '"':
+		sel = selector{kind: selectNames}
+		for {
+			name, err := p.parseQuoted()
+			if err != nil {
+				return selector{}, err
+			}
+			sel.names = append(sel.names, name)
+			p.skipSpaces()
+			if !p.consume(",") {
+				break
+			}
+			p.skipSpaces()
+		}
+	default:
+		var err error
+		sel, err = p.parseIndexesOrSlice()
+		if err != nil {
+			return selector{}, err
+		}
+	}
+
+	p.skipSpaces()
+	if !p.consume("]") {
+		return selector{}, fmt.Errorf("expected ] at position %d", p.pos)
+	}
+	return sel, nil
+}
+
+func (p *jsonPathParser) parseIndexesOrSlice() (selector, error) {
+	first, hasFirst, err := p.parseOptionalInt()
+	if err != nil {
+		return selector{}, err
+	}
+	p.skipSpaces()
+	if p.consume(":") {
+		p.skipSpaces()
+		second, hasSecond, err := p.parseOptionalInt()
+		if err != nil {
+			return selector{}, err
+		}
+		sel := selector{kind: selectSlice}
+		if hasFirst {
+			sel.start = &first
+		}
+		if hasSecond {
+			sel.end = &second
+		}
+		return sel, nil
+	}
+	if !hasFirst {
+		return selector{}, fmt.Errorf("expected index at position %d", p.pos)
+	}
+
+	sel := selector{kind: selectIndexes, indexes: []int{first}}
+	for p.consume(",") {
+		p.skipSpaces()
+		idx, ok, err := p.parseOptionalInt()
+		if err != nil {
+			return selector{}, err
+		}
+		if !ok {
+			return selector{}, fmt.Errorf("expected index at position %d", p.pos)
+		}
+		sel.indexes = append(sel.indexes, idx)
+		p.skipSpaces()
+	}
+	return sel, nil
+}
+
+func (p *jsonPathParser) parseOptionalInt() (int, bool, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid index %q", p.src[start:p.pos])
+	}
+	return n, true, nil
+}
+
+func (p *jsonPathParser) parseQuoted() (string, error) {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", fmt.Errorf("expected quoted string at position %d", p.pos)
+	}
+	end := strings.IndexByte(p.src[p.pos+1:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at position %d", p.pos)
+	}
+	s := p.src[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+func (p *jsonPathParser) parseFilter() (filterExpr, error) {
+	var expr filterExpr
+	var and []condition
+	for {
+		p.skipSpaces()
+		c, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, c)
+		p.skipSpaces()
+		switch {
+		case p.consume("&&"):
+		case p.consume("||"):
+			expr = append(expr, and)
+			and = nil
+		default:
+			return append(expr, and), nil
+		}
+	}
+}
+
+func (p *jsonPathParser) parseCondition() (condition, error) {
+	if !p.consume("@") {
+		return condition{}, fmt.Errorf("expected @ at position %d", p.pos)
+	}
+	var c condition
+	for {
+		switch {
+		case p.consume("."):
+			name := p.parseIdentifier()
+			if name == "" {
+				return condition{}, fmt.Errorf("expected name at position %d", p.pos)
+			}
+			c.path = append(c.path, relativeStep{name: name})
+			continue
+		case p.consume("["):
+			p.skipSpaces()
+			if p.peek() == '\'' || p.peek() == '"' {
+				name, err := p.parseQuoted()
+				if err != nil {
+					return condition{}, err
+				}
+				c.path = append(c.path, relativeStep{name: name})
+			} else {
+				idx, ok, err := p.parseOptionalInt()
+				if err != nil {
+					return condition{}, err
+				}
+				if !ok {
+					return condition{}, fmt.Errorf("expected index at position %d", p.pos)
+				}
+				c.path = append(c.path, relativeStep{index: idx, isIdx: true})
+			}
+			p.skipSpaces()
+			if !p.consume("]") {
+				return condition{}, fmt.Errorf("expected ] at position %d", p.pos)
+			}
+			continue
+		}
+		break
+	}
+
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			c.op = op
+			break
+		}
+	}
+	if c.op == "" {
+		return c, nil
+	}
+
+	p.skipSpaces()
+	operand, err := p.parseLiteral()
+	if err != nil {
+		return condition{}, err
+	}
+	c.operand = operand
+	return c, nil
+}
+
+func (p *jsonPathParser) parseLiteral() (interface{}, error) {
+	switch {
+	case p.peek() == '\'' || p.peek() == '"':
+		return p.parseQuoted()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	case p.consume("null"):
+		return nil, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) && strings.ContainsRune("+-.0123456789eE", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected literal at position %d", start)
+	}
+	return f, nil
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
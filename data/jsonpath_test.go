@@ -0,0 +1,142 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"name": "home",
+		"living_room": map[string]interface{}{
+			"temp":    "22.5",
+			"battery": "80",
+		},
+		"bedroom": map[string]interface{}{
+			"temp":    "19",
+			"battery": "15",
+		},
+		"sensors": []interface{}{
+			map[string]interface{}{"id": "a", "temp": 20.0, "battery": 10.0},
+			map[string]interface{}{"id": "b", "temp": 21.0, "battery": 90.0},
+			map[string]interface{}{"id": "c", "online": false},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		expr      string
+		wantPaths []string
+	}{
+		{name: "Root", expr: "$", wantPaths: []string{""}},
+		{name: "Child", expr: "$.name", wantPaths: []string{"name"}},
+		{name: "Bracket child", expr: "$['living_room'].temp", wantPaths: []string{"living_room/temp"}},
+		{name: "Wildcard", expr: "$.*.temp", wantPaths: []string{"bedroom/temp", "living_room/temp"}},
+		{name: "Recursive descent", expr: "$..temp", wantPaths: []string{"bedroom/temp", "living_room/temp", "sensors/0/temp", "sensors/1/temp"}},
+		{name: "Index", expr: "$.sensors[1].id", wantPaths: []string{"sensors/1/id"}},
+		{name: "Negative index", expr: "$.sensors[-1].id", wantPaths: []string{"sensors/2/id"}},
+		{name: "Index union", expr: "$.sensors[0,2].id", wantPaths: []string{"sensors/0/id", "sensors/2/id"}},
+		{name: "Slice", expr: "$.sensors[1:].id", wantPaths: []string{"sensors/1/id", "sensors/2/id"}},
+		{name: "Array wildcard", expr: "$.sensors[*].id", wantPaths: []string{"sensors/0/id", "sensors/1/id", "sensors/2/id"}},
+		{name: "Name projection", expr: "$.bedroom['temp','battery']", wantPaths: []string{"bedroom/temp", "bedroom/battery"}},
+		{name: "Filter numeric strings", expr: "$..[?(@.battery<20)]", wantPaths: []string{"bedroom", "sensors/0"}},
+		{name: "Filter string equality", expr: "$.sensors[?(@.id == 'b')].temp", wantPaths: []string{"sensors/1/temp"}},
+		{name: "Filter existence", expr: "$.sensors[?(@.online)].id", wantPaths: []string{"sensors/2/id"}},
+		{name: "Filter boolean", expr: "$.sensors[?(@.online == false)].id", wantPaths: []string{"sensors/2/id"}},
+		{name: "Filter and", expr: "$.sensors[?(@.temp > 19 && @.battery > 50)].id", wantPaths: []string{"sensors/1/id"}},
+		{name: "Filter or", expr: "$.sensors[?(@.id == 'a' || @.id == 'c')].id", wantPaths: []string{"sensors/0/id", "sensors/2/id"}},
+		{name: "No match", expr: "$.missing", wantPaths: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := CompileJSONPath(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileJSONPath(%q) error: %v", tt.expr, err)
+			}
+			matches, err := q.Evaluate(doc)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error: %v", tt.expr, err)
+			}
+			paths := make([]string, 0, len(matches))
+			for _, m := range matches {
+				paths = append(paths, m.Path)
+				if m.Path == "" {
+					continue
+				}
+				value, err := TraverseField(doc, m.Path)
+				if err != nil || !reflect.DeepEqual(value, m.Value) {
+					t.Errorf("match %q does not resolve to its value via TraverseField", m.Path)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("got paths %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestCompileJSONPath_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"temp",
+		"$.",
+		"$[",
+		"$['temp'",
+		"$[?(@.temp < )]",
+		"$[?(temp < 1)]",
+		"$.temp]",
+		"$..a..b..c",
+		"$." + strings.Repeat("a", MaxQueryLength),
+	} {
+		if _, err := CompileJSONPath(expr); err == nil {
+			t.Errorf("CompileJSONPath(%q) expected error", expr)
+		}
+	}
+}
+
+func TestJSONPath_Limits(t *testing.T) {
+	// A document nested deeply enough to blow up repeated recursive descent.
+	deep := map[string]interface{}{}
+	current := deep
+	for i := 0; i < 1600; i++ {
+		next := map[string]interface{}{}
+		current["a"] = next
+		current = next
+	}
+
+	q, err := CompileJSONPath("$..*..*")
+	if err != nil {
+		t.Fatalf("CompileJSONPath error: %v", err)
+	}
+	matches, err := q.Evaluate(deep)
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if len(matches) != 1599 {
+		t.Errorf("Expected each nested node to match once, got %d matches", len(matches))
+	}
+
+	wide := make(map[string]interface{}, MaxQueryNodes)
+	for i := 0; i < MaxQueryNodes; i++ {
+		wide[strconv.Itoa(i)] = "1"
+	}
+	q, _ = CompileJSONPath("$..*")
+	if _, err := q.Evaluate(wide); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected validation error for an oversized evaluation, got %v", err)
+	}
+
+	// Overlapping descendant sets are visited once.
+	doc := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "1"}}}
+	q, _ = CompileJSONPath("$..*..c")
+	matches, err = q.Evaluate(doc)
+	if err != nil {
+		t.Fatalf("Evaluate error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "a/b/c" {
+		t.Errorf("Expected the single match a/b/c, got %v", matches)
+	}
+}
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UploadKeyTag is the line protocol tag that carries the upload key if it is
+// not given otherwise. It is never part of a value path.
+const UploadKeyTag = "upload_key"
+
+// Point is a single line of the InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Field values are float64 (1.5), int64 (1i), uint64 (1u), bool (t, false,
+// ...) or string ("text").
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	// Timestamp is the optional timestamp of the line, 0 if absent. The
+	// precision depends on the sender.
+	Timestamp int64
+}
+
+// ParseLineProtocol parses one line of the InfluxDB line protocol.
+func ParseLineProtocol(line string) (Point, error) {
+	sections := splitUnescaped(strings.TrimSpace(line), ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return Point{}, fmt.Errorf("invalid line protocol %q: expected measurement, fields and optional timestamp", line)
+	}
+
+	p := Point{Tags: make(map[string]string), Fields: make(map[string]interface{})}
+
+	series := splitUnescaped(sections[0], ',', false)
+	p.Measurement = unescapeLineProtocol(series[0])
+	if p.Measurement == "" {
+		return Point{}, fmt.Errorf("invalid line protocol %q: missing measurement", line)
+	}
+	for _, tag := range series[1:] {
+		key, value, ok := cutUnescaped(tag, '=')
+		if !ok || key == "" || value == "" {
+			return Point{}, fmt.Errorf("invalid line protocol %q: invalid tag %q", line, tag)
+		}
+		p.Tags[unescapeLineProtocol(key)] = unescapeLineProtocol(value)
+	}
+
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		key, raw, ok := cutUnescaped(field, '=')
+		if !ok || key == "" {
+			return Point{}, fmt.Errorf("invalid line protocol %q: invalid field %q", line, field)
+		}
+		value, err := parseFieldValue(raw)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid line protocol %q: field %q: %w", line, key, err)
+		}
+		p.Fields[unescapeLineProtocol(key)] = value
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid line protocol %q: invalid timestamp %q", line, sections[2])
+		}
+		p.Timestamp = ts
+	}
+	return p, nil
+}
+
+// Path returns the value path of p: the measurement followed by the values of
+// all tags in the order of their keys. Tags named in exclude are skipped.
+func (p Point) Path(exclude ...string) string {
+	keys := make([]string, 0, len(p.Tags))
+	for key := range p.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	segments := []string{p.Measurement}
+	for _, key := range keys {
+		if !slices.Contains(exclude, key) {
+			segments = append(segments, p.Tags[key])
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func parseFieldValue(raw string) (interface{}, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case raw[0] == '"':
+		if len(raw) < 2 || raw[len(raw)-1] != '"' {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return unescapeFieldString(raw[1 : len(raw)-1]), nil
+	case strings.HasSuffix(raw, "i"):
+		return strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+	case strings.HasSuffix(raw, "u"):
+		return strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", raw)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("invalid value %s", raw)
+	}
+	return f, nil
+}
+
+// splitUnescaped splits s at every sep that is not escaped with a backslash
+// and, if quotes is set, not inside a double-quoted string.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped cuts s around the first sep that is not escaped with a
+// backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescapeFieldString removes the backslash in front of escaped quotes and
+// backslashes of a string field value.
+func unescapeFieldString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeLineProtocol removes the backslash in front of escaped commas,
+// equal signs and spaces of measurements, tags and field keys.
+func unescapeLineProtocol(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(", =", s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
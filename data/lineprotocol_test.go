@@ -0,0 +1,90 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Point
+	}{
+		{
+			name: "measurement and field",
+			line: "weather temp=21.5",
+			want: Point{Measurement: "weather", Tags: map[string]string{}, Fields: map[string]interface{}{"temp": 21.5}},
+		},
+		{
+			name: "tags, typed fields and timestamp",
+			line: `weather,location=garden,floor=0 temp=21.5,count=3i,total=7u,ok=t,broken=FALSE,label="hi there" 1700000000000000000`,
+			want: Point{
+				Measurement: "weather",
+				Tags:        map[string]string{"location": "garden", "floor": "0"},
+				Fields: map[string]interface{}{
+					"temp": 21.5, "count": int64(3), "total": uint64(7), "ok": true, "broken": false, "label": "hi there",
+				},
+				Timestamp: 1700000000000000000,
+			},
+		},
+		{
+			name: "escapes",
+			line: `my\ room,tag\,key=a\=b field\ key="say \"hi\", \\o/"`,
+			want: Point{
+				Measurement: "my room",
+				Tags:        map[string]string{"tag,key": "a=b"},
+				Fields:      map[string]interface{}{"field key": `say "hi", \o/`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLineProtocol(tt.line)
+			if err != nil {
+				t.Fatalf("ParseLineProtocol() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLineProtocol() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineProtocolErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"weather",
+		",tag=a temp=1",
+		"weather,tag temp=1",
+		"weather temp",
+		"weather temp=",
+		"weather temp=abc",
+		"weather temp=NaN",
+		`weather temp="open`,
+		"weather temp=1x",
+		"weather temp=1.5i",
+		"weather temp=1 now",
+		"weather temp=1 1 2",
+	}
+
+	for _, line := range tests {
+		t.Run(line, func(t *testing.T) {
+			if _, err := ParseLineProtocol(line); err == nil {
+				t.Errorf("ParseLineProtocol(%q) expected error", line)
+			}
+		})
+	}
+}
+
+func TestPointPath(t *testing.T) {
+	p := Point{Measurement: "weather", Tags: map[string]string{"room": "kitchen", "floor": "1", "upload_key": "secret"}}
+
+	if got := p.Path("upload_key"); got != "weather/1/kitchen" {
+		t.Errorf("Path() = %q, want weather/1/kitchen", got)
+	}
+	if got := (Point{Measurement: "weather"}).Path(); got != "weather" {
+		t.Errorf("Path() = %q, want weather", got)
+	}
+}
@@ -1,38 +1,163 @@
 package data
 
-import "strings"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
-// MergeDataAtPath merges newData into existingData at the specified path.
+// ArrayMergeMode controls how an array in new data is combined with an array
+// already stored under the same key.
+type ArrayMergeMode string
+
+const (
+	// ArrayMergeReplace replaces the stored array. This is the default.
+	ArrayMergeReplace ArrayMergeMode = "replace"
+	// ArrayMergeAppend appends the new elements to the stored array.
+	ArrayMergeAppend ArrayMergeMode = "append"
+	// ArrayMergeIndex merges element by element; objects at the same index
+	// are merged recursively and surplus new elements are appended.
+	ArrayMergeIndex ArrayMergeMode = "index"
+)
+
+// MergeOptions configures MergeDataAtPathWithOptions.
+type MergeOptions struct {
+	Arrays ArrayMergeMode
+}
+
+// ParseArrayMergeMode converts a request parameter into an ArrayMergeMode.
+// An empty string yields ArrayMergeReplace.
+func ParseArrayMergeMode(s string) (ArrayMergeMode, error) {
+	switch mode := ArrayMergeMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ArrayMergeReplace, nil
+	case ArrayMergeReplace, ArrayMergeAppend, ArrayMergeIndex:
+		return mode, nil
+	}
+	return "", markError(ErrValidation, fmt.Errorf("unknown array merge mode %q (expected replace, append or index)", s))
+}
+
+// MergeDataAtPath merges newData into existingData at the specified path
+// using the default MergeOptions.
 // If path is empty, merges at root level.
 // Path segments are separated by "/".
-func MergeDataAtPath(existingData map[string]interface{}, path string, newData map[string]interface{}) {
-	if path == "" {
-		for k, v := range newData {
-			existingData[k] = v
+func MergeDataAtPath(existingData map[string]interface{}, path string, newData map[string]interface{}) error {
+	return MergeDataAtPathWithOptions(existingData, path, newData, MergeOptions{})
+}
+
+// MergeDataAtPathWithOptions merges newData into existingData at the
+// specified path. Missing or non-container path segments are replaced by
+// maps. Segments addressing an existing array must be valid (possibly
+// negative) indexes. Nested maps in newData are merged recursively; arrays
+// are combined according to opts.Arrays.
+func MergeDataAtPathWithOptions(existingData map[string]interface{}, path string, newData map[string]interface{}, opts MergeOptions) error {
+	target := existingData
+	if path != "" {
+		var err error
+		target, err = ensureMapAtPath(existingData, path)
+		if err != nil {
+			return err
 		}
-		return
 	}
 
-	segments := strings.Split(path, "/")
+	mergeMaps(target, newData, opts)
+	return nil
+}
 
-	current := existingData
-	for i, segment := range segments {
-		if i == len(segments)-1 {
-			existingMap, ok := current[segment].(map[string]interface{})
-			if !ok {
-				existingMap = make(map[string]interface{})
+// ensureMapAtPath walks path from root, creating maps where a segment is
+// missing or holds a scalar, and returns the map at the end of the path.
+// Empty segments, e.g. from "a//b" or a trailing slash, are rejected.
+func ensureMapAtPath(root map[string]interface{}, path string) (map[string]interface{}, error) {
+	var current interface{} = root
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s' (empty segment)", path))
+		}
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next := c[segment]
+			if !isContainer(next) {
+				next = make(map[string]interface{})
+				c[segment] = next
 			}
-			for k, v := range newData {
-				existingMap[k] = v
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s' (segment '%s' must be an array index)", path, segment))
 			}
-			current[segment] = existingMap
-		} else {
-			nextMap, ok := current[segment].(map[string]interface{})
+			i, ok := resolveIndex(idx, len(c))
 			if !ok {
-				nextMap = make(map[string]interface{})
-				current[segment] = nextMap
+				return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s' (array index %d out of range)", path, idx))
+			}
+			if !isContainer(c[i]) {
+				c[i] = make(map[string]interface{})
+			}
+			current = c[i]
+		}
+	}
+
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s' (points to an array, add an index to address an element)", path))
+	}
+	return m, nil
+}
+
+// mergeMaps merges src into dst. Maps present on both sides are merged
+// recursively, arrays according to opts.Arrays; everything else in src
+// overwrites dst.
+func mergeMaps(dst, src map[string]interface{}, opts MergeOptions) {
+	for k, v := range src {
+		switch sv := v.(type) {
+		case map[string]interface{}:
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeMaps(dm, sv, opts)
+				continue
+			}
+		case []interface{}:
+			if da, ok := dst[k].([]interface{}); ok {
+				dst[k] = mergeArrays(da, sv, opts)
+				continue
 			}
-			current = nextMap
 		}
+		dst[k] = v
+	}
+}
+
+// mergeArrays combines a stored array with a new one according to
+// opts.Arrays.
+func mergeArrays(dst, src []interface{}, opts MergeOptions) []interface{} {
+	switch opts.Arrays {
+	case ArrayMergeAppend:
+		merged := make([]interface{}, 0, len(dst)+len(src))
+		merged = append(merged, dst...)
+		return append(merged, src...)
+	case ArrayMergeIndex:
+		for i, v := range src {
+			if i >= len(dst) {
+				dst = append(dst, v)
+				continue
+			}
+			dm, dstIsMap := dst[i].(map[string]interface{})
+			sm, srcIsMap := v.(map[string]interface{})
+			if dstIsMap && srcIsMap {
+				mergeMaps(dm, sm, opts)
+				continue
+			}
+			dst[i] = v
+		}
+		return dst
+	default:
+		return src
+	}
+}
+
+// isContainer reports whether v is a map or an array.
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
 	}
+	return false
 }
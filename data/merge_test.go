@@ -152,3 +152,145 @@ func TestMergeDataAtPath(t *testing.T) {
 		}
 	})
 }
+
+func TestMergeDataAtPathWithOptions_Arrays(t *testing.T) {
+	existing := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sensors": []interface{}{
+				map[string]interface{}{"temp": "20", "id": "a"},
+				map[string]interface{}{"temp": "21", "id": "b"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		newData map[string]interface{}
+		mode    ArrayMergeMode
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "Replace array",
+			newData: map[string]interface{}{"sensors": []interface{}{"x"}},
+			mode:    ArrayMergeReplace,
+			want:    map[string]interface{}{"sensors": []interface{}{"x"}},
+		},
+		{
+			name:    "Append array",
+			newData: map[string]interface{}{"sensors": []interface{}{"x"}},
+			mode:    ArrayMergeAppend,
+			want: map[string]interface{}{"sensors": []interface{}{
+				map[string]interface{}{"temp": "20", "id": "a"},
+				map[string]interface{}{"temp": "21", "id": "b"},
+				"x",
+			}},
+		},
+		{
+			name: "Merge by index",
+			newData: map[string]interface{}{"sensors": []interface{}{
+				map[string]interface{}{"temp": "25"},
+				"y",
+				"z",
+			}},
+			mode: ArrayMergeIndex,
+			want: map[string]interface{}{"sensors": []interface{}{
+				map[string]interface{}{"temp": "25", "id": "a"},
+				"y",
+				"z",
+			}},
+		},
+		{
+			name:    "Path through array index",
+			path:    "sensors/1",
+			newData: map[string]interface{}{"humidity": "40"},
+			want: map[string]interface{}{"sensors": []interface{}{
+				map[string]interface{}{"temp": "20", "id": "a"},
+				map[string]interface{}{"temp": "21", "id": "b", "humidity": "40"},
+			}},
+		},
+		{
+			name:    "Path through negative index",
+			path:    "sensors/-2",
+			newData: map[string]interface{}{"humidity": "40"},
+			want: map[string]interface{}{"sensors": []interface{}{
+				map[string]interface{}{"temp": "20", "id": "a", "humidity": "40"},
+				map[string]interface{}{"temp": "21", "id": "b"},
+			}},
+		},
+		{
+			name:    "Index out of range",
+			path:    "sensors/2",
+			newData: map[string]interface{}{"humidity": "40"},
+			wantErr: true,
+		},
+		{
+			name:    "Non-numeric segment in array",
+			path:    "sensors/first",
+			newData: map[string]interface{}{"humidity": "40"},
+			wantErr: true,
+		},
+		{
+			name:    "Path ends at array",
+			path:    "sensors",
+			newData: map[string]interface{}{"humidity": "40"},
+			wantErr: true,
+		},
+		{
+			name:    "Trailing slash",
+			path:    "room/",
+			newData: map[string]interface{}{"humidity": "40"},
+			wantErr: true,
+		},
+		{
+			name:    "Empty segment",
+			path:    "room//sensor",
+			newData: map[string]interface{}{"humidity": "40"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := existing()
+			err := MergeDataAtPathWithOptions(got, tt.path, tt.newData, MergeOptions{Arrays: tt.mode})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArrayMergeMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ArrayMergeMode
+		wantErr bool
+	}{
+		{in: "", want: ArrayMergeReplace},
+		{in: "replace", want: ArrayMergeReplace},
+		{in: "Append", want: ArrayMergeAppend},
+		{in: "index", want: ArrayMergeIndex},
+		{in: "concat", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseArrayMergeMode(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseArrayMergeMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseArrayMergeMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sources identify the frontend that performed a write. They are recorded in
+// the document metadata.
+const (
+	SourceHTTP = "http"
+	SourceMCP  = "mcp"
+	SourceCoAP = "coap"
+	SourceUDP  = "udp"
+	SourceTCP  = "tcp"
+	SourceGRPC = "grpc"
+)
+
+// metaKeySuffix is appended to a download key to form the storage key of the
+// document's metadata record.
+const metaKeySuffix = ":meta"
+
+// Meta holds server-generated information about a stored document. It is
+// kept in a separate storage record so it never collides with user values.
+type Meta struct {
+	UpdatedAt        string            `json:"updated_at,omitempty"`
+	PerPathUpdatedAt map[string]string `json:"per_path_updated_at,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	TTLSeconds       int64             `json:"ttl_seconds,omitempty"`
+	WriteCount       int64             `json:"write_count"`
+	Source           string            `json:"source,omitempty"`
+}
+
+// Document is the v2 document format, which separates user values from
+// server-generated metadata.
+type Document struct {
+	Values map[string]interface{} `json:"values"`
+	Meta   Meta                   `json:"meta"`
+}
+
+type sourceContextKey struct{}
+
+// WithSource returns a copy of ctx that records source as the frontend
+// performing writes through the Service.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceContextKey{}, source)
+}
+
+// SourceFromContext returns the source recorded by WithSource, or an empty
+// string if none was set.
+func SourceFromContext(ctx context.Context) string {
+	source, _ := ctx.Value(sourceContextKey{}).(string)
+	return source
+}
+
+// DownloadDocument retrieves the stored values for the given download key
+// together with their metadata in the v2 document format. The
+// server-generated timestamps of the flat format are left out of the values.
+func (s *Service) DownloadDocument(ctx context.Context, downloadKey string) (Document, error) {
+	downloadKey, err := storageDownloadKey(downloadKey)
+	if err != nil {
+		return Document{}, err
+	}
+	jsonData, expiresAt, err := s.StorageInstance.GetJSONWithExpiry(ctx, downloadKey)
+	if err != nil {
+		return Document{}, markNotFound(fmt.Errorf("invalid download key or data not found: %w", err))
+	}
+
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &values); err != nil {
+		return Document{}, fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	meta, err := s.loadMeta(ctx, downloadKey)
+	if err != nil {
+		return Document{}, err
+	}
+	stripServerTimestamps(values, "", meta.userValue)
+	if !expiresAt.IsZero() {
+		meta.ExpiresAt = expiresAt.Format(time.RFC3339)
+		meta.TTLSeconds = RemainingTTLSeconds(expiresAt, time.Now())
+	}
+
+	return Document{Values: values, Meta: meta}, nil
+}
+
+// DownloadMeta retrieves the metadata record for the given download key.
+// Records written before metadata existed yield an empty Meta.
+func (s *Service) DownloadMeta(ctx context.Context, downloadKey string) (Meta, error) {
+	downloadKey, err := storageDownloadKey(downloadKey)
+	if err != nil {
+		return Meta{}, err
+	}
+	return s.loadMeta(ctx, downloadKey)
+}
+
+// loadMeta retrieves the metadata record for downloadKey. A missing record
+// yields an empty Meta.
+func (s *Service) loadMeta(ctx context.Context, downloadKey string) (Meta, error) {
+	var meta Meta
+	raw, err := s.StorageInstance.Retrieve(ctx, metaKey(downloadKey))
+	if err != nil {
+		return meta, fmt.Errorf("error retrieving metadata: %w", err)
+	}
+	if err := convertMap(raw, &meta); err != nil {
+		return meta, fmt.Errorf("error decoding metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// applyWrite records a write of writtenKeys at path by source.
+func (m *Meta) applyWrite(source, path string, writtenKeys []string, replace bool, now time.Time) {
+	if replace || m.PerPathUpdatedAt == nil {
+		m.PerPathUpdatedAt = make(map[string]string, len(writtenKeys))
+	}
+	ts := now.UTC().Format(time.RFC3339)
+	m.UpdatedAt = ts
+	for _, k := range writtenKeys {
+		m.PerPathUpdatedAt[joinPath(path, k)] = ts
+	}
+	m.WriteCount++
+	m.Source = source
+	m.ExpiresAt = ""
+	m.TTLSeconds = 0
+}
+
+// userValue reports whether the value at path was written by a user: path or
+// one of its parents is a written path. Server-generated timestamps never
+// replace such values.
+func (m *Meta) userValue(path string) bool {
+	for {
+		if _, ok := m.PerPathUpdatedAt[path]; ok {
+			return true
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+// IsServerTimestamp reports whether the field at path holds a
+// server-generated timestamp: it is named "timestamp" or "<key>_timestamp"
+// and is not a user value. Without metadata, e.g. for records written before
+// it existed, every field with such a name counts as a server timestamp.
+func (m *Meta) IsServerTimestamp(path string) bool {
+	return isTimestampName(path[strings.LastIndex(path, "/")+1:]) && !m.userValue(path)
+}
+
+// pathUpdatedAt returns the time of the last write to the value at path or
+// to the nearest enclosing path that was written as a whole.
+func (m *Meta) pathUpdatedAt(path string) (time.Time, bool) {
+	for {
+		if ts, ok := m.PerPathUpdatedAt[path]; ok {
+			return parseTimestamp(ts)
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return time.Time{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// stripServerTimestamps removes the server-generated timestamps below prefix
+// from doc, i.e. all "timestamp" and "<key>_timestamp" fields that are not
+// user values.
+func stripServerTimestamps(doc map[string]interface{}, prefix string, userValue func(path string) bool) {
+	for k, v := range doc {
+		path := joinPath(prefix, k)
+		if isTimestampName(k) && !userValue(path) {
+			delete(doc, k)
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			stripServerTimestamps(m, path, userValue)
+		}
+	}
+}
+
+// toMap encodes the metadata for storage.
+func (m Meta) toMap() (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	if err := convertMap(m, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// RemainingTTLSeconds returns the whole seconds between now and expiresAt,
+// never less than zero.
+func RemainingTTLSeconds(expiresAt, now time.Time) int64 {
+	remaining := int64(expiresAt.Sub(now) / time.Second)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// metaKey returns the storage key of the metadata record for downloadKey.
+func metaKey(downloadKey string) string {
+	return downloadKey + metaKeySuffix
+}
+
+// joinPath appends key to the slash-separated path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+// convertMap converts between structs and generic maps via JSON.
+func convertMap(from interface{}, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestDownloadDocument(t *testing.T) {
+	svc, _ := newTestService()
+	svc.TimestampMode = TimestampModeNone
+	ctx := WithSource(context.Background(), SourceHTTP)
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"timestamp": "sensor-value"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.Patch(WithSource(ctx, SourceMCP), uploadKey, "room1", map[string]string{"temp": "21"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	doc, err := svc.DownloadDocument(ctx, domain.AddDownloadPrefix(downloadKey))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if doc.Values["timestamp"] != "sensor-value" {
+		t.Errorf("Expected user value 'timestamp' to be kept, got %v", doc.Values["timestamp"])
+	}
+	if doc.Meta.WriteCount != 2 {
+		t.Errorf("Expected write_count 2, got %d", doc.Meta.WriteCount)
+	}
+	if doc.Meta.Source != SourceMCP {
+		t.Errorf("Expected source %q, got %q", SourceMCP, doc.Meta.Source)
+	}
+	if doc.Meta.UpdatedAt == "" || doc.Meta.ExpiresAt == "" {
+		t.Errorf("Expected updated_at and expires_at to be set, got %+v", doc.Meta)
+	}
+	for _, p := range []string{"timestamp", "room1/temp"} {
+		if doc.Meta.PerPathUpdatedAt[p] == "" {
+			t.Errorf("Expected per_path_updated_at for %q, got %v", p, doc.Meta.PerPathUpdatedAt)
+		}
+	}
+
+	// A full upload replaces the per-path times
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"hum": "40"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	doc, _ = svc.DownloadDocument(ctx, downloadKey)
+	if len(doc.Meta.PerPathUpdatedAt) != 1 || doc.Meta.PerPathUpdatedAt["hum"] == "" {
+		t.Errorf("Expected only hum in per_path_updated_at, got %v", doc.Meta.PerPathUpdatedAt)
+	}
+	if doc.Meta.WriteCount != 3 {
+		t.Errorf("Expected write_count 3, got %d", doc.Meta.WriteCount)
+	}
+}
+
+func TestDownloadDocument_DeleteRemovesMeta(t *testing.T) {
+	svc, si := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, _ := svc.Upload(ctx, uploadKey, map[string]string{"temp": "1"})
+
+	if _, err := svc.Delete(ctx, uploadKey); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := svc.DownloadDocument(ctx, downloadKey); err == nil {
+		t.Error("Expected error after delete")
+	}
+	if _, err := si.GetJSON(ctx, metaKey(downloadKey)); err == nil {
+		t.Error("Expected metadata record to be deleted")
+	}
+}
+
+func TestDownloadDocument_UserTimestamp(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, stored, err := svc.Upload(ctx, uploadKey, map[string]string{"timestamp": "foo", "temp": "21"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored["timestamp"] != "foo" {
+		t.Errorf("Expected user value 'timestamp' not to be overwritten, got %v", stored["timestamp"])
+	}
+	if stored["temp_timestamp"] == nil {
+		t.Error("Expected server timestamp temp_timestamp to be set")
+	}
+
+	// Later writes keep the user value as well
+	if _, _, err := svc.Patch(ctx, uploadKey, "", map[string]string{"hum": "40"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.Patch(ctx, uploadKey, "room1", map[string]string{"temp": "19"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	doc, err := svc.DownloadDocument(ctx, downloadKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := map[string]interface{}{
+		"timestamp": "foo",
+		"temp":      "21",
+		"hum":       "40",
+		"room1":     map[string]interface{}{"temp": "19"},
+	}
+	if !reflect.DeepEqual(doc.Values, want) {
+		t.Errorf("Expected values %v without server timestamps, got %v", want, doc.Values)
+	}
+
+	// A full upload without the user value hands the field back to the server
+	_, stored, _ = svc.Upload(ctx, uploadKey, map[string]string{"temp": "22"})
+	if stored["timestamp"] == "foo" {
+		t.Error("Expected server timestamp after the user value was replaced")
+	}
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"sync"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+// changeBroker fans out change notifications per download key. The zero
+// value is ready to use.
+type changeBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+// Subscribe registers interest in writes to downloadKey. The returned channel
+// receives a value after each Upload, Patch, batch write or Delete of the key.
+// Notifications are coalesced: a subscriber that has not yet consumed the
+// previous notification does not block writers and receives a single value.
+// cancel must be called to release the subscription.
+func (s *Service) Subscribe(downloadKey string) (changes <-chan struct{}, cancel func()) {
+	return s.changes.subscribe(domain.StripDownloadPrefix(downloadKey))
+}
+
+// notifyChange wakes all subscribers of downloadKey.
+func (s *Service) notifyChange(downloadKey string) {
+	s.changes.notify(downloadKey)
+}
+
+func (b *changeBroker) subscribe(downloadKey string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[string]map[chan struct{}]struct{})
+	}
+	if b.subscribers[downloadKey] == nil {
+		b.subscribers[downloadKey] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[downloadKey][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[downloadKey], ch)
+			if len(b.subscribers[downloadKey]) == 0 {
+				delete(b.subscribers, downloadKey)
+			}
+		})
+	}
+	return ch, cancel
+}
+
+func (b *changeBroker) notify(downloadKey string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[downloadKey] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
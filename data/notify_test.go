@@ -0,0 +1,103 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestSubscribe(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	otherKey := domain.GenerateRandomKey()
+
+	changes, cancel := svc.Subscribe(domain.AddDownloadPrefix(downloadKey))
+	defer cancel()
+
+	received := func() bool {
+		select {
+		case <-changes:
+			return true
+		default:
+			return false
+		}
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		want  bool
+	}{
+		{"upload", func() error {
+			_, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "1"})
+			return err
+		}, true},
+		{"patch", func() error {
+			_, _, err := svc.Patch(ctx, uploadKey, "room", map[string]string{"temp": "2"})
+			return err
+		}, true},
+		{"batch", func() error {
+			_, err := svc.UploadMany(ctx, []WriteOperation{{UploadKey: uploadKey, Values: map[string]interface{}{"temp": 3}}})
+			return err
+		}, true},
+		{"other key", func() error {
+			_, _, err := svc.Upload(ctx, otherKey, map[string]string{"temp": "4"})
+			return err
+		}, false},
+		{"touch", func() error {
+			_, _, err := svc.Touch(ctx, uploadKey)
+			return err
+		}, false},
+		{"delete", func() error {
+			_, err := svc.Delete(ctx, uploadKey)
+			return err
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			if got := received(); got != tt.want {
+				t.Errorf("notification received = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeCoalescesAndCancels(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+
+	changes, cancel := svc.Subscribe(downloadKey)
+	for i := 0; i < 3; i++ {
+		if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "1"}); err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+	}
+	<-changes
+	select {
+	case <-changes:
+		t.Error("expected notifications to be coalesced")
+	default:
+	}
+
+	cancel()
+	cancel()
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "2"}); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	select {
+	case <-changes:
+		t.Error("expected no notification after cancel")
+	default:
+	}
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 9457 problem details body shared by the frontends. Code
+// is one of the stable error codes; Type is derived from it and documented
+// at that path, see LookupProblemType.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// MediaTypeProblem is the media type of error responses (RFC 9457).
+const MediaTypeProblem = "application/problem+json"
+
+// ProblemTypePath is the path of the problem type documentation. The type
+// of a problem is ProblemTypePath followed by its code, a relative reference
+// that resolves to the server serving the documentation.
+const ProblemTypePath = "/problems/"
+
+// problemKinds maps error codes to their HTTP status, title and
+// documentation.
+var problemKinds = map[string]struct {
+	status      int
+	title       string
+	description string
+}{
+	CodeNotFound:           {http.StatusNotFound, "Not found", "No data is stored for the download key, or the value path does not exist. Data expires after the retention period."},
+	CodeInvalidKey:         {http.StatusBadRequest, "Invalid key", "The upload or download key is not a 256 bit hex string."},
+	CodeValidation:         {http.StatusBadRequest, "Invalid request", "A path, query, parameter or request body is invalid. The detail names the problem."},
+	CodeTooLarge:           {http.StatusRequestEntityTooLarge, "Request too large", "The request body exceeds the size limit of the server."},
+	CodeClientCertRequired: {http.StatusForbidden, "Client certificate required", "The server accepts writes only with a TLS client certificate signed by its client CA."},
+	CodeMethodNotAllowed:   {http.StatusMethodNotAllowed, "Method not allowed", "The route accepts writes only with the methods in the Allow header, GET writes are disabled in this route tree."},
+	CodeStorageDegraded:    {http.StatusServiceUnavailable, "Storage degraded", "Writes are rejected after a storage timeout until the storage recovers. Retry after the Retry-After delay."},
+	CodeStorageTimeout:     {http.StatusServiceUnavailable, "Storage timeout", "A storage operation timed out. Retry after the Retry-After delay."},
+	CodeInternal:           {http.StatusInternalServerError, "Internal error", "An unexpected server error occurred."},
+}
+
+// ProblemType documents an error code at its problem type URI.
+type ProblemType struct {
+	Code        string
+	Title       string
+	Status      int
+	Description string
+}
+
+// LookupProblemType returns the documentation of code. It reports false for
+// unknown codes.
+func LookupProblemType(code string) (ProblemType, bool) {
+	kind, ok := problemKinds[code]
+	if !ok {
+		return ProblemType{}, false
+	}
+	return ProblemType{Code: code, Title: kind.title, Status: kind.status, Description: kind.description}, true
+}
+
+// NewProblem returns the problem for code with the given detail. Unknown
+// codes yield an internal problem.
+func NewProblem(code, detail string) Problem {
+	kind, ok := problemKinds[code]
+	if !ok {
+		code, kind = CodeInternal, problemKinds[CodeInternal]
+	}
+	return Problem{
+		Type:   ProblemTypePath + code,
+		Title:  kind.title,
+		Status: kind.status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WriteProblem writes p as the response to r, with Retry-After for temporary
+// storage failures.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Instance = r.URL.Path
+	if p.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "10")
+	}
+	w.Header().Set("Content-Type", MediaTypeProblem)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// ProblemFor returns the problem matching err. The detail is the error
+// message for client errors and the given detail otherwise, so storage
+// internals are not exposed.
+func ProblemFor(err error, detail string) Problem {
+	code := ErrorCode(err)
+	switch code {
+	case CodeInvalidKey, CodeValidation:
+		detail = err.Error()
+	}
+	return NewProblem(code, detail)
+}
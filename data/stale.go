@@ -0,0 +1,166 @@
+package data
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStaleAfter is the maximum age of a value before it is reported as
+// stale when neither the Service nor the caller configures a max age.
+const DefaultStaleAfter = 1 * time.Hour
+
+const (
+	// TimestampField is the server-generated field holding the time of the
+	// last write to a map level.
+	TimestampField = "timestamp"
+	// TimestampSuffix is appended to a field name to hold the time of the
+	// last write to that field.
+	TimestampSuffix = "_timestamp"
+)
+
+// PathStatus describes the freshness of a single value path.
+type PathStatus struct {
+	Path       string `json:"path"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Stale      bool   `json:"stale"`
+}
+
+// StatusReport summarizes the freshness of all value paths of a document.
+type StatusReport struct {
+	Stale      bool         `json:"stale"`
+	MaxAge     string       `json:"max_age"`
+	CheckedAt  string       `json:"checked_at"`
+	UpdatedAt  string       `json:"updated_at,omitempty"`
+	Paths      []PathStatus `json:"paths"`
+	StalePaths []string     `json:"stale_paths"`
+}
+
+// BuildStatusReport reports which value paths of a stored document have not
+// been updated within maxAge.
+//
+// The last update of a value is taken from meta, the time of the last write
+// to the value or an enclosing map. Values meta does not know, e.g. in
+// records written before metadata existed, fall back to the
+// server-generated timestamps of the document: the "<key>_timestamp"
+// sibling if present, otherwise the nearest "timestamp" field on the same or
+// an enclosing level. Paths without any resolvable time are never stale.
+func BuildStatusReport(doc map[string]interface{}, meta Meta, maxAge time.Duration, now time.Time) StatusReport {
+	report := StatusReport{
+		MaxAge:     maxAge.String(),
+		CheckedAt:  now.UTC().Format(time.RFC3339),
+		Paths:      []PathStatus{},
+		StalePaths: []string{},
+	}
+	if ts, ok := parseTimestamp(meta.UpdatedAt); ok {
+		report.UpdatedAt = ts.UTC().Format(time.RFC3339)
+	} else if ts, ok := parseTimestamp(doc[TimestampField]); ok {
+		report.UpdatedAt = ts.UTC().Format(time.RFC3339)
+	}
+
+	collectPathStatus(doc, "", time.Time{}, &meta, maxAge, now, &report)
+
+	sort.Slice(report.Paths, func(i, j int) bool {
+		return report.Paths[i].Path < report.Paths[j].Path
+	})
+	sort.Strings(report.StalePaths)
+	report.Stale = len(report.StalePaths) > 0
+	return report
+}
+
+func collectPathStatus(m map[string]interface{}, prefix string, inherited time.Time, meta *Meta, maxAge time.Duration, now time.Time, report *StatusReport) {
+	levelTime := inherited
+	if ts, ok := parseTimestamp(m[TimestampField]); ok {
+		levelTime = ts
+	}
+
+	for key, value := range m {
+		path := joinPath(prefix, key)
+		if isTimestampKey(m, key, path, meta) {
+			continue
+		}
+
+		updated := levelTime
+		if ts, ok := parseTimestamp(m[key+TimestampSuffix]); ok {
+			updated = ts
+		}
+
+		collectValueStatus(value, path, updated, meta, maxAge, now, report)
+	}
+}
+
+// collectValueStatus reports a single value at path. Maps and the elements of
+// arrays are inspected recursively; array elements inherit the update time
+// of the array itself.
+func collectValueStatus(value interface{}, path string, updated time.Time, meta *Meta, maxAge time.Duration, now time.Time, report *StatusReport) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		collectPathStatus(v, path, updated, meta, maxAge, now, report)
+		return
+	case []interface{}:
+		for i, elem := range v {
+			collectValueStatus(elem, path+"/"+strconv.Itoa(i), updated, meta, maxAge, now, report)
+		}
+		return
+	}
+
+	if ts, ok := meta.pathUpdatedAt(path); ok {
+		updated = ts
+	}
+	status := PathStatus{Path: path}
+	if !updated.IsZero() {
+		age := now.Sub(updated)
+		status.UpdatedAt = updated.UTC().Format(time.RFC3339)
+		status.AgeSeconds = int64(age / time.Second)
+		status.Stale = maxAge > 0 && age > maxAge
+	}
+	report.Paths = append(report.Paths, status)
+	if status.Stale {
+		report.StalePaths = append(report.StalePaths, path)
+	}
+}
+
+// isTimestampKey reports whether key at path in m holds a server-generated
+// timestamp rather than a user value. With metadata this is decided by the
+// written paths. Without, a "<name>_timestamp" key only counts as such when
+// "<name>" exists on the same level.
+func isTimestampKey(m map[string]interface{}, key, path string, meta *Meta) bool {
+	if len(meta.PerPathUpdatedAt) > 0 {
+		return meta.IsServerTimestamp(path)
+	}
+	if key == TimestampField {
+		return true
+	}
+	if base, ok := strings.CutSuffix(key, TimestampSuffix); ok && base != "" {
+		_, exists := m[base]
+		return exists
+	}
+	return false
+}
+
+// isTimestampName reports whether key is named like a server-generated
+// timestamp.
+func isTimestampName(key string) bool {
+	return key == TimestampField || strings.HasSuffix(key, TimestampSuffix)
+}
+
+// parseTimestamp converts a stored timestamp value into a time.Time. Both
+// RFC3339 strings and Unix epoch milliseconds (see TimestampFormat) are
+// accepted.
+func parseTimestamp(v interface{}) (time.Time, bool) {
+	switch ts := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	case float64:
+		return time.UnixMilli(int64(ts)), true
+	case int64:
+		return time.UnixMilli(ts), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestBuildStatusReport(t *testing.T) {
+	now := time.Date(2026, 1, 16, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	fresh := now.Add(-5 * time.Minute).Format(time.RFC3339)
+
+	doc := map[string]interface{}{
+		"temp":           "21",
+		"temp_timestamp": fresh,
+		"hum":            "40",
+		"hum_timestamp":  old,
+		"boot_timestamp": "custom",
+		"timestamp":      fresh,
+		"cellar": map[string]interface{}{
+			"temp":      "12",
+			"timestamp": old,
+		},
+		"garden": map[string]interface{}{
+			"temp": "18",
+		},
+	}
+
+	report := BuildStatusReport(doc, Meta{}, time.Hour, now)
+
+	wantStale := []string{"cellar/temp", "hum"}
+	if !reflect.DeepEqual(report.StalePaths, wantStale) {
+		t.Errorf("Expected stale paths %v, got %v", wantStale, report.StalePaths)
+	}
+	if !report.Stale {
+		t.Error("Expected report to be stale")
+	}
+	if report.UpdatedAt != fresh {
+		t.Errorf("Expected updated_at %s, got %s", fresh, report.UpdatedAt)
+	}
+
+	var paths []string
+	for _, p := range report.Paths {
+		paths = append(paths, p.Path)
+	}
+	wantPaths := []string{"boot_timestamp", "cellar/temp", "garden/temp", "hum", "temp"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Expected paths %v, got %v", wantPaths, paths)
+	}
+
+	// garden has no own timestamp and inherits the root one
+	for _, p := range report.Paths {
+		if p.Path == "garden/temp" && (p.UpdatedAt != fresh || p.Stale) {
+			t.Errorf("Expected garden/temp to inherit fresh root timestamp, got %+v", p)
+		}
+	}
+}
+
+func TestBuildStatusReport_NoTimestamps(t *testing.T) {
+	report := BuildStatusReport(map[string]interface{}{"temp": "21"}, Meta{}, time.Minute, time.Now())
+
+	if report.Stale {
+		t.Error("Expected values without timestamps to never be stale")
+	}
+	if len(report.Paths) != 1 || report.Paths[0].UpdatedAt != "" {
+		t.Errorf("Expected one path without updated_at, got %+v", report.Paths)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	svc, si := newTestService()
+	ctx := context.Background()
+
+	old := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	si.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{"temp": "23", "timestamp": old})
+
+	t.Run("Default max age", func(t *testing.T) {
+		report, err := svc.Status(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if report.MaxAge != DefaultStaleAfter.String() {
+			t.Errorf("Expected max age %s, got %s", DefaultStaleAfter, report.MaxAge)
+		}
+		if !report.Stale {
+			t.Error("Expected data to be stale")
+		}
+	})
+
+	t.Run("Configured max age", func(t *testing.T) {
+		svc.StaleAfter = 24 * time.Hour
+		defer func() { svc.StaleAfter = 0 }()
+
+		report, err := svc.Status(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if report.Stale {
+			t.Error("Expected data not to be stale with 24h max age")
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		if _, err := svc.Status(ctx, "nonexistent", 0); err == nil {
+			t.Error("Expected error for non-existent key")
+		}
+	})
+}
+
+func TestStatusFromMeta(t *testing.T) {
+	for _, mode := range []TimestampMode{TimestampModeNone, TimestampModeRoot} {
+		t.Run(string(mode), func(t *testing.T) {
+			svc, si := newTestService()
+			svc.TimestampMode = mode
+			ctx := context.Background()
+
+			downloadKey, _, err := svc.UploadValues(ctx, domain.GenerateRandomKey(), map[string]interface{}{
+				"temp": "21",
+				"hum":  "40",
+				"room": map[string]interface{}{"co2": "600"},
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			// hum and room were last written two hours ago.
+			key, _ := storageDownloadKey(downloadKey)
+			meta, err := svc.loadMeta(ctx, key)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+			meta.PerPathUpdatedAt["hum"] = old
+			meta.PerPathUpdatedAt["room"] = old
+			raw, _ := meta.toMap()
+			si.Store(ctx, metaKey(key), raw)
+
+			report, err := svc.Status(ctx, downloadKey, time.Hour)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			wantStale := []string{"hum", "room/co2"}
+			if !reflect.DeepEqual(report.StalePaths, wantStale) {
+				t.Errorf("Expected stale paths %v, got %v", wantStale, report.StalePaths)
+			}
+			if report.UpdatedAt == "" {
+				t.Error("Expected updated_at from the metadata")
+			}
+		})
+	}
+}
+
+func TestStatusKeepsUserTimestamps(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	downloadKey, _, err := svc.UploadValues(ctx, domain.GenerateRandomKey(), map[string]interface{}{
+		"temp":      "21",
+		"timestamp": "1700000000",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report, err := svc.Status(ctx, downloadKey, time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var paths []string
+	for _, p := range report.Paths {
+		paths = append(paths, p.Path)
+	}
+	wantPaths := []string{"temp", "timestamp"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Expected paths %v without server timestamps, got %v", wantPaths, paths)
+	}
+}
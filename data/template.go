@@ -0,0 +1,330 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+// templatesKeySuffix is appended to a download key to form the storage key of
+// the record holding the document's text templates.
+const templatesKeySuffix = ":templates"
+
+// Limits of text templates. Templates are small display formats, e.g.
+// "T:{{.temp}} H:{{.humidity}}" for an LCD.
+const (
+	MaxTemplates        = 16
+	MaxTemplateSize     = 4 << 10
+	MaxRenderedTemplate = 64 << 10
+)
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errRenderedTooLarge aborts template execution once the output exceeds
+// MaxRenderedTemplate.
+var errRenderedTooLarge = fmt.Errorf("rendered template exceeds %d bytes", MaxRenderedTemplate)
+
+// templateFuncs is the function set available to text templates in addition
+// to the text/template builtins. It gives no access to the server or to
+// other keys.
+var templateFuncs = template.FuncMap{
+	"round":   roundValue,
+	"default": defaultValue,
+	"since":   sinceValue,
+}
+
+// ParseTemplate parses source as a text template with the template function
+// set. Templates cannot be cancelled while they execute, so only a subset of
+// text/template is accepted that keeps the run time proportional to the
+// stored data: no define, block or template actions, and range only over
+// stored values and not nested.
+func ParseTemplate(name, source string) (*template.Template, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid template name %q, expected 1 to 64 letters, digits, '-' or '_'", name))
+	}
+	if len(source) > MaxTemplateSize {
+		return nil, markError(ErrValidation, fmt.Errorf("template exceeds %d bytes", MaxTemplateSize))
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid template: %w", err))
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, markError(ErrValidation, errors.New("invalid template: define and block are not supported"))
+	}
+	if err := checkTemplateNode(tmpl.Tree.Root, false, true); err != nil {
+		return nil, markError(ErrValidation, fmt.Errorf("invalid template: %w", err))
+	}
+	return tmpl, nil
+}
+
+// checkTemplateNode rejects the actions that ParseTemplate does not accept in
+// node and its children. inRange is set inside the body of a range action,
+// dotIsData while dot refers to a stored value.
+func checkTemplateNode(node parse.Node, inRange, dotIsData bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateNode(child, inRange, dotIsData); err != nil {
+				return err
+			}
+		}
+	case *parse.TemplateNode:
+		return errors.New("template actions are not supported")
+	case *parse.IfNode:
+		if err := checkTemplateNode(n.List, inRange, dotIsData); err != nil {
+			return err
+		}
+		return checkTemplateNode(n.ElseList, inRange, dotIsData)
+	case *parse.WithNode:
+		if err := checkTemplateNode(n.List, inRange, isDataPipe(n.Pipe, dotIsData)); err != nil {
+			return err
+		}
+		return checkTemplateNode(n.ElseList, inRange, dotIsData)
+	case *parse.RangeNode:
+		if inRange {
+			return errors.New("nested range actions are not supported")
+		}
+		if !isDataPipe(n.Pipe, dotIsData) {
+			return errors.New("range is only supported over stored values, e.g. {{range .sensors}}")
+		}
+		if err := checkTemplateNode(n.List, true, true); err != nil {
+			return err
+		}
+		return checkTemplateNode(n.ElseList, inRange, dotIsData)
+	}
+	return nil
+}
+
+// isDataPipe reports whether pipe only selects a stored value, e.g. ".",
+// ".sensors" or "$.sensors", rather than computing one. dotIsData tells
+// whether dot refers to a stored value.
+func isDataPipe(pipe *parse.PipeNode, dotIsData bool) bool {
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return dotIsData
+	case *parse.FieldNode:
+		return dotIsData
+	case *parse.VariableNode:
+		return arg.Ident[0] == "$"
+	}
+	return false
+}
+
+// SetTemplate validates the upload key and stores source as the text template
+// name of the associated download key, replacing a template of the same
+// name. Templates expire like the data; every write renews them.
+func (s *Service) SetTemplate(ctx context.Context, uploadKey, name, source string) (downloadKey string, err error) {
+	downloadKey, err = templateDownloadKey(uploadKey)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ParseTemplate(name, source); err != nil {
+		return "", err
+	}
+
+	templates, err := s.loadTemplates(ctx, downloadKey)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := templates[name]; !exists && len(templates) >= MaxTemplates {
+		return "", markError(ErrValidation, fmt.Errorf("at most %d templates per key", MaxTemplates))
+	}
+	templates[name] = source
+
+	if err := s.storeTemplates(ctx, downloadKey, templates); err != nil {
+		return "", err
+	}
+	return downloadKey, nil
+}
+
+// DeleteTemplate validates the upload key and deletes the text template name
+// of the associated download key.
+func (s *Service) DeleteTemplate(ctx context.Context, uploadKey, name string) (downloadKey string, err error) {
+	downloadKey, err = templateDownloadKey(uploadKey)
+	if err != nil {
+		return "", err
+	}
+
+	templates, err := s.loadTemplates(ctx, downloadKey)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := templates[name]; !exists {
+		return "", markError(ErrNotFound, fmt.Errorf("template '%s' not found", name))
+	}
+	delete(templates, name)
+
+	if len(templates) == 0 {
+		if err := s.StorageInstance.Delete(ctx, templatesKey(downloadKey)); err != nil {
+			return "", fmt.Errorf("error deleting templates: %w", err)
+		}
+		return downloadKey, nil
+	}
+	if err := s.storeTemplates(ctx, downloadKey, templates); err != nil {
+		return "", err
+	}
+	return downloadKey, nil
+}
+
+// RenderTemplate renders the text template name against the stored data of
+// the given download key. It returns the output together with the time at
+// which the data expires.
+func (s *Service) RenderTemplate(ctx context.Context, downloadKey, name string) ([]byte, time.Time, error) {
+	downloadKey, err := storageDownloadKey(downloadKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	templates, err := s.loadTemplates(ctx, downloadKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	source, ok := templates[name]
+	if !ok {
+		return nil, time.Time{}, markError(ErrNotFound, fmt.Errorf("template '%s' not found", name))
+	}
+	tmpl, err := ParseTemplate(name, source)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	jsonData, expiresAt, err := s.DownloadJSONWithExpiry(ctx, downloadKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, time.Time{}, fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	out := &limitedBuffer{limit: MaxRenderedTemplate}
+	if err := tmpl.Execute(out, doc); err != nil {
+		if errors.Is(err, errRenderedTooLarge) {
+			return nil, time.Time{}, markError(ErrValidation, errRenderedTooLarge)
+		}
+		return nil, time.Time{}, markError(ErrValidation, fmt.Errorf("error rendering template: %w", err))
+	}
+	return out.Bytes(), expiresAt, nil
+}
+
+// loadTemplates retrieves the text templates of downloadKey by name. A
+// missing record yields an empty map.
+func (s *Service) loadTemplates(ctx context.Context, downloadKey string) (map[string]string, error) {
+	raw, err := s.StorageInstance.Retrieve(ctx, templatesKey(downloadKey))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving templates: %w", err)
+	}
+	templates := make(map[string]string, len(raw))
+	for name, v := range raw {
+		if source, ok := v.(string); ok {
+			templates[name] = source
+		}
+	}
+	return templates, nil
+}
+
+// storeTemplates replaces the text templates of downloadKey.
+func (s *Service) storeTemplates(ctx context.Context, downloadKey string, templates map[string]string) error {
+	raw := make(map[string]interface{}, len(templates))
+	for name, source := range templates {
+		raw[name] = source
+	}
+	if err := s.StorageInstance.Store(ctx, templatesKey(downloadKey), raw); err != nil {
+		return fmt.Errorf("error storing templates: %w", err)
+	}
+	return nil
+}
+
+// renewTemplates extends the TTL of the templates of downloadKey after a
+// write, so templates live as long as the data they render. Most keys have
+// no templates.
+func (s *Service) renewTemplates(ctx context.Context, downloadKey string) {
+	if _, err := s.StorageInstance.Touch(ctx, templatesKey(downloadKey)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		slog.Warn("data: failed to extend templates TTL", "error", err)
+	}
+}
+
+// templateDownloadKey validates uploadKey and derives its download key.
+func templateDownloadKey(uploadKey string) (string, error) {
+	if err := domain.ValidateUploadKey(uploadKey); err != nil {
+		return "", markError(ErrInvalidKey, fmt.Errorf("invalid upload key: %w", err))
+	}
+	downloadKey, err := domain.DeriveDownloadKey(uploadKey)
+	if err != nil {
+		return "", fmt.Errorf("error deriving download key: %w", err)
+	}
+	return downloadKey, nil
+}
+
+// templatesKey returns the storage key of the templates record for
+// downloadKey.
+func templatesKey(downloadKey string) string {
+	return downloadKey + templatesKeySuffix
+}
+
+// roundValue formats a number or numeric string with the given number of
+// decimals, e.g. {{.temp | round 1}}.
+func roundValue(decimals int, v interface{}) (string, error) {
+	if decimals < 0 || decimals > 10 {
+		return "", fmt.Errorf("round: decimals must be between 0 and 10, got %d", decimals)
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return "", fmt.Errorf("round: %v is not a number", v)
+	}
+	return strconv.FormatFloat(f, 'f', decimals, 64), nil
+}
+
+// defaultValue returns v, or fallback if v is missing or empty, e.g.
+// {{.temp | default "--"}}.
+func defaultValue(fallback, v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return fallback
+	case string:
+		if val == "" {
+			return fallback
+		}
+	}
+	return v
+}
+
+// sinceValue returns the time elapsed since a stored timestamp in whole
+// seconds, e.g. {{since .timestamp}} renders "5m12s".
+func sinceValue(v interface{}) (string, error) {
+	t, ok := parseTimestamp(v)
+	if !ok {
+		return "", fmt.Errorf("since: %v is not a timestamp", v)
+	}
+	return time.Since(t).Truncate(time.Second).String(), nil
+}
+
+// limitedBuffer is a bytes.Buffer that fails writes beyond limit bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errRenderedTooLarge
+	}
+	return b.Buffer.Write(p)
+}
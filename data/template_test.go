@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.UploadValues(ctx, uploadKey, map[string]interface{}{"temp": "21.46", "humidity": 40.0})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"values", "T:{{.temp}} H:{{.humidity}}", "T:21.46 H:40"},
+		{"round", "T:{{.temp | round 1}}", "T:21.5"},
+		{"default", "P:{{.pressure | default \"--\"}}", "P:--"},
+		{"since", "{{since .temp_timestamp}}", "0s"},
+		{"range", "{{range $k, $v := .}}{{if eq $k \"humidity\"}}{{$v}}{{end}}{{end}}", "40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.SetTemplate(ctx, uploadKey, "lcd", tt.source); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			out, expiresAt, err := svc.RenderTemplate(ctx, domain.AddDownloadPrefix(downloadKey), "lcd")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out)
+			}
+			if expiresAt.IsZero() {
+				t.Error("Expected expiry time to be set")
+			}
+		})
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "warm"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "lcd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown template, got %v", err)
+	}
+
+	if _, err := svc.SetTemplate(ctx, uploadKey, "lcd", "{{.temp | round 1}}"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "lcd"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for non-numeric round, got %v", err)
+	}
+
+	if _, err := svc.SetTemplate(ctx, uploadKey, "big", `{{range .}}{{printf "%900000s" "x"}}{{end}}`); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "big"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected size limit error, got %v", err)
+	}
+}
+
+func TestSetTemplate_Validation(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+	uploadKey := domain.GenerateRandomKey()
+
+	tests := []struct {
+		name      string
+		uploadKey string
+		tmplName  string
+		source    string
+		kind      error
+	}{
+		{"invalid upload key", "invalid", "lcd", "{{.temp}}", ErrInvalidKey},
+		{"invalid name", uploadKey, "../lcd", "{{.temp}}", ErrValidation},
+		{"syntax error", uploadKey, "lcd", "{{.temp", ErrValidation},
+		{"unknown function", uploadKey, "lcd", "{{env \"HOME\"}}", ErrValidation},
+		{"define", uploadKey, "lcd", `{{define "a"}}{{template "a"}}{{end}}`, ErrValidation},
+		{"range over number", uploadKey, "lcd", "{{range 1000000000}}{{end}}", ErrValidation},
+		{"range over variable", uploadKey, "lcd", "{{$n := 1000000000}}{{range $n}}{{end}}", ErrValidation},
+		{"range over dot bound to number", uploadKey, "lcd", "{{with 1000000000}}{{range .}}{{end}}{{end}}", ErrValidation},
+		{"nested range", uploadKey, "lcd", "{{range .a}}{{if .}}{{range $.a}}{{end}}{{end}}{{end}}", ErrValidation},
+		{"too large", uploadKey, "lcd", strings.Repeat("x", MaxTemplateSize+1), ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SetTemplate(ctx, tt.uploadKey, tt.tmplName, tt.source)
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("Expected %v, got %v", tt.kind, err)
+			}
+		})
+	}
+}
+
+func TestDeleteTemplate(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, name := range []string{"lcd", "oled"} {
+		if _, err := svc.SetTemplate(ctx, uploadKey, name, "{{.temp}}"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if _, err := svc.DeleteTemplate(ctx, uploadKey, "lcd"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "lcd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleted template to be gone, got %v", err)
+	}
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "oled"); err != nil {
+		t.Errorf("Expected other template to be kept, got %v", err)
+	}
+	if _, err := svc.DeleteTemplate(ctx, uploadKey, "lcd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for deleted template, got %v", err)
+	}
+
+	// Deleting the data deletes its templates
+	if _, err := svc.Delete(ctx, uploadKey); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.Upload(ctx, uploadKey, map[string]string{"temp": "21"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, _, err := svc.RenderTemplate(ctx, downloadKey, "oled"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected templates to be deleted with the data, got %v", err)
+	}
+}
+
+func TestSinceValue(t *testing.T) {
+	ts := time.Now().Add(-90 * time.Second)
+	for _, v := range []interface{}{ts.Format(time.RFC3339), float64(ts.UnixMilli())} {
+		got, err := sinceValue(v)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got != "1m30s" && got != "1m31s" {
+			t.Errorf("Expected about 1m30s, got %q", got)
+		}
+	}
+	if _, err := sinceValue("yesterday"); err == nil {
+		t.Error("Expected error for invalid timestamp")
+	}
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimestampMode controls which server-generated timestamps the Service adds
+// to a document on every write.
+type TimestampMode string
+
+const (
+	// TimestampModeNone adds no timestamps.
+	TimestampModeNone TimestampMode = "none"
+	// TimestampModeRoot adds a single "timestamp" field at the document root.
+	TimestampModeRoot TimestampMode = "root"
+	// TimestampModePath adds the root "timestamp" and a "timestamp" field in
+	// the map at the written path.
+	TimestampModePath TimestampMode = "path"
+	// TimestampModeField adds everything TimestampModePath adds plus a
+	// "<key>_timestamp" sibling for every written field.
+	TimestampModeField TimestampMode = "field"
+	// TimestampModeLegacy adds what TimestampModeField adds for writes at the
+	// document root and what TimestampModePath adds for writes at a nested
+	// path, the format of earlier versions. This is the default.
+	TimestampModeLegacy TimestampMode = "legacy"
+)
+
+// TimestampFormat controls how server-generated timestamps are encoded.
+type TimestampFormat string
+
+const (
+	// TimestampFormatRFC3339 stores timestamps as RFC3339 strings in UTC.
+	// This is the default.
+	TimestampFormatRFC3339 TimestampFormat = "rfc3339"
+	// TimestampFormatUnixMs stores timestamps as Unix epoch milliseconds.
+	TimestampFormatUnixMs TimestampFormat = "unixms"
+)
+
+// ParseTimestampMode converts a configuration string into a TimestampMode.
+func ParseTimestampMode(s string) (TimestampMode, error) {
+	switch mode := TimestampMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case TimestampModeNone, TimestampModeRoot, TimestampModePath, TimestampModeField, TimestampModeLegacy:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown timestamp mode %q (expected none, root, path, field or legacy)", s)
+}
+
+// ParseTimestampFormat converts a configuration string into a TimestampFormat.
+func ParseTimestampFormat(s string) (TimestampFormat, error) {
+	switch format := TimestampFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case TimestampFormatRFC3339, TimestampFormatUnixMs:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown timestamp format %q (expected rfc3339 or unixms)", s)
+}
+
+// applyTimestamps adds the server-generated timestamps configured by mode to
+// doc after writtenKeys were merged at path. A timestamp is never written to
+// a path for which userValue reports true, so values supplied by the user,
+// e.g. a sensor named "timestamp", are kept. userValue may be nil.
+func applyTimestamps(doc map[string]interface{}, path string, writtenKeys []string, mode TimestampMode, format TimestampFormat, now time.Time, userValue func(path string) bool) {
+	mode = effectiveMode(mode, path)
+	if mode == TimestampModeNone {
+		return
+	}
+	if userValue == nil {
+		userValue = func(string) bool { return false }
+	}
+
+	ts := formatTimestamp(now, format)
+	if !userValue(TimestampField) {
+		doc[TimestampField] = ts
+	}
+	if mode == TimestampModeRoot {
+		return
+	}
+
+	level := mapAtPath(doc, path)
+	if level == nil {
+		return
+	}
+	for _, k := range timestampKeys(mode, writtenKeys) {
+		if !userValue(joinPath(path, k)) {
+			level[k] = ts
+		}
+	}
+}
+
+// TimestampKeys returns the keys of the server-generated timestamps that an
+// Upload or Patch of values at path adds next to the values, e.g.
+// "timestamp" and "temp_timestamp".
+func (s *Service) TimestampKeys(path string, values map[string]interface{}) []string {
+	mode := effectiveMode(s.TimestampMode, path)
+	if mode == TimestampModeRoot {
+		return []string{TimestampField}
+	}
+	return timestampKeys(mode, valueKeys(values))
+}
+
+// effectiveMode resolves the zero value and TimestampModeLegacy for a write
+// at path.
+func effectiveMode(mode TimestampMode, path string) TimestampMode {
+	if mode == "" {
+		mode = TimestampModeLegacy
+	}
+	if mode != TimestampModeLegacy {
+		return mode
+	}
+	if path == "" {
+		return TimestampModeField
+	}
+	return TimestampModePath
+}
+
+// timestampKeys returns the keys mode adds in the map at the written path.
+func timestampKeys(mode TimestampMode, writtenKeys []string) []string {
+	switch mode {
+	case TimestampModePath:
+		return []string{TimestampField}
+	case TimestampModeField:
+		keys := []string{TimestampField}
+		for _, k := range writtenKeys {
+			if k == TimestampField {
+				continue
+			}
+			keys = append(keys, k+TimestampSuffix)
+		}
+		return keys
+	}
+	return nil
+}
+
+// formatTimestamp encodes now according to format.
+func formatTimestamp(now time.Time, format TimestampFormat) interface{} {
+	if format == TimestampFormatUnixMs {
+		return now.UnixMilli()
+	}
+	return now.UTC().Format(time.RFC3339)
+}
+
+// mapAtPath returns the nested map at the slash-separated path, or nil if
+// the path does not lead to a map. An empty path returns doc itself.
+func mapAtPath(doc map[string]interface{}, path string) map[string]interface{} {
+	if path == "" {
+		return doc
+	}
+	value, err := TraverseField(doc, path)
+	if err != nil {
+		return nil
+	}
+	m, _ := value.(map[string]interface{})
+	return m
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestApplyTimestamps(t *testing.T) {
+	now := time.Date(2026, 1, 16, 10, 0, 0, 0, time.UTC)
+	rfc := now.Format(time.RFC3339)
+
+	newDoc := func() map[string]interface{} {
+		return map[string]interface{}{
+			"temp": "21",
+			"room1": map[string]interface{}{
+				"hum": "40",
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mode    TimestampMode
+		path    string
+		keys    []string
+		present []string
+		absent  []string
+	}{
+		{"none", TimestampModeNone, "", []string{"temp"}, nil, []string{"timestamp", "temp_timestamp"}},
+		{"root", TimestampModeRoot, "room1", []string{"hum"}, []string{"timestamp"}, []string{"room1/timestamp", "room1/hum_timestamp"}},
+		{"path", TimestampModePath, "room1", []string{"hum"}, []string{"timestamp", "room1/timestamp"}, []string{"room1/hum_timestamp"}},
+		{"field at root", TimestampModeField, "", []string{"temp"}, []string{"timestamp", "temp_timestamp"}, nil},
+		{"field nested", TimestampModeField, "room1", []string{"hum"}, []string{"timestamp", "room1/timestamp", "room1/hum_timestamp"}, []string{"temp_timestamp"}},
+		{"legacy at root", TimestampModeLegacy, "", []string{"temp"}, []string{"timestamp", "temp_timestamp"}, nil},
+		{"legacy nested", TimestampModeLegacy, "room1", []string{"hum"}, []string{"timestamp", "room1/timestamp"}, []string{"room1/hum_timestamp"}},
+		{"zero value is legacy", "", "room1", []string{"hum"}, []string{"timestamp", "room1/timestamp"}, []string{"room1/hum_timestamp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newDoc()
+			applyTimestamps(doc, tt.path, tt.keys, tt.mode, TimestampFormatRFC3339, now, nil)
+
+			for _, p := range tt.present {
+				v, err := TraverseField(doc, p)
+				if err != nil {
+					t.Errorf("Expected %q to be set: %v", p, err)
+					continue
+				}
+				if v != rfc {
+					t.Errorf("Expected %q to be %s, got %v", p, rfc, v)
+				}
+			}
+			for _, p := range tt.absent {
+				if _, err := TraverseField(doc, p); err == nil {
+					t.Errorf("Expected %q not to be set", p)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTimestamps_UnixMs(t *testing.T) {
+	now := time.Date(2026, 1, 16, 10, 0, 0, 0, time.UTC)
+	doc := map[string]interface{}{"temp": "21"}
+
+	applyTimestamps(doc, "", []string{"temp"}, TimestampModeField, TimestampFormatUnixMs, now, nil)
+
+	if doc["timestamp"] != now.UnixMilli() {
+		t.Errorf("Expected unix ms timestamp %d, got %v", now.UnixMilli(), doc["timestamp"])
+	}
+	if ts, ok := parseTimestamp(float64(now.UnixMilli())); !ok || !ts.Equal(now) {
+		t.Errorf("Expected unix ms timestamp to parse back to %v, got %v", now, ts)
+	}
+}
+
+func TestParseTimestampMode(t *testing.T) {
+	for _, s := range []string{"none", "root", "path", "field", "legacy", " FIELD "} {
+		if _, err := ParseTimestampMode(s); err != nil {
+			t.Errorf("Expected %q to parse, got %v", s, err)
+		}
+	}
+	if _, err := ParseTimestampMode("always"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+	if _, err := ParseTimestampFormat("unixms"); err != nil {
+		t.Errorf("Expected unixms to parse, got %v", err)
+	}
+	if _, err := ParseTimestampFormat("iso"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestUpload_TimestampMode(t *testing.T) {
+	svc, _ := newTestService()
+	svc.TimestampMode = TimestampModeRoot
+	ctx := context.Background()
+
+	_, data, err := svc.Upload(ctx, domain.GenerateRandomKey(), map[string]string{"temp": "23.5"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data["timestamp"] == nil {
+		t.Error("Expected root timestamp to be set")
+	}
+	if _, ok := data["temp_timestamp"]; ok {
+		t.Error("Expected no per-field timestamp in root mode")
+	}
+}
+
+func TestTimestampKeys(t *testing.T) {
+	values := map[string]interface{}{"temp": "21"}
+	tests := []struct {
+		mode TimestampMode
+		path string
+		want []string
+	}{
+		{TimestampModeNone, "", nil},
+		{TimestampModeRoot, "room1", []string{"timestamp"}},
+		{TimestampModePath, "", []string{"timestamp"}},
+		{TimestampModeField, "room1", []string{"timestamp", "temp_timestamp"}},
+		{"", "", []string{"timestamp", "temp_timestamp"}},
+		{"", "room1", []string{"timestamp"}},
+	}
+	for _, tt := range tests {
+		svc := &Service{TimestampMode: tt.mode}
+		if got := svc.TimestampKeys(tt.path, values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TimestampKeys(%q) in mode %q = %v, want %v", tt.path, tt.mode, got, tt.want)
+		}
+	}
+}
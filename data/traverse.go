@@ -2,25 +2,86 @@ package data
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// TraverseField walks a nested map using the given slash-separated field path
-// and returns the value at that location.
+// TraverseField walks a nested structure using the given slash-separated
+// field path and returns the value at that location. Segments addressing an
+// array must be numeric indexes; negative indexes count from the end
+// (e.g. "sensors/-1/temp" is the temp of the last sensor).
 func TraverseField(data map[string]interface{}, fieldPath string) (interface{}, error) {
 	keys := strings.Split(fieldPath, "/")
 	var value interface{} = data
 
 	for _, key := range keys {
-		m, ok := value.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid parameter path: '%s'", fieldPath)
-		}
-		value, ok = m[key]
-		if !ok {
-			return nil, fmt.Errorf("parameter '%s' not found", fieldPath)
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, markError(ErrNotFound, fmt.Errorf("parameter '%s' not found", fieldPath))
+			}
+			value = next
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s'", fieldPath))
+			}
+			i, ok := resolveIndex(idx, len(v))
+			if !ok {
+				return nil, markError(ErrNotFound, fmt.Errorf("parameter '%s' not found", fieldPath))
+			}
+			value = v[i]
+		default:
+			return nil, markError(ErrValidation, fmt.Errorf("invalid parameter path: '%s'", fieldPath))
 		}
 	}
 
 	return value, nil
 }
+
+// resolveIndex converts a possibly negative index into a position within an
+// array of the given length.
+func resolveIndex(idx, length int) (int, bool) {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return 0, false
+	}
+	return idx, true
+}
+
+// CollectPaths recursively collects the paths of all leaf values in a nested
+// structure. Array elements are addressed by their index, e.g.
+// "sensors/0/temp". The prefix parameter should be an empty string for
+// root-level calls. The returned paths are sorted.
+func CollectPaths(data interface{}, prefix string) []string {
+	var paths []string
+	collectPaths(data, prefix, &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+func collectPaths(data interface{}, prefix string, paths *[]string) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			collectLeafOrRecurse(value, joinPath(prefix, key), paths)
+		}
+	case []interface{}:
+		for i, value := range v {
+			collectLeafOrRecurse(value, joinPath(prefix, strconv.Itoa(i)), paths)
+		}
+	}
+}
+
+func collectLeafOrRecurse(value interface{}, path string, paths *[]string) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		collectPaths(value, path, paths)
+	default:
+		*paths = append(*paths, path)
+	}
+}
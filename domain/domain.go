@@ -72,3 +72,15 @@ func ValidateUploadKey(uploadKey string) error {
 	}
 	return nil
 }
+
+// ValidateDownloadKey checks that downloadKey is a 256 bit hex string with
+// the optional "d_" prefix. Downloads of unknown keys fail anyway; this is
+// for endpoints that only render a key, e.g. as a QR code.
+func ValidateDownloadKey(downloadKey string) error {
+	downloadKey = strings.ToLower(StripDownloadPrefix(downloadKey))
+	decoded, err := hex.DecodeString(downloadKey)
+	if err != nil || len(decoded) != 32 {
+		return errors.New("downloadKey must be a 256 bit hex string")
+	}
+	return nil
+}
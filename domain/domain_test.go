@@ -70,6 +70,28 @@ func TestValidateUploadKey(t *testing.T) {
 	}
 }
 
+func TestValidateDownloadKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		downloadKey string
+		wantErr     bool
+	}{
+		{"Valid key", "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef", false},
+		{"Valid key with prefix", "d_1234567890ABCDEF1234567890ABCDEF1234567890ABCDEF1234567890ABCDEF", false},
+		{"Upload prefix", "u_1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef", true},
+		{"Invalid length", "1234567890abcdef", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDownloadKey(tt.downloadKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDownloadKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestDeriveDownloadKeyConsistency(t *testing.T) {
 	uploadKey := GenerateRandomKey()
 	downloadKey1, err1 := DeriveDownloadKey(uploadKey)
@@ -0,0 +1,249 @@
+// Package grpchandler serves the ValueStore gRPC service defined in
+// proto/valuestore/v1/valuestore.proto. Every method is a thin wrapper over
+// data.Service; Watch streams the stored data after every change.
+package grpchandler
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=github.com/dhcgn/iot-ephemeral-value-store --go-grpc_out=.. --go-grpc_opt=module=github.com/dhcgn/iot-ephemeral-value-store valuestore/v1/valuestore.proto
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/grpchandler/valuestorepb"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Config holds the dependencies of the gRPC service.
+type Config struct {
+	DataService   *data.Service
+	StatsInstance *stats.Stats
+}
+
+// NewServer returns a gRPC server with the ValueStore service registered.
+// Failed calls are counted as errors in the stats.
+func NewServer(c Config, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(c.unaryInterceptor),
+		grpc.ChainStreamInterceptor(c.streamInterceptor),
+	)
+	s := grpc.NewServer(opts...)
+	valuestorepb.RegisterValueStoreServer(s, &service{Config: c})
+	return s
+}
+
+func (c Config) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		c.countError(info.FullMethod, err)
+	}
+	return resp, err
+}
+
+func (c Config) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err != nil && status.Code(err) != codes.Canceled {
+		c.countError(info.FullMethod, err)
+	}
+	return err
+}
+
+func (c Config) countError(method string, err error) {
+	slog.Debug("grpc: call failed", "error", err, "method", method)
+	c.StatsInstance.IncrementHTTPErrors()
+}
+
+type service struct {
+	valuestorepb.UnimplementedValueStoreServer
+	Config
+}
+
+func (s *service) GenerateKeyPair(ctx context.Context, req *valuestorepb.GenerateKeyPairRequest) (*valuestorepb.GenerateKeyPairResponse, error) {
+	uploadKey, downloadKey, err := s.DataService.GenerateKeyPair()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &valuestorepb.GenerateKeyPairResponse{UploadKey: uploadKey, DownloadKey: downloadKey}, nil
+}
+
+func (s *service) Upload(ctx context.Context, req *valuestorepb.UploadRequest) (*valuestorepb.UploadResponse, error) {
+	ctx = data.WithSource(ctx, data.SourceGRPC)
+	downloadKey, stored, err := s.DataService.UploadValues(ctx, req.GetUploadKey(), structValues(req.GetValues()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	s.StatsInstance.IncrementUploads()
+
+	doc, err := toStruct(stored)
+	if err != nil {
+		return nil, err
+	}
+	return &valuestorepb.UploadResponse{DownloadKey: downloadKey, Data: doc}, nil
+}
+
+func (s *service) Patch(ctx context.Context, req *valuestorepb.PatchRequest) (*valuestorepb.PatchResponse, error) {
+	opts := data.MergeOptions{Arrays: arrayMergeModes[req.GetArrays()]}
+	ctx = data.WithSource(ctx, data.SourceGRPC)
+	path := strings.Trim(req.GetPath(), "/")
+	downloadKey, stored, err := s.DataService.PatchValues(ctx, req.GetUploadKey(), path, structValues(req.GetValues()), opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	s.StatsInstance.IncrementUploads()
+
+	doc, err := toStruct(stored)
+	if err != nil {
+		return nil, err
+	}
+	return &valuestorepb.PatchResponse{DownloadKey: downloadKey, Data: doc}, nil
+}
+
+func (s *service) Download(ctx context.Context, req *valuestorepb.DownloadRequest) (*valuestorepb.DownloadResponse, error) {
+	value, expiresAt, err := s.download(ctx, req.GetDownloadKey(), req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	s.StatsInstance.IncrementDownloads()
+	return &valuestorepb.DownloadResponse{Value: value, ExpiresAt: timestamppb.New(expiresAt)}, nil
+}
+
+func (s *service) Delete(ctx context.Context, req *valuestorepb.DeleteRequest) (*valuestorepb.DeleteResponse, error) {
+	downloadKey, err := s.DataService.Delete(ctx, req.GetUploadKey())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &valuestorepb.DeleteResponse{DownloadKey: downloadKey}, nil
+}
+
+// Watch sends the current state and then a new message after every change
+// of the document. A state that cannot be found, e.g. after a Delete, is sent
+// with found set to false and does not end the stream.
+func (s *service) Watch(req *valuestorepb.WatchRequest, stream grpc.ServerStreamingServer[valuestorepb.WatchResponse]) error {
+	ctx := stream.Context()
+	changes, cancel := s.DataService.Subscribe(req.GetDownloadKey())
+	defer cancel()
+
+	for {
+		value, expiresAt, err := s.download(ctx, req.GetDownloadKey(), req.GetPath())
+		resp := &valuestorepb.WatchResponse{}
+		switch status.Code(err) {
+		case codes.OK:
+			resp = &valuestorepb.WatchResponse{Found: true, Value: value, ExpiresAt: timestamppb.New(expiresAt)}
+			s.StatsInstance.IncrementDownloads()
+		case codes.NotFound:
+		default:
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+
+		select {
+		case <-changes:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// download returns the whole document or the value at path.
+func (s *service) download(ctx context.Context, downloadKey, path string) (*structpb.Value, time.Time, error) {
+	jsonData, expiresAt, err := s.DataService.DownloadJSONWithExpiry(ctx, downloadKey)
+	if err != nil {
+		return nil, time.Time{}, toStatus(err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, time.Time{}, status.Error(codes.Internal, "error decoding JSON")
+	}
+
+	var value interface{} = doc
+	if path = strings.Trim(path, "/"); path != "" {
+		if value, err = data.TraverseField(doc, path); err != nil {
+			return nil, time.Time{}, toStatus(err)
+		}
+	}
+
+	v, err := structpb.NewValue(value)
+	if err != nil {
+		return nil, time.Time{}, status.Error(codes.Internal, "error encoding value")
+	}
+	return v, expiresAt, nil
+}
+
+var arrayMergeModes = map[valuestorepb.ArrayMergeMode]data.ArrayMergeMode{
+	valuestorepb.ArrayMergeMode_ARRAY_MERGE_MODE_UNSPECIFIED: data.ArrayMergeReplace,
+	valuestorepb.ArrayMergeMode_ARRAY_MERGE_MODE_REPLACE:     data.ArrayMergeReplace,
+	valuestorepb.ArrayMergeMode_ARRAY_MERGE_MODE_APPEND:      data.ArrayMergeAppend,
+	valuestorepb.ArrayMergeMode_ARRAY_MERGE_MODE_INDEX:       data.ArrayMergeIndex,
+}
+
+// structValues converts the values of a request and HTML-escapes all
+// strings, like the HTTP upload handlers do.
+func structValues(s *structpb.Struct) map[string]interface{} {
+	values := s.AsMap()
+	for k, v := range values {
+		values[k] = sanitizeValue(v)
+	}
+	return values
+}
+
+func sanitizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return html.EscapeString(val)
+	case map[string]interface{}:
+		for k, elem := range val {
+			val[k] = sanitizeValue(elem)
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = sanitizeValue(elem)
+		}
+	}
+	return v
+}
+
+// toStruct converts stored data, which may contain values of types that
+// structpb does not accept, by round-tripping it through JSON.
+func toStruct(stored map[string]interface{}) (*structpb.Struct, error) {
+	jsonData, err := json.Marshal(stored)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error encoding data")
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, status.Error(codes.Internal, "error encoding data")
+	}
+	s, err := structpb.NewStruct(doc)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error encoding data")
+	}
+	return s, nil
+}
+
+// toStatus maps an error of data.Service to a gRPC status.
+func toStatus(err error) error {
+	switch data.ErrorCode(err) {
+	case data.CodeNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case data.CodeInvalidKey, data.CodeValidation:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case data.CodeStorageDegraded, data.CodeStorageTimeout:
+		slog.Warn("grpc: storage unavailable", "error", err)
+		return status.Error(codes.Unavailable, "storage unavailable, retry later")
+	default:
+		slog.Error("grpc: data service error", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,242 @@
+package grpchandler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/grpchandler/valuestorepb"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestClient(t *testing.T) (valuestorepb.ValueStoreClient, *stats.Stats) {
+	t.Helper()
+	si := storage.NewInMemoryStorage()
+	st := stats.NewStats()
+	srv := NewServer(Config{DataService: &data.Service{StorageInstance: &si}, StatsInstance: st})
+
+	l := bufconn.Listen(1024 * 1024)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return valuestorepb.NewValueStoreClient(conn), st
+}
+
+func mustStruct(t *testing.T, m map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatalf("NewStruct failed: %v", err)
+	}
+	return s
+}
+
+func TestUploadPatchDownloadDelete(t *testing.T) {
+	client, st := newTestClient(t)
+	ctx := context.Background()
+
+	keys, err := client.GenerateKeyPair(ctx, &valuestorepb.GenerateKeyPairRequest{})
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	upload, err := client.Upload(ctx, &valuestorepb.UploadRequest{
+		UploadKey: keys.UploadKey,
+		Values:    mustStruct(t, map[string]interface{}{"temp": 21.5, "label": "<b>", "list": []interface{}{1.0}}),
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if upload.DownloadKey != keys.DownloadKey {
+		t.Errorf("Upload download key = %q, want %q", upload.DownloadKey, keys.DownloadKey)
+	}
+	if got := upload.Data.Fields["label"].GetStringValue(); got != "&lt;b&gt;" {
+		t.Errorf("label = %q, want escaped string", got)
+	}
+
+	_, err = client.Patch(ctx, &valuestorepb.PatchRequest{
+		UploadKey: keys.UploadKey,
+		Values:    mustStruct(t, map[string]interface{}{"list": []interface{}{2.0}}),
+		Arrays:    valuestorepb.ArrayMergeMode_ARRAY_MERGE_MODE_APPEND,
+	})
+	if err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+	if _, err := client.Patch(ctx, &valuestorepb.PatchRequest{
+		UploadKey: keys.UploadKey,
+		Path:      "room1",
+		Values:    mustStruct(t, map[string]interface{}{"hum": 40.0}),
+	}); err != nil {
+		t.Fatalf("Patch with path failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{path: "temp", want: 21.5},
+		{path: "list/-1", want: 2.0},
+		{path: "room1/hum", want: 40.0},
+	}
+	for _, tt := range tests {
+		resp, err := client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: keys.DownloadKey, Path: tt.path})
+		if err != nil {
+			t.Fatalf("Download(%q) failed: %v", tt.path, err)
+		}
+		if got := resp.Value.AsInterface(); got != tt.want {
+			t.Errorf("Download(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+		if resp.ExpiresAt.AsTime().Before(time.Now()) {
+			t.Errorf("Download(%q) expires_at = %v, want a future time", tt.path, resp.ExpiresAt.AsTime())
+		}
+	}
+
+	whole, err := client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: "d_" + keys.DownloadKey})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if _, ok := whole.Value.GetStructValue().Fields["room1"]; !ok {
+		t.Errorf("Download() = %v, want room1", whole.Value)
+	}
+
+	if _, err := client.Delete(ctx, &valuestorepb.DeleteRequest{UploadKey: keys.UploadKey}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	_, err = client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: keys.DownloadKey})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Download after Delete error = %v, want NotFound", err)
+	}
+
+	current := st.GetCurrentStats()
+	if current.UploadCount != 3 || current.HTTPErrorCount != 1 {
+		t.Errorf("stats = %d uploads, %d errors, want 3 and 1", current.UploadCount, current.HTTPErrorCount)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	keys, _ := client.GenerateKeyPair(ctx, &valuestorepb.GenerateKeyPairRequest{})
+	if _, err := client.Upload(ctx, &valuestorepb.UploadRequest{
+		UploadKey: keys.UploadKey,
+		Values:    mustStruct(t, map[string]interface{}{"temp": "21"}),
+	}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{
+			name: "invalid upload key",
+			call: func() error {
+				_, err := client.Upload(ctx, &valuestorepb.UploadRequest{UploadKey: "invalid"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "unknown download key",
+			call: func() error {
+				_, err := client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: "unknown"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "missing path",
+			call: func() error {
+				_, err := client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: keys.DownloadKey, Path: "missing"})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "path through a value",
+			call: func() error {
+				_, err := client.Download(ctx, &valuestorepb.DownloadRequest{DownloadKey: keys.DownloadKey, Path: "temp/x"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "invalid delete key",
+			call: func() error {
+				_, err := client.Delete(ctx, &valuestorepb.DeleteRequest{UploadKey: keys.DownloadKey + "x"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	keys, _ := client.GenerateKeyPair(ctx, &valuestorepb.GenerateKeyPairRequest{})
+
+	stream, err := client.Watch(ctx, &valuestorepb.WatchRequest{DownloadKey: keys.DownloadKey, Path: "temp"})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	recv := func() *valuestorepb.WatchResponse {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		return resp
+	}
+
+	if resp := recv(); resp.Found {
+		t.Errorf("initial state = %v, want not found", resp)
+	}
+
+	for _, temp := range []string{"21", "22"} {
+		if _, err := client.Upload(ctx, &valuestorepb.UploadRequest{
+			UploadKey: keys.UploadKey,
+			Values:    mustStruct(t, map[string]interface{}{"temp": temp}),
+		}); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if resp := recv(); !resp.Found || resp.Value.GetStringValue() != temp {
+			t.Errorf("state after upload = %v, want temp %s", resp, temp)
+		}
+	}
+
+	if _, err := client.Delete(ctx, &valuestorepb.DeleteRequest{UploadKey: keys.UploadKey}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if resp := recv(); resp.Found {
+		t.Errorf("state after delete = %v, want not found", resp)
+	}
+}
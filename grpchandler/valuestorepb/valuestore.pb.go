@@ -0,0 +1,848 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: valuestore/v1/valuestore.proto
+
+package valuestorepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ArrayMergeMode selects how Patch combines arrays already stored under the
+// same key with arrays in the values.
+type ArrayMergeMode int32
+
+const (
+	// Same as ARRAY_MERGE_MODE_REPLACE.
+	ArrayMergeMode_ARRAY_MERGE_MODE_UNSPECIFIED ArrayMergeMode = 0
+	ArrayMergeMode_ARRAY_MERGE_MODE_REPLACE     ArrayMergeMode = 1
+	ArrayMergeMode_ARRAY_MERGE_MODE_APPEND      ArrayMergeMode = 2
+	ArrayMergeMode_ARRAY_MERGE_MODE_INDEX       ArrayMergeMode = 3
+)
+
+// Enum value maps for ArrayMergeMode.
+var (
+	ArrayMergeMode_name = map[int32]string{
+		0: "ARRAY_MERGE_MODE_UNSPECIFIED",
+		1: "ARRAY_MERGE_MODE_REPLACE",
+		2: "ARRAY_MERGE_MODE_APPEND",
+		3: "ARRAY_MERGE_MODE_INDEX",
+	}
+	ArrayMergeMode_value = map[string]int32{
+		"ARRAY_MERGE_MODE_UNSPECIFIED": 0,
+		"ARRAY_MERGE_MODE_REPLACE":     1,
+		"ARRAY_MERGE_MODE_APPEND":      2,
+		"ARRAY_MERGE_MODE_INDEX":       3,
+	}
+)
+
+func (x ArrayMergeMode) Enum() *ArrayMergeMode {
+	p := new(ArrayMergeMode)
+	*p = x
+	return p
+}
+
+func (x ArrayMergeMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ArrayMergeMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_valuestore_v1_valuestore_proto_enumTypes[0].Descriptor()
+}
+
+func (ArrayMergeMode) Type() protoreflect.EnumType {
+	return &file_valuestore_v1_valuestore_proto_enumTypes[0]
+}
+
+func (x ArrayMergeMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ArrayMergeMode.Descriptor instead.
+func (ArrayMergeMode) EnumDescriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{0}
+}
+
+type GenerateKeyPairRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateKeyPairRequest) Reset() {
+	*x = GenerateKeyPairRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateKeyPairRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateKeyPairRequest) ProtoMessage() {}
+
+func (x *GenerateKeyPairRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateKeyPairRequest.ProtoReflect.Descriptor instead.
+func (*GenerateKeyPairRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{0}
+}
+
+type GenerateKeyPairResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadKey     string                 `protobuf:"bytes,1,opt,name=upload_key,json=uploadKey,proto3" json:"upload_key,omitempty"`
+	DownloadKey   string                 `protobuf:"bytes,2,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateKeyPairResponse) Reset() {
+	*x = GenerateKeyPairResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateKeyPairResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateKeyPairResponse) ProtoMessage() {}
+
+func (x *GenerateKeyPairResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateKeyPairResponse.ProtoReflect.Descriptor instead.
+func (*GenerateKeyPairResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GenerateKeyPairResponse) GetUploadKey() string {
+	if x != nil {
+		return x.UploadKey
+	}
+	return ""
+}
+
+func (x *GenerateKeyPairResponse) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+type UploadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadKey     string                 `protobuf:"bytes,1,opt,name=upload_key,json=uploadKey,proto3" json:"upload_key,omitempty"`
+	Values        *structpb.Struct       `protobuf:"bytes,2,opt,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadRequest) Reset() {
+	*x = UploadRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadRequest) ProtoMessage() {}
+
+func (x *UploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadRequest.ProtoReflect.Descriptor instead.
+func (*UploadRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UploadRequest) GetUploadKey() string {
+	if x != nil {
+		return x.UploadKey
+	}
+	return ""
+}
+
+func (x *UploadRequest) GetValues() *structpb.Struct {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type UploadResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	DownloadKey string                 `protobuf:"bytes,1,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	// The stored document including server-generated timestamps.
+	Data          *structpb.Struct `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResponse) ProtoMessage() {}
+
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UploadResponse) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+func (x *UploadResponse) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type PatchRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	UploadKey string                 `protobuf:"bytes,1,opt,name=upload_key,json=uploadKey,proto3" json:"upload_key,omitempty"`
+	// Slash-separated path, e.g. "house/kitchen". Empty for the root.
+	Path          string           `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Values        *structpb.Struct `protobuf:"bytes,3,opt,name=values,proto3" json:"values,omitempty"`
+	Arrays        ArrayMergeMode   `protobuf:"varint,4,opt,name=arrays,proto3,enum=valuestore.v1.ArrayMergeMode" json:"arrays,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PatchRequest) Reset() {
+	*x = PatchRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchRequest) ProtoMessage() {}
+
+func (x *PatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchRequest.ProtoReflect.Descriptor instead.
+func (*PatchRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PatchRequest) GetUploadKey() string {
+	if x != nil {
+		return x.UploadKey
+	}
+	return ""
+}
+
+func (x *PatchRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *PatchRequest) GetValues() *structpb.Struct {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *PatchRequest) GetArrays() ArrayMergeMode {
+	if x != nil {
+		return x.Arrays
+	}
+	return ArrayMergeMode_ARRAY_MERGE_MODE_UNSPECIFIED
+}
+
+type PatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DownloadKey   string                 `protobuf:"bytes,1,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PatchResponse) Reset() {
+	*x = PatchResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchResponse) ProtoMessage() {}
+
+func (x *PatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchResponse.ProtoReflect.Descriptor instead.
+func (*PatchResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PatchResponse) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+func (x *PatchResponse) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type DownloadRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	DownloadKey string                 `protobuf:"bytes,1,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	// Optional slash-separated path of a single value, e.g. "room1/temp" or
+	// "list/0". Empty for the whole document.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadRequest) ProtoMessage() {}
+
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DownloadRequest) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+func (x *DownloadRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type DownloadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *structpb.Value        `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadResponse) Reset() {
+	*x = DownloadResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadResponse) ProtoMessage() {}
+
+func (x *DownloadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadResponse.ProtoReflect.Descriptor instead.
+func (*DownloadResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DownloadResponse) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *DownloadResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadKey     string                 `protobuf:"bytes,1,opt,name=upload_key,json=uploadKey,proto3" json:"upload_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteRequest) GetUploadKey() string {
+	if x != nil {
+		return x.UploadKey
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DownloadKey   string                 `protobuf:"bytes,1,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeleteResponse) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	DownloadKey string                 `protobuf:"bytes,1,opt,name=download_key,json=downloadKey,proto3" json:"download_key,omitempty"`
+	// Optional path of a single value to watch. Empty for the whole document.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchRequest) GetDownloadKey() string {
+	if x != nil {
+		return x.DownloadKey
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type WatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// False if no data is stored for the key or the path does not exist, for
+	// example after a Delete. value and expires_at are unset in that case.
+	Found         bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Value         *structpb.Value        `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_valuestore_v1_valuestore_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_valuestore_v1_valuestore_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WatchResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *WatchResponse) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+var File_valuestore_v1_valuestore_proto protoreflect.FileDescriptor
+
+const file_valuestore_v1_valuestore_proto_rawDesc = "" +
+	"\n" +
+	"\x1evaluestore/v1/valuestore.proto\x12\rvaluestore.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x18\n" +
+	"\x16GenerateKeyPairRequest\"[\n" +
+	"\x17GenerateKeyPairResponse\x12\x1d\n" +
+	"\n" +
+	"upload_key\x18\x01 \x01(\tR\tuploadKey\x12!\n" +
+	"\fdownload_key\x18\x02 \x01(\tR\vdownloadKey\"_\n" +
+	"\rUploadRequest\x12\x1d\n" +
+	"\n" +
+	"upload_key\x18\x01 \x01(\tR\tuploadKey\x12/\n" +
+	"\x06values\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06values\"`\n" +
+	"\x0eUploadResponse\x12!\n" +
+	"\fdownload_key\x18\x01 \x01(\tR\vdownloadKey\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\"\xa9\x01\n" +
+	"\fPatchRequest\x12\x1d\n" +
+	"\n" +
+	"upload_key\x18\x01 \x01(\tR\tuploadKey\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12/\n" +
+	"\x06values\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06values\x125\n" +
+	"\x06arrays\x18\x04 \x01(\x0e2\x1d.valuestore.v1.ArrayMergeModeR\x06arrays\"_\n" +
+	"\rPatchResponse\x12!\n" +
+	"\fdownload_key\x18\x01 \x01(\tR\vdownloadKey\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\"H\n" +
+	"\x0fDownloadRequest\x12!\n" +
+	"\fdownload_key\x18\x01 \x01(\tR\vdownloadKey\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"{\n" +
+	"\x10DownloadResponse\x12,\n" +
+	"\x05value\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x05value\x129\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\".\n" +
+	"\rDeleteRequest\x12\x1d\n" +
+	"\n" +
+	"upload_key\x18\x01 \x01(\tR\tuploadKey\"3\n" +
+	"\x0eDeleteResponse\x12!\n" +
+	"\fdownload_key\x18\x01 \x01(\tR\vdownloadKey\"E\n" +
+	"\fWatchRequest\x12!\n" +
+	"\fdownload_key\x18\x01 \x01(\tR\vdownloadKey\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\x8e\x01\n" +
+	"\rWatchResponse\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value\x129\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt*\x89\x01\n" +
+	"\x0eArrayMergeMode\x12 \n" +
+	"\x1cARRAY_MERGE_MODE_UNSPECIFIED\x10\x00\x12\x1c\n" +
+	"\x18ARRAY_MERGE_MODE_REPLACE\x10\x01\x12\x1b\n" +
+	"\x17ARRAY_MERGE_MODE_APPEND\x10\x02\x12\x1a\n" +
+	"\x16ARRAY_MERGE_MODE_INDEX\x10\x032\xd3\x03\n" +
+	"\n" +
+	"ValueStore\x12`\n" +
+	"\x0fGenerateKeyPair\x12%.valuestore.v1.GenerateKeyPairRequest\x1a&.valuestore.v1.GenerateKeyPairResponse\x12E\n" +
+	"\x06Upload\x12\x1c.valuestore.v1.UploadRequest\x1a\x1d.valuestore.v1.UploadResponse\x12B\n" +
+	"\x05Patch\x12\x1b.valuestore.v1.PatchRequest\x1a\x1c.valuestore.v1.PatchResponse\x12K\n" +
+	"\bDownload\x12\x1e.valuestore.v1.DownloadRequest\x1a\x1f.valuestore.v1.DownloadResponse\x12E\n" +
+	"\x06Delete\x12\x1c.valuestore.v1.DeleteRequest\x1a\x1d.valuestore.v1.DeleteResponse\x12D\n" +
+	"\x05Watch\x12\x1b.valuestore.v1.WatchRequest\x1a\x1c.valuestore.v1.WatchResponse0\x01BRZPgithub.com/dhcgn/iot-ephemeral-value-store/grpchandler/valuestorepb;valuestorepbb\x06proto3"
+
+var (
+	file_valuestore_v1_valuestore_proto_rawDescOnce sync.Once
+	file_valuestore_v1_valuestore_proto_rawDescData []byte
+)
+
+func file_valuestore_v1_valuestore_proto_rawDescGZIP() []byte {
+	file_valuestore_v1_valuestore_proto_rawDescOnce.Do(func() {
+		file_valuestore_v1_valuestore_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_valuestore_v1_valuestore_proto_rawDesc), len(file_valuestore_v1_valuestore_proto_rawDesc)))
+	})
+	return file_valuestore_v1_valuestore_proto_rawDescData
+}
+
+var file_valuestore_v1_valuestore_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_valuestore_v1_valuestore_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_valuestore_v1_valuestore_proto_goTypes = []any{
+	(ArrayMergeMode)(0),             // 0: valuestore.v1.ArrayMergeMode
+	(*GenerateKeyPairRequest)(nil),  // 1: valuestore.v1.GenerateKeyPairRequest
+	(*GenerateKeyPairResponse)(nil), // 2: valuestore.v1.GenerateKeyPairResponse
+	(*UploadRequest)(nil),           // 3: valuestore.v1.UploadRequest
+	(*UploadResponse)(nil),          // 4: valuestore.v1.UploadResponse
+	(*PatchRequest)(nil),            // 5: valuestore.v1.PatchRequest
+	(*PatchResponse)(nil),           // 6: valuestore.v1.PatchResponse
+	(*DownloadRequest)(nil),         // 7: valuestore.v1.DownloadRequest
+	(*DownloadResponse)(nil),        // 8: valuestore.v1.DownloadResponse
+	(*DeleteRequest)(nil),           // 9: valuestore.v1.DeleteRequest
+	(*DeleteResponse)(nil),          // 10: valuestore.v1.DeleteResponse
+	(*WatchRequest)(nil),            // 11: valuestore.v1.WatchRequest
+	(*WatchResponse)(nil),           // 12: valuestore.v1.WatchResponse
+	(*structpb.Struct)(nil),         // 13: google.protobuf.Struct
+	(*structpb.Value)(nil),          // 14: google.protobuf.Value
+	(*timestamppb.Timestamp)(nil),   // 15: google.protobuf.Timestamp
+}
+var file_valuestore_v1_valuestore_proto_depIdxs = []int32{
+	13, // 0: valuestore.v1.UploadRequest.values:type_name -> google.protobuf.Struct
+	13, // 1: valuestore.v1.UploadResponse.data:type_name -> google.protobuf.Struct
+	13, // 2: valuestore.v1.PatchRequest.values:type_name -> google.protobuf.Struct
+	0,  // 3: valuestore.v1.PatchRequest.arrays:type_name -> valuestore.v1.ArrayMergeMode
+	13, // 4: valuestore.v1.PatchResponse.data:type_name -> google.protobuf.Struct
+	14, // 5: valuestore.v1.DownloadResponse.value:type_name -> google.protobuf.Value
+	15, // 6: valuestore.v1.DownloadResponse.expires_at:type_name -> google.protobuf.Timestamp
+	14, // 7: valuestore.v1.WatchResponse.value:type_name -> google.protobuf.Value
+	15, // 8: valuestore.v1.WatchResponse.expires_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: valuestore.v1.ValueStore.GenerateKeyPair:input_type -> valuestore.v1.GenerateKeyPairRequest
+	3,  // 10: valuestore.v1.ValueStore.Upload:input_type -> valuestore.v1.UploadRequest
+	5,  // 11: valuestore.v1.ValueStore.Patch:input_type -> valuestore.v1.PatchRequest
+	7,  // 12: valuestore.v1.ValueStore.Download:input_type -> valuestore.v1.DownloadRequest
+	9,  // 13: valuestore.v1.ValueStore.Delete:input_type -> valuestore.v1.DeleteRequest
+	11, // 14: valuestore.v1.ValueStore.Watch:input_type -> valuestore.v1.WatchRequest
+	2,  // 15: valuestore.v1.ValueStore.GenerateKeyPair:output_type -> valuestore.v1.GenerateKeyPairResponse
+	4,  // 16: valuestore.v1.ValueStore.Upload:output_type -> valuestore.v1.UploadResponse
+	6,  // 17: valuestore.v1.ValueStore.Patch:output_type -> valuestore.v1.PatchResponse
+	8,  // 18: valuestore.v1.ValueStore.Download:output_type -> valuestore.v1.DownloadResponse
+	10, // 19: valuestore.v1.ValueStore.Delete:output_type -> valuestore.v1.DeleteResponse
+	12, // 20: valuestore.v1.ValueStore.Watch:output_type -> valuestore.v1.WatchResponse
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_valuestore_v1_valuestore_proto_init() }
+func file_valuestore_v1_valuestore_proto_init() {
+	if File_valuestore_v1_valuestore_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_valuestore_v1_valuestore_proto_rawDesc), len(file_valuestore_v1_valuestore_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_valuestore_v1_valuestore_proto_goTypes,
+		DependencyIndexes: file_valuestore_v1_valuestore_proto_depIdxs,
+		EnumInfos:         file_valuestore_v1_valuestore_proto_enumTypes,
+		MessageInfos:      file_valuestore_v1_valuestore_proto_msgTypes,
+	}.Build()
+	File_valuestore_v1_valuestore_proto = out.File
+	file_valuestore_v1_valuestore_proto_goTypes = nil
+	file_valuestore_v1_valuestore_proto_depIdxs = nil
+}
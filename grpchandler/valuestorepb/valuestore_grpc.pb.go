@@ -0,0 +1,337 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: valuestore/v1/valuestore.proto
+
+package valuestorepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ValueStore_GenerateKeyPair_FullMethodName = "/valuestore.v1.ValueStore/GenerateKeyPair"
+	ValueStore_Upload_FullMethodName          = "/valuestore.v1.ValueStore/Upload"
+	ValueStore_Patch_FullMethodName           = "/valuestore.v1.ValueStore/Patch"
+	ValueStore_Download_FullMethodName        = "/valuestore.v1.ValueStore/Download"
+	ValueStore_Delete_FullMethodName          = "/valuestore.v1.ValueStore/Delete"
+	ValueStore_Watch_FullMethodName           = "/valuestore.v1.ValueStore/Watch"
+)
+
+// ValueStoreClient is the client API for ValueStore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ValueStore exposes the upload and download operations of the REST API.
+// Upload keys are 256 bit hex strings with an optional "u_" prefix; the
+// download key is derived from the upload key and may carry a "d_" prefix.
+type ValueStoreClient interface {
+	// GenerateKeyPair returns a new upload key and its download key.
+	GenerateKeyPair(ctx context.Context, in *GenerateKeyPairRequest, opts ...grpc.CallOption) (*GenerateKeyPairResponse, error)
+	// Upload replaces all data of the upload key.
+	Upload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadResponse, error)
+	// Patch merges values into the data of the upload key at a path.
+	Patch(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*PatchResponse, error)
+	// Download returns the whole document or the value at a path.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (*DownloadResponse, error)
+	// Delete removes all data of the upload key.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Watch sends the current document or value and a new message after
+	// every change until the client cancels the call.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
+}
+
+type valueStoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewValueStoreClient(cc grpc.ClientConnInterface) ValueStoreClient {
+	return &valueStoreClient{cc}
+}
+
+func (c *valueStoreClient) GenerateKeyPair(ctx context.Context, in *GenerateKeyPairRequest, opts ...grpc.CallOption) (*GenerateKeyPairResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerateKeyPairResponse)
+	err := c.cc.Invoke(ctx, ValueStore_GenerateKeyPair_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *valueStoreClient) Upload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadResponse)
+	err := c.cc.Invoke(ctx, ValueStore_Upload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *valueStoreClient) Patch(ctx context.Context, in *PatchRequest, opts ...grpc.CallOption) (*PatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PatchResponse)
+	err := c.cc.Invoke(ctx, ValueStore_Patch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *valueStoreClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (*DownloadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DownloadResponse)
+	err := c.cc.Invoke(ctx, ValueStore_Download_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *valueStoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, ValueStore_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *valueStoreClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ValueStore_ServiceDesc.Streams[0], ValueStore_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ValueStore_WatchClient = grpc.ServerStreamingClient[WatchResponse]
+
+// ValueStoreServer is the server API for ValueStore service.
+// All implementations must embed UnimplementedValueStoreServer
+// for forward compatibility.
+//
+// ValueStore exposes the upload and download operations of the REST API.
+// Upload keys are 256 bit hex strings with an optional "u_" prefix; the
+// download key is derived from the upload key and may carry a "d_" prefix.
+type ValueStoreServer interface {
+	// GenerateKeyPair returns a new upload key and its download key.
+	GenerateKeyPair(context.Context, *GenerateKeyPairRequest) (*GenerateKeyPairResponse, error)
+	// Upload replaces all data of the upload key.
+	Upload(context.Context, *UploadRequest) (*UploadResponse, error)
+	// Patch merges values into the data of the upload key at a path.
+	Patch(context.Context, *PatchRequest) (*PatchResponse, error)
+	// Download returns the whole document or the value at a path.
+	Download(context.Context, *DownloadRequest) (*DownloadResponse, error)
+	// Delete removes all data of the upload key.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Watch sends the current document or value and a new message after
+	// every change until the client cancels the call.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
+	mustEmbedUnimplementedValueStoreServer()
+}
+
+// UnimplementedValueStoreServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedValueStoreServer struct{}
+
+func (UnimplementedValueStoreServer) GenerateKeyPair(context.Context, *GenerateKeyPairRequest) (*GenerateKeyPairResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GenerateKeyPair not implemented")
+}
+func (UnimplementedValueStoreServer) Upload(context.Context, *UploadRequest) (*UploadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedValueStoreServer) Patch(context.Context, *PatchRequest) (*PatchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Patch not implemented")
+}
+func (UnimplementedValueStoreServer) Download(context.Context, *DownloadRequest) (*DownloadResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedValueStoreServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedValueStoreServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error {
+	return status.Error(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedValueStoreServer) mustEmbedUnimplementedValueStoreServer() {}
+func (UnimplementedValueStoreServer) testEmbeddedByValue()                    {}
+
+// UnsafeValueStoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ValueStoreServer will
+// result in compilation errors.
+type UnsafeValueStoreServer interface {
+	mustEmbedUnimplementedValueStoreServer()
+}
+
+func RegisterValueStoreServer(s grpc.ServiceRegistrar, srv ValueStoreServer) {
+	// If the following call panics, it indicates UnimplementedValueStoreServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ValueStore_ServiceDesc, srv)
+}
+
+func _ValueStore_GenerateKeyPair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateKeyPairRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValueStoreServer).GenerateKeyPair(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValueStore_GenerateKeyPair_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValueStoreServer).GenerateKeyPair(ctx, req.(*GenerateKeyPairRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValueStore_Upload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValueStoreServer).Upload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValueStore_Upload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValueStoreServer).Upload(ctx, req.(*UploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValueStore_Patch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValueStoreServer).Patch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValueStore_Patch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValueStoreServer).Patch(ctx, req.(*PatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValueStore_Download_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValueStoreServer).Download(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValueStore_Download_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValueStoreServer).Download(ctx, req.(*DownloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValueStore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValueStoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValueStore_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValueStoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValueStore_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ValueStoreServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ValueStore_WatchServer = grpc.ServerStreamingServer[WatchResponse]
+
+// ValueStore_ServiceDesc is the grpc.ServiceDesc for ValueStore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ValueStore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "valuestore.v1.ValueStore",
+	HandlerType: (*ValueStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GenerateKeyPair",
+			Handler:    _ValueStore_GenerateKeyPair_Handler,
+		},
+		{
+			MethodName: "Upload",
+			Handler:    _ValueStore_Upload_Handler,
+		},
+		{
+			MethodName: "Patch",
+			Handler:    _ValueStore_Patch_Handler,
+		},
+		{
+			MethodName: "Download",
+			Handler:    _ValueStore_Download_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _ValueStore_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ValueStore_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "valuestore/v1/valuestore.proto",
+}
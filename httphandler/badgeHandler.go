@@ -0,0 +1,353 @@
+package httphandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// badgeColors are the named colors of shields.io badges.
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellowgreen": "#a4a61d",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"grey":        "#555",
+	"lightgrey":   "#9f9f9f",
+}
+
+var hexColorPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+const (
+	defaultBadgeColor = "#007ec6"
+	missingBadgeColor = "#9f9f9f"
+	labelBadgeColor   = "#555"
+)
+
+// parseColor returns the SVG color of a named or hex color.
+func parseColor(s string) (string, error) {
+	if color, ok := badgeColors[strings.ToLower(s)]; ok {
+		return color, nil
+	}
+	if m := hexColorPattern.FindStringSubmatch(s); m != nil {
+		return "#" + m[1], nil
+	}
+	return "", fmt.Errorf("invalid color %q, expected a name like green or a hex color like 4c1", s)
+}
+
+// badgeThreshold colors values greater than or equal to min.
+type badgeThreshold struct {
+	min   float64
+	color string
+}
+
+// parseThresholds parses "min:color" pairs separated by commas, e.g.
+// "0:blue,20:green,30:red".
+func parseThresholds(s string) ([]badgeThreshold, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var thresholds []badgeThreshold
+	for _, part := range strings.Split(s, ",") {
+		minStr, colorStr, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid threshold %q, expected min:color", part)
+		}
+		min, err := strconv.ParseFloat(strings.TrimSpace(minStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q, expected min:color", part)
+		}
+		color, err := parseColor(strings.TrimSpace(colorStr))
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, badgeThreshold{min: min, color: color})
+	}
+	return thresholds, nil
+}
+
+// thresholdColor returns the color of the highest threshold reached by v,
+// or fallback if none is reached.
+func thresholdColor(thresholds []badgeThreshold, v float64, fallback string) string {
+	var best *badgeThreshold
+	for i, t := range thresholds {
+		if v >= t.min && (best == nil || t.min >= best.min) {
+			best = &thresholds[i]
+		}
+	}
+	if best == nil {
+		return fallback
+	}
+	return best.color
+}
+
+// numericValue returns v as a number. Query parameter uploads store numbers
+// as strings, so numeric strings are accepted as well.
+func numericValue(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// downloadDocument returns the decoded document of downloadKey.
+func (c Config) downloadDocument(r *http.Request, downloadKey string) (map[string]interface{}, time.Time, error) {
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, time.Time{}, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	return doc, expiresAt, nil
+}
+
+// lookupValue returns the value at param of the document of downloadKey.
+func (c Config) lookupValue(r *http.Request, downloadKey, param string) (interface{}, time.Time, error) {
+	doc, expiresAt, err := c.downloadDocument(r, downloadKey)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	value, err := data.TraverseField(doc, param)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return value, expiresAt, nil
+}
+
+// DownloadBadgeHandler handles /d/{downloadKey}/badge/{param}.svg and renders
+// the value as a shields-style SVG badge for pages that only embed images.
+// Query parameters: label (default: last path segment), unit, color and
+// thresholds ("min:color" pairs for numeric values). Missing data is rendered
+// as "n/a" with status 404, so the image still shows.
+func (c Config) DownloadBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+	param := vars["param"]
+	query := r.URL.Query()
+
+	label := query.Get("label")
+	if label == "" {
+		label = param[strings.LastIndex(param, "/")+1:]
+	}
+	color := defaultBadgeColor
+	if s := query.Get("color"); s != "" {
+		var err error
+		if color, err = parseColor(s); err != nil {
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeValidation, err.Error()))
+			return
+		}
+	}
+	thresholds, err := parseThresholds(query.Get("thresholds"))
+	if err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, err.Error()))
+		return
+	}
+
+	value, expiresAt, err := c.lookupValue(r, downloadKey, param)
+	if err != nil {
+		slog.Debug("badge: failed to retrieve value", "error", err, "param", param, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		if data.ErrorCode(err) != data.CodeNotFound {
+			writeError(w, r, err, "Error reading data")
+			return
+		}
+		writeSVG(w, http.StatusNotFound, renderBadge(label, "n/a", missingBadgeColor))
+		return
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, "Badges show single values, not objects or arrays"))
+		return
+	}
+
+	text := html.UnescapeString(formatFlatValue(value))
+	if unit := query.Get("unit"); unit != "" {
+		text += " " + unit
+	}
+	if v, ok := numericValue(value); ok {
+		color = thresholdColor(thresholds, v, color)
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	writeSVG(w, http.StatusOK, renderBadge(label, text, color))
+}
+
+// DownloadSparklineHandler handles /d/{downloadKey}/sparkline/{param}.svg and
+// renders an array of numbers, e.g. appended with ?arrays=append, as a
+// sparkline. Query parameters: width, height and color.
+func (c Config) DownloadSparklineHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+	param := vars["param"]
+
+	width, height, color, err := parseSparklineOptions(r)
+	if err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, err.Error()))
+		return
+	}
+
+	value, expiresAt, err := c.lookupValue(r, downloadKey, param)
+	if err != nil {
+		slog.Debug("sparkline: failed to retrieve value", "error", err, "param", param, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	list, _ := value.([]interface{})
+	points := make([]float64, 0, len(list))
+	for _, elem := range list {
+		if v, ok := numericValue(elem); ok {
+			points = append(points, v)
+		}
+	}
+	if len(points) == 0 || len(points) != len(list) {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, "Sparklines need an array of numbers at the path, e.g. appended with ?arrays=append"))
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	writeSVG(w, http.StatusOK, renderSparkline(points, width, height, color))
+}
+
+// parseSparklineOptions returns the size and color of a sparkline.
+func parseSparklineOptions(r *http.Request) (width, height int, color string, err error) {
+	query := r.URL.Query()
+	if width, err = sparklineDimension(query.Get("width"), 100); err != nil {
+		return 0, 0, "", err
+	}
+	if height, err = sparklineDimension(query.Get("height"), 20); err != nil {
+		return 0, 0, "", err
+	}
+	color = defaultBadgeColor
+	if s := query.Get("color"); s != "" {
+		if color, err = parseColor(s); err != nil {
+			return 0, 0, "", err
+		}
+	}
+	return width, height, color, nil
+}
+
+// sparklineDimension parses a width or height between 10 and 1000 pixels.
+func sparklineDimension(s string, fallback int) (int, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 10 || n > 1000 {
+		return 0, fmt.Errorf("invalid size %q, expected 10 to 1000 pixels", s)
+	}
+	return n, nil
+}
+
+func writeSVG(w http.ResponseWriter, status int, svg []byte) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(status)
+	w.Write(svg)
+}
+
+// textWidth estimates the width of s in pixels for 11px Verdana, the font of
+// shields-style badges.
+func textWidth(s string) int {
+	width := 0.0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ijlI.,:;'|!", r):
+			width += 3.5
+		case strings.ContainsRune("frt()[] ", r):
+			width += 4.5
+		case strings.ContainsRune("mwMW%", r):
+			width += 10
+		case r >= 'A' && r <= 'Z':
+			width += 7.5
+		default:
+			width += 7
+		}
+	}
+	return int(width + 0.5)
+}
+
+// renderBadge renders a two-part badge with a grey label and a colored value.
+func renderBadge(label, value, color string) []byte {
+	labelWidth := textWidth(label) + 10
+	valueWidth := textWidth(value) + 10
+	total := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, total, label, value)
+	fmt.Fprintf(&buf, `<title>%s: %s</title>`, label, value)
+	buf.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&buf, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, total)
+	fmt.Fprintf(&buf, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="%s"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		labelWidth, labelBadgeColor, labelWidth, valueWidth, color, total)
+	buf.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&buf, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, labelWidth/2, label, labelWidth/2, label)
+	fmt.Fprintf(&buf, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, labelWidth+valueWidth/2, value, labelWidth+valueWidth/2, value)
+	buf.WriteString(`</g></svg>`)
+	return buf.Bytes()
+}
+
+// renderSparkline renders points as a line scaled to width and height, with
+// a dot on the latest value.
+func renderSparkline(points []float64, width, height int, color string) []byte {
+	min, max := points[0], points[0]
+	for _, p := range points {
+		if p < min {
+			min = p
+		}
+		if p > max {
+			max = p
+		}
+	}
+
+	const pad = 2.0
+	w, h := float64(width)-2*pad, float64(height)-2*pad
+	coords := make([]string, len(points))
+	var x, y float64
+	for i, p := range points {
+		x, y = pad+w/2, pad+h/2
+		if len(points) > 1 {
+			x = pad + w*float64(i)/float64(len(points)-1)
+		}
+		if max > min {
+			y = pad + h*(max-p)/(max-min)
+		}
+		coords[i] = strconv.FormatFloat(x, 'f', 1, 64) + "," + strconv.FormatFloat(y, 'f', 1, 64)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, width, height, width, height)
+	fmt.Fprintf(&buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round" stroke-linecap="round"/>`, strings.Join(coords, " "), color)
+	fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`, x, y, color)
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,117 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func newBadgeTestConfig(t *testing.T) Config {
+	t.Helper()
+	si := storage.NewInMemoryStorage()
+	si.Store(context.Background(), "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{
+		"room1":   map[string]interface{}{"temp": "23.5"},
+		"label":   "&lt;b&gt;",
+		"battery": 15.0,
+		"history": []interface{}{20.0, "21.5", 19.0},
+		"mixed":   []interface{}{20.0, "high"},
+	})
+	return Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &si},
+	}
+}
+
+func Test_DownloadBadgeHandler(t *testing.T) {
+	c := newBadgeTestConfig(t)
+
+	tests := []struct {
+		name                 string
+		downloadKey          string
+		param                string
+		query                string
+		expectedStatus       int
+		expectedContentType  string
+		expectedBodyContains []string
+	}{
+		{"value with default label", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "room1/temp", "", http.StatusOK, "image/svg+xml", []string{`aria-label="temp: 23.5"`, defaultBadgeColor}},
+		{"label and unit", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "room1/temp", "?label=Kitchen&unit=%C2%B0C", http.StatusOK, "image/svg+xml", []string{"Kitchen: 23.5 °C"}},
+		{"threshold reached", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "room1/temp", "?thresholds=0:blue,20:green,30:red", http.StatusOK, "image/svg+xml", []string{badgeColors["green"]}},
+		{"below all thresholds", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "battery", "?thresholds=20:green&color=red", http.StatusOK, "image/svg+xml", []string{badgeColors["red"]}},
+		{"hex color", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "battery", "?color=ff00ff", http.StatusOK, "image/svg+xml", []string{"#ff00ff"}},
+		{"escaped once", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "label", "", http.StatusOK, "image/svg+xml", []string{"label: &lt;b&gt;"}},
+		{"missing path", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "missing", "", http.StatusNotFound, "image/svg+xml", []string{"missing: n/a", missingBadgeColor}},
+		{"unknown key", "unknownKey", "temp", "", http.StatusNotFound, "image/svg+xml", []string{"temp: n/a"}},
+		{"object", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "room1", "", http.StatusBadRequest, MediaTypeProblem, []string{`"code":"validation"`}},
+		{"invalid color", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "battery", "?color=url(x)", http.StatusBadRequest, MediaTypeProblem, []string{"invalid color"}},
+		{"invalid thresholds", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "battery", "?thresholds=low:red", http.StatusBadRequest, MediaTypeProblem, []string{"invalid threshold"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/d/"+tt.downloadKey+"/badge/"+tt.param+".svg"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey, "param": tt.param})
+			rr := httptest.NewRecorder()
+
+			c.DownloadBadgeHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("DownloadBadgeHandler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("DownloadBadgeHandler returned wrong content type: got %q want %q", got, tt.expectedContentType)
+			}
+			for _, want := range tt.expectedBodyContains {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("DownloadBadgeHandler body does not contain %q: %s", want, rr.Body.String())
+				}
+			}
+			if strings.Contains(rr.Body.String(), "&amp;") {
+				t.Errorf("DownloadBadgeHandler escaped a value twice: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func Test_DownloadSparklineHandler(t *testing.T) {
+	c := newBadgeTestConfig(t)
+
+	tests := []struct {
+		name                 string
+		param                string
+		query                string
+		expectedStatus       int
+		expectedBodyContains string
+	}{
+		{"numbers and numeric strings", "history", "", http.StatusOK, `points="2.0,11.6 50.0,2.0 98.0,18.0"`},
+		{"custom size and color", "history", "?width=200&height=40&color=red", http.StatusOK, `width="200" height="40"`},
+		{"single value", "battery", "", http.StatusBadRequest, "array of numbers"},
+		{"non-numeric element", "mixed", "", http.StatusBadRequest, "array of numbers"},
+		{"missing path", "missing", "", http.StatusNotFound, `"code":"not_found"`},
+		{"invalid width", "history", "?width=5", http.StatusBadRequest, "invalid size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/sparkline/"+tt.param+".svg"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "param": tt.param})
+			rr := httptest.NewRecorder()
+
+			c.DownloadSparklineHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("DownloadSparklineHandler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(rr.Body.String(), tt.expectedBodyContains) {
+				t.Errorf("DownloadSparklineHandler body does not contain %q: %s", tt.expectedBodyContains, rr.Body.String())
+			}
+		})
+	}
+}
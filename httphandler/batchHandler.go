@@ -0,0 +1,96 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+)
+
+// batchDownloadRequest is the body of POST /batch/download. Each entry is a
+// download key string or an object with download_key and optional paths.
+type batchDownloadRequest struct {
+	Keys []data.DownloadRequest `json:"keys"`
+}
+
+// BatchDownloadHandler handles POST /batch/download and reads several
+// download keys in one request. Failures of single keys are reported per
+// entry in the result, so the response is 200 as long as the batch itself
+// is valid.
+func (c Config) BatchDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Debug("batch download: invalid body", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeBodyError(w, r, fmt.Errorf("invalid JSON body, expected {\"keys\": [...]}: %w", err))
+		return
+	}
+
+	results, err := c.DataService.DownloadMany(r.Context(), req.Keys)
+	if err != nil {
+		slog.Error("batch download: failed", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Database error")
+		return
+	}
+
+	for _, result := range results {
+		if result.Error == "" {
+			c.StatsInstance.IncrementDownloads()
+		}
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"results": results,
+	})
+}
+
+// batchUploadRequest is the body of POST /batch/upload.
+type batchUploadRequest struct {
+	Operations []data.WriteOperation `json:"operations"`
+}
+
+// BatchUploadHandler handles POST /batch/upload and applies several write
+// operations, possibly for different upload keys, in one request. Each
+// operation gets its own status in the result, so the response is 200 as
+// long as the batch itself is valid.
+func (c Config) BatchUploadHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Debug("batch upload: invalid body", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeBodyError(w, r, fmt.Errorf("invalid JSON body, expected {\"operations\": [...]}: %w", err))
+		return
+	}
+
+	for i := range req.Operations {
+		for k, v := range req.Operations[i].Values {
+			req.Operations[i].Values[k] = sanitizeValue(v)
+		}
+	}
+
+	ctx := data.WithSource(r.Context(), data.SourceHTTP)
+	results, err := c.DataService.UploadMany(ctx, req.Operations)
+	if err != nil {
+		slog.Error("batch upload: failed", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Database error")
+		return
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Status == data.WriteStatusOK {
+			succeeded++
+			c.StatsInstance.IncrementUploads()
+		}
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
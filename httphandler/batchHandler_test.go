@@ -0,0 +1,164 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+func Test_BatchDownloadHandler(t *testing.T) {
+	ctx := context.Background()
+
+	newConfig := func() Config {
+		s := storage.NewInMemoryStorage()
+		s.Store(ctx, "aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11", map[string]interface{}{"temp": "21"})
+		s.Store(ctx, "bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22", map[string]interface{}{"room": map[string]interface{}{"temp": "19"}})
+		return Config{
+			StatsInstance: stats.NewStats(),
+			DataService:   &data.Service{StorageInstance: &s},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		body              string
+		expectedStatus    int
+		expectedDownloads int
+		check             func(t *testing.T, results map[string]data.DownloadResult)
+	}{
+		{
+			name:              "mixed keys and paths",
+			body:              `{"keys": ["aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11", {"download_key": "bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22", "paths": ["room/temp"]}, "missing"]}`,
+			expectedStatus:    http.StatusOK,
+			expectedDownloads: 2,
+			check: func(t *testing.T, results map[string]data.DownloadResult) {
+				if results["aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11"].Data["temp"] != "21" {
+					t.Errorf("Unexpected result for aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11: %+v", results["aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11"])
+				}
+				if results["bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22"].Values["room/temp"] != "19" {
+					t.Errorf("Unexpected result for bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22: %+v", results["bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22"])
+				}
+				if results["missing"].Error == "" {
+					t.Errorf("Expected error for missing key, got %+v", results["missing"])
+				}
+			},
+		},
+		{name: "invalid JSON", body: `{"keys": [`, expectedStatus: http.StatusBadRequest},
+		{name: "empty batch", body: `{"keys": []}`, expectedStatus: http.StatusBadRequest},
+		{name: "oversized batch", body: `{"keys": [` + strings.Repeat(`"k",`, data.MaxBatchSize) + `"k"]}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			req := httptest.NewRequest("POST", "/batch/download", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			c.BatchDownloadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("BatchDownloadHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if c.StatsInstance.GetCurrentStats().HTTPErrorCount != 1 {
+					t.Errorf("Expected HTTPErrorCount to be incremented")
+				}
+				return
+			}
+
+			if got := c.StatsInstance.GetCurrentStats().DownloadCount; got != tt.expectedDownloads {
+				t.Errorf("Expected %d downloads, got %d", tt.expectedDownloads, got)
+			}
+			var body struct {
+				Results map[string]data.DownloadResult `json:"results"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			tt.check(t, body.Results)
+		})
+	}
+}
+
+func Test_BatchUploadHandler(t *testing.T) {
+	const (
+		keyA = "7790e6a7c72e97c2493334f7b22ffbaa2a41fc53a95268a4fbb45a9c34d9c5d1"
+		keyB = "0143a8a24c3b364ce4df085579601d9f2408f5e93f851078b3f5e4088eb13220"
+	)
+
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedUploads int
+		expectedBody    []string
+	}{
+		{
+			name: "mixed operations",
+			body: `{"operations": [
+				{"upload_key": "` + keyA + `", "values": {"temp": "<b>21</b>"}},
+				{"upload_key": "` + keyB + `", "mode": "patch", "path": "node1", "values": {"rssi": -80}},
+				{"upload_key": "invalid", "values": {"temp": "1"}}
+			]}`,
+			expectedStatus:  http.StatusOK,
+			expectedUploads: 2,
+			expectedBody:    []string{`"succeeded":2`, `"failed":1`, `"status":"error"`, "invalid upload key"},
+		},
+		{name: "invalid JSON", body: `{"operations": [`, expectedStatus: http.StatusBadRequest},
+		{name: "empty batch", body: `{"operations": []}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewInMemoryStorage()
+			c := Config{
+				StatsInstance: stats.NewStats(),
+				DataService:   &data.Service{StorageInstance: &s},
+			}
+			req := httptest.NewRequest("POST", "/batch/upload", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			c.BatchUploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("BatchUploadHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if c.StatsInstance.GetCurrentStats().HTTPErrorCount != 1 {
+					t.Errorf("Expected HTTPErrorCount to be incremented")
+				}
+				return
+			}
+			if got := c.StatsInstance.GetCurrentStats().UploadCount; got != tt.expectedUploads {
+				t.Errorf("Expected %d uploads, got %d", tt.expectedUploads, got)
+			}
+			for _, want := range tt.expectedBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("Expected body to contain %s, got %s", want, w.Body.String())
+				}
+			}
+
+			value, err := c.DataService.DownloadField(context.Background(), "d_"+mustDeriveDownloadKey(t, keyA), "temp")
+			if err != nil || value != "&lt;b&gt;21&lt;/b&gt;" {
+				t.Errorf("Expected sanitized stored value, got %v (err %v)", value, err)
+			}
+		})
+	}
+}
+
+func mustDeriveDownloadKey(t *testing.T, uploadKey string) string {
+	t.Helper()
+	downloadKey, err := domain.DeriveDownloadKey(uploadKey)
+	if err != nil {
+		t.Fatalf("DeriveDownloadKey failed: %v", err)
+	}
+	return downloadKey
+}
@@ -0,0 +1,129 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types of the compact binary formats accepted for uploads and offered
+// for downloads.
+const (
+	MediaTypeCBOR    = "application/cbor"
+	MediaTypeMsgPack = "application/msgpack"
+)
+
+// binaryCodec converts between a binary encoding and the generic document
+// representation used for the stored JSON.
+type binaryCodec struct {
+	mediaType string
+	unmarshal func(data []byte, v interface{}) error
+	marshal   func(v interface{}) ([]byte, error)
+}
+
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+var (
+	codecCBOR    = binaryCodec{mediaType: MediaTypeCBOR, unmarshal: cborDecMode.Unmarshal, marshal: cbor.Marshal}
+	codecMsgPack = binaryCodec{mediaType: MediaTypeMsgPack, unmarshal: msgpack.Unmarshal, marshal: msgpack.Marshal}
+)
+
+// binaryCodecsByMediaType maps request and Accept media types to codecs. The
+// unregistered MessagePack media types are still widely used by clients.
+var binaryCodecsByMediaType = map[string]binaryCodec{
+	MediaTypeCBOR:             codecCBOR,
+	MediaTypeMsgPack:          codecMsgPack,
+	"application/x-msgpack":   codecMsgPack,
+	"application/vnd.msgpack": codecMsgPack,
+}
+
+// binaryMediaTypes lists the keys of binaryCodecsByMediaType for content
+// negotiation.
+var binaryMediaTypes = []string{MediaTypeCBOR, MediaTypeMsgPack, "application/x-msgpack", "application/vnd.msgpack"}
+
+// requestBodyCodec returns the binary codec matching the Content-Type of r,
+// if any.
+func requestBodyCodec(r *http.Request) (binaryCodec, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return binaryCodec{}, false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return binaryCodec{}, false
+	}
+	codec, ok := binaryCodecsByMediaType[mediaType]
+	return codec, ok
+}
+
+// decodeBinaryBody decodes a CBOR or MessagePack object from the request
+// body. The result is normalized to the types produced by encoding/json, so
+// it behaves exactly like a JSON body.
+func decodeBinaryBody(r *http.Request, codec binaryCodec) (map[string]interface{}, error) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]interface{}
+	if err := codec.unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid %s body: %w", codec.mediaType, err)
+	}
+	if decoded == nil {
+		return nil, fmt.Errorf("invalid %s body: expected a map", codec.mediaType)
+	}
+
+	normalized, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s body: %w", codec.mediaType, err)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(normalized, &values); err != nil {
+		return nil, fmt.Errorf("invalid %s body: %w", codec.mediaType, err)
+	}
+	return values, nil
+}
+
+// encodeBinaryDocument encodes a stored JSON document with codec. Whole
+// numbers are encoded as integers, which is considerably shorter than the
+// float64 values encoding/json decodes them to.
+func encodeBinaryDocument(jsonData []byte, codec binaryCodec) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, err
+	}
+	return codec.marshal(compactNumbers(doc))
+}
+
+// compactNumbers replaces whole float64 numbers in v by int64.
+func compactNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val)
+		}
+	case map[string]interface{}:
+		for k, elem := range val {
+			val[k] = compactNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = compactNumbers(elem)
+		}
+	}
+	return v
+}
@@ -0,0 +1,181 @@
+package httphandler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/mux"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func Test_UploadHandler_BinaryBodies(t *testing.T) {
+	const uploadKey = "7790e6a7c72e97c2493334f7b22ffbaa2a41fc53a95268a4fbb45a9c34d9c5d1"
+
+	payload := map[string]interface{}{
+		"temp":    21.5,
+		"count":   3,
+		"label":   "<b>",
+		"sensors": []interface{}{map[string]interface{}{"id": "a"}},
+	}
+	cborBody, _ := cbor.Marshal(payload)
+	msgpackBody, _ := msgpack.Marshal(payload)
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           []byte
+		expectedStatus int
+	}{
+		{"CBOR", MediaTypeCBOR, cborBody, http.StatusOK},
+		{"MessagePack", MediaTypeMsgPack, msgpackBody, http.StatusOK},
+		{"MessagePack alias", "application/x-msgpack", msgpackBody, http.StatusOK},
+		{"invalid CBOR", MediaTypeCBOR, []byte{0xff, 0x00}, http.StatusBadRequest},
+		{"CBOR array", MediaTypeCBOR, []byte{0x82, 0x01, 0x02}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := storage.NewInMemoryStorage()
+			c := Config{
+				StatsInstance: stats.NewStats(),
+				DataService:   &data.Service{StorageInstance: &si},
+			}
+
+			req := httptest.NewRequest("POST", "/u/"+uploadKey, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = mux.SetURLVars(req, map[string]string{"uploadKey": uploadKey})
+			w := httptest.NewRecorder()
+
+			c.UploadHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("UploadHandler returned wrong status code: got %v want %v (%s)", w.Code, tt.expectedStatus, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			downloadKey := mustDeriveDownloadKey(t, uploadKey)
+			for path, want := range map[string]interface{}{
+				"temp":         21.5,
+				"count":        3.0,
+				"label":        "&lt;b&gt;",
+				"sensors/0/id": "a",
+			} {
+				got, err := c.DataService.DownloadField(context.Background(), downloadKey, path)
+				if err != nil {
+					t.Fatalf("DownloadField(%s) error: %v", path, err)
+				}
+				if got != want {
+					t.Errorf("DownloadField(%s) = %#v, want %#v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_DownloadJsonHandler_BinaryFormats(t *testing.T) {
+	newConfig := func() Config {
+		s := storage.NewInMemoryStorage()
+		s.Store(context.Background(), "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{
+			"temp":  21.5,
+			"count": 3,
+			"room":  map[string]interface{}{"name": "kitchen"},
+		})
+		return Config{
+			StatsInstance: stats.NewStats(),
+			DataService:   &data.Service{StorageInstance: &s},
+		}
+	}
+
+	want := map[string]interface{}{
+		"temp":  21.5,
+		"count": int64(3),
+		"room":  map[string]interface{}{"name": "kitchen"},
+	}
+
+	tests := []struct {
+		name                string
+		accept              string
+		handler             func(c Config) http.HandlerFunc
+		expectedContentType string
+		decode              func(b []byte) (map[string]interface{}, error)
+	}{
+		{
+			name:                "CBOR on json endpoint",
+			accept:              MediaTypeCBOR,
+			handler:             func(c Config) http.HandlerFunc { return c.DownloadJsonHandler },
+			expectedContentType: MediaTypeCBOR,
+			decode: func(b []byte) (map[string]interface{}, error) {
+				var m map[string]interface{}
+				err := cborDecMode.Unmarshal(b, &m)
+				return m, err
+			},
+		},
+		{
+			name:                "MessagePack on root",
+			accept:              "application/msgpack, application/json;q=0.5",
+			handler:             func(c Config) http.HandlerFunc { return c.DownloadRootHandler },
+			expectedContentType: MediaTypeMsgPack,
+			decode: func(b []byte) (map[string]interface{}, error) {
+				var m map[string]interface{}
+				dec := msgpack.NewDecoder(bytes.NewReader(b))
+				dec.UseLooseInterfaceDecoding(true)
+				err := dec.Decode(&m)
+				return m, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			req := httptest.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json", nil)
+			req.Header.Set("Accept", tt.accept)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a"})
+			w := httptest.NewRecorder()
+
+			tt.handler(c)(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedContentType, got)
+			}
+			got, err := tt.decode(w.Body.Bytes())
+			if err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			normalizeInts(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+// normalizeInts converts the unsigned and sized integers returned by the
+// binary decoders to int64 for comparison.
+func normalizeInts(m map[string]interface{}) {
+	for k, v := range m {
+		switch n := v.(type) {
+		case uint64:
+			m[k] = int64(n)
+		case int8:
+			m[k] = int64(n)
+		case uint8:
+			m[k] = int64(n)
+		case map[string]interface{}:
+			normalizeInts(n)
+		}
+	}
+}
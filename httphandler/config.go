@@ -2,6 +2,7 @@ package httphandler
 
 import (
 	"html/template"
+	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
@@ -11,4 +12,7 @@ type Config struct {
 	DataService      *data.Service
 	StatsInstance    *stats.Stats
 	DownloadTemplate *template.Template
+	// PersistDuration is how long values are kept after the last upload. It
+	// is only reported to clients, e.g. in provisioning bundles.
+	PersistDuration time.Duration
 }
@@ -15,7 +15,7 @@ func (c Config) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		slog.Error("delete: failed to delete data", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err, "Error deleting data")
 		return
 	}
 
@@ -45,8 +45,8 @@ func Test_DeleteHandler(t *testing.T) {
 					return mux.SetURLVars(req, vars)
 				}(),
 			},
-			expectedStatus:         http.StatusInternalServerError,
-			expectedBody:           "invalid upload key: uploadKey must be a 256 bit hex string\n",
+			expectedStatus:         http.StatusBadRequest,
+			expectedBody:           `{"type":"/problems/invalid_key","title":"Invalid key","status":400,"detail":"invalid upload key: uploadKey must be a 256 bit hex string","instance":"/delete/invalidUploadKey","code":"invalid_key"}` + "\n",
 			expectedHTTPErrorCount: 1,
 		},
 		{
@@ -8,7 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
-	"strings"
+	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/gorilla/mux"
@@ -23,11 +23,11 @@ func (c Config) downloadPlainHandler(w http.ResponseWriter, r *http.Request, bas
 	downloadKey := vars["downloadKey"]
 	param := vars["param"]
 
-	jsonData, err := c.DataService.DownloadJSON(r.Context(), downloadKey)
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
 	if err != nil {
 		slog.Debug("download plain: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Invalid download key or database error", http.StatusNotFound)
+		writeError(w, r, err, "Invalid download key or data not found")
 		return
 	}
 
@@ -35,7 +35,7 @@ func (c Config) downloadPlainHandler(w http.ResponseWriter, r *http.Request, bas
 	if err := json.Unmarshal(jsonData, &paramMap); err != nil {
 		slog.Error("download plain: failed to decode JSON", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Error decoding JSON", http.StatusInternalServerError)
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error decoding JSON"))
 		return
 	}
 
@@ -43,21 +43,24 @@ func (c Config) downloadPlainHandler(w http.ResponseWriter, r *http.Request, bas
 	if err != nil {
 		slog.Debug("download plain: parameter not found", "error", err, "param", param, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Parameter not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Invalid parameter path", http.StatusBadRequest)
-		}
+		writeError(w, r, err, "Parameter not found")
 		return
 	}
 
 	// If base64 mode is enabled, decode the value from base64url
 	if base64mode {
-		decoded, err := decodeBase64URL(value.(string))
+		encoded, ok := value.(string)
+		if !ok {
+			slog.Debug("download plain: value is not a string", "param", param, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeValidation, "Value is not a base64url string"))
+			return
+		}
+		decoded, err := decodeBase64URL(encoded)
 		if err != nil {
 			slog.Error("download plain: failed to decode base64url", "error", err, "method", r.Method, "path", r.URL.Path)
 			c.StatsInstance.IncrementHTTPErrors()
-			http.Error(w, "Error decoding base64url", http.StatusInternalServerError)
+			writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error decoding base64url"))
 			return
 		}
 		value = decoded
@@ -66,6 +69,7 @@ func (c Config) downloadPlainHandler(w http.ResponseWriter, r *http.Request, bas
 	c.StatsInstance.IncrementDownloads()
 
 	// Return the value as plain text
+	setExpiryHeaders(w, expiresAt)
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprintln(w, value)
 }
@@ -95,36 +99,165 @@ func (c Config) DownloadJsonHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	downloadKey := vars["downloadKey"]
 
-	jsonData, err := c.DataService.DownloadJSON(r.Context(), downloadKey)
+	w.Header().Add("Vary", "Accept")
+	if wantsDocumentV2(r) {
+		c.downloadDocumentV2(w, r, downloadKey)
+		return
+	}
+
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
 	if err != nil {
 		slog.Debug("download JSON: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Invalid download key or database error", http.StatusNotFound)
+		writeError(w, r, err, "Invalid download key or data not found")
 		return
 	}
 
+	if wantsStaleMarkers(r) {
+		jsonData, err = c.addStaleMarkers(r, downloadKey, jsonData)
+		if err != nil {
+			slog.Debug("download JSON: failed to add stale markers", "error", err, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeValidation, "Invalid max_age or stored data"))
+			return
+		}
+	}
+
+	contentType := "application/json"
+	if codec, ok := binaryCodecsByMediaType[preferredMediaType(r, jsonMediaTypes)]; ok {
+		jsonData, err = encodeBinaryDocument(jsonData, codec)
+		if err != nil {
+			slog.Error("download JSON: failed to encode "+codec.mediaType, "error", err, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error encoding data"))
+			return
+		}
+		contentType = codec.mediaType
+	}
+
 	c.StatsInstance.IncrementDownloads()
 
-	// Set header and write the JSON data to the response writer
-	w.Header().Set("Content-Type", "application/json")
+	// Set header and write the data to the response writer
+	setExpiryHeaders(w, expiresAt)
+	w.Header().Set("Content-Type", contentType)
 	w.Write(jsonData)
 }
 
+// DownloadValueHandler handles requests to /v1/values/{downloadKey}/{param}
+// and returns the value at the path as JSON, so nested objects and arrays
+// keep their structure.
+func (c Config) DownloadValueHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+	param := vars["param"]
+
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
+	if err != nil {
+		slog.Debug("download value: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		slog.Error("download value: failed to decode JSON", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error decoding JSON"))
+		return
+	}
+
+	value, err := data.TraverseField(doc, param)
+	if err != nil {
+		slog.Debug("download value: parameter not found", "error", err, "param", param, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Parameter not found")
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+
+	setExpiryHeaders(w, expiresAt)
+	jsonResponse(w, value)
+}
+
+// jsonMediaTypes are the media types DownloadJsonHandler can negotiate via
+// the Accept header, in order of preference for equal quality values.
+var jsonMediaTypes = append([]string{"application/json"}, binaryMediaTypes...)
+
+// downloadDocumentV2 writes the v2 document format with user values and
+// server-generated metadata in separate objects.
+func (c Config) downloadDocumentV2(w http.ResponseWriter, r *http.Request, downloadKey string) {
+	doc, err := c.DataService.DownloadDocument(r.Context(), downloadKey)
+	if err != nil {
+		slog.Debug("download JSON v2: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	if expiresAt, err := time.Parse(time.RFC3339, doc.Meta.ExpiresAt); err == nil {
+		setExpiryHeaders(w, expiresAt)
+	}
+	jsonResponse(w, doc)
+}
+
+// addStaleMarkers decodes the stored document and adds a top-level "_stale"
+// list with all value paths older than the requested max age.
+func (c Config) addStaleMarkers(r *http.Request, downloadKey string, jsonData []byte) ([]byte, error) {
+	maxAge, err := parseMaxAge(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, err
+	}
+	report, err := c.DataService.Status(r.Context(), downloadKey, maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	doc["_stale"] = report.StalePaths
+	return json.Marshal(doc)
+}
+
 func (c Config) DownloadBase64Handler(w http.ResponseWriter, r *http.Request) {
 	c.downloadPlainHandler(w, r, true)
 }
 
+// rootMediaTypes are the media types DownloadRootHandler can negotiate via
+// the Accept header, in order of preference for equal quality values.
+var rootMediaTypes = append([]string{"text/html", "application/json", MediaTypeDocumentV2, "text/csv", "application/xml", "text/xml", "text/plain"}, binaryMediaTypes...)
+
 // DownloadRootHandler handles requests to /d/{downloadKey}/ and returns an HTML page
-// with links to all available download endpoints
+// with links to all available download endpoints. Clients that explicitly
+// accept JSON, CBOR, MessagePack, CSV, XML or plain text receive the data in
+// that format instead.
 func (c Config) DownloadRootHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	downloadKey := vars["downloadKey"]
 
-	jsonData, err := c.DataService.DownloadJSON(r.Context(), downloadKey)
+	mediaType := preferredMediaType(r, rootMediaTypes)
+	_, binary := binaryCodecsByMediaType[mediaType]
+	if mediaType == "application/json" || mediaType == MediaTypeDocumentV2 || binary {
+		// DownloadJsonHandler sets the Vary header itself.
+		c.DownloadJsonHandler(w, r)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	if format, ok := flatFormatsByMediaType[mediaType]; ok {
+		c.downloadFlat(w, r, format)
+		return
+	}
+
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
 	if err != nil {
 		slog.Debug("download root: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Invalid download key or database error", http.StatusNotFound)
+		writeError(w, r, err, "Invalid download key or data not found")
 		return
 	}
 
@@ -133,7 +266,7 @@ func (c Config) DownloadRootHandler(w http.ResponseWriter, r *http.Request) {
 	if err := json.Unmarshal(jsonData, &paramMap); err != nil {
 		slog.Error("download root: failed to decode JSON", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Error decoding JSON", http.StatusInternalServerError)
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error decoding JSON"))
 		return
 	}
 
@@ -162,53 +295,32 @@ func (c Config) DownloadRootHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	data := struct {
+	page := struct {
+		Prefix      string
 		DownloadKey string
 		Fields      []FieldData
 	}{
+		Prefix:      apiPrefix(r),
 		DownloadKey: downloadKey, // Template will auto-escape
 		Fields:      fields,
 	}
 
 	// Render template
+	setExpiryHeaders(w, expiresAt)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := c.DownloadTemplate.Execute(w, data); err != nil {
+	if err := c.DownloadTemplate.Execute(w, page); err != nil {
 		slog.Error("download root: failed to render template", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error rendering template"))
 		return
 	}
 }
 
-// collectAllPaths recursively collects all paths in a nested map structure.
-// The data parameter should be a map[string]interface{} representing the JSON structure.
-// The prefix parameter should be an empty string ("") for root-level calls; it will be
-// built up recursively as the function traverses nested maps.
-// Returns a slice of path strings in the format "key" or "parent/child" for nested values.
-func collectAllPaths(data interface{}, prefix string) []string {
-	var paths []string
-
-	switch v := data.(type) {
-	case map[string]interface{}:
-		for key, value := range v {
-			var currentPath string
-			if prefix == "" {
-				currentPath = key
-			} else {
-				currentPath = prefix + "/" + key
-			}
-
-			// Check if the value is a nested map or a leaf value
-			if nestedMap, ok := value.(map[string]interface{}); ok {
-				// Recursively collect paths from nested maps
-				nestedPaths := collectAllPaths(nestedMap, currentPath)
-				paths = append(paths, nestedPaths...)
-			} else {
-				// This is a leaf value, add the path
-				paths = append(paths, currentPath)
-			}
-		}
-	}
-
-	return paths
+// collectAllPaths recursively collects all paths in a nested structure.
+// The value parameter should be a map[string]interface{} representing the JSON structure.
+// The prefix parameter should be an empty string ("") for root-level calls.
+// Returns a slice of path strings in the format "key", "parent/child" for nested
+// values, or "list/0" for array elements.
+func collectAllPaths(value interface{}, prefix string) []string {
+	return data.CollectPaths(value, prefix)
 }
@@ -85,7 +85,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 				}(),
 			},
 			expectedStatus:         http.StatusNotFound,
-			expectedBody:           "Invalid download key or database error\n",
+			expectedBody:           `{"type":"/problems/not_found","title":"Not found","status":404,"detail":"Invalid download key or data not found","instance":"/download/invalidDownloadKey/param","code":"not_found"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -94,7 +94,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"key": "value"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -103,16 +103,16 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/invalidParam", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/invalidParam", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "invalidParam",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
 			},
 			expectedStatus:         http.StatusNotFound,
-			expectedBody:           "Parameter not found\n",
+			expectedBody:           `{"type":"/problems/not_found","title":"Not found","status":404,"detail":"Parameter not found","instance":"/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/invalidParam","code":"not_found"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -121,7 +121,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"key": "value"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -130,9 +130,9 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/key", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/key", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "key",
 					}
 					return mux.SetURLVars(req, vars)
@@ -147,7 +147,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			name: "PlainDownloadHandler - error decoding JSON",
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
-				s.StoreRawForTesting("validKey", []byte(`{"key": "value"`))
+				s.StoreRawForTesting("5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", []byte(`{"key": "value"`))
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -156,16 +156,16 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/key", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/key", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "key",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
 			},
 			expectedStatus:         http.StatusInternalServerError,
-			expectedBody:           "Error decoding JSON\n",
+			expectedBody:           `{"type":"/problems/internal","title":"Internal error","status":500,"detail":"Error decoding JSON","instance":"/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/key","code":"internal"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -174,7 +174,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"key": "value"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -183,16 +183,16 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/key/invalidPath", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/key/invalidPath", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "key/invalidPath",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
 			},
 			expectedStatus:         http.StatusBadRequest,
-			expectedBody:           "Invalid parameter path\n",
+			expectedBody:           `{"type":"/problems/validation","title":"Invalid request","status":400,"detail":"invalid parameter path: 'key/invalidPath'","instance":"/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/key/invalidPath","code":"validation"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -201,7 +201,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"key": "invalid_base64"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -210,9 +210,9 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/plain-from-base64url/key", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain-from-base64url/key", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "key",
 					}
 					return mux.SetURLVars(req, vars)
@@ -220,7 +220,35 @@ func Test_PlainDownloadHandler(t *testing.T) {
 				base64mode: true,
 			},
 			expectedStatus:         http.StatusInternalServerError,
-			expectedBody:           "Error decoding base64url\n",
+			expectedBody:           `{"type":"/problems/internal","title":"Internal error","status":500,"detail":"Error decoding base64url","instance":"/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain-from-base64url/key","code":"internal"}` + "\n",
+			expectedHTTPErrorCount: 1,
+			expectedDownloadCount:  0,
+		},
+		{
+			name: "PlainDownloadHandler - base64url of a number",
+			c: func() Config {
+				s := storage.NewInMemoryStorage()
+				d := map[string]interface{}{"key": float64(1)}
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
+				return Config{
+					StatsInstance: stats.NewStats(),
+					DataService:   &data.Service{StorageInstance: &s},
+				}
+			}(),
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain-from-base64url/key", nil)
+					vars := map[string]string{
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
+						"param":       "key",
+					}
+					return mux.SetURLVars(req, vars)
+				}(),
+				base64mode: true,
+			},
+			expectedStatus:         http.StatusBadRequest,
+			expectedBody:           `{"type":"/problems/validation","title":"Invalid request","status":400,"detail":"Value is not a base64url string","instance":"/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain-from-base64url/key","code":"validation"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -230,7 +258,7 @@ func Test_PlainDownloadHandler(t *testing.T) {
 				s := storage.NewInMemoryStorage()
 				base64string := base64.URLEncoding.EncodeToString([]byte("Hallo Welt!"))
 				d := map[string]interface{}{"key": base64string}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -239,9 +267,9 @@ func Test_PlainDownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey/plain-from-base64url/key", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain-from-base64url/key", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 						"param":       "key",
 					}
 					return mux.SetURLVars(req, vars)
@@ -318,7 +346,7 @@ func Test_DownloadHandler(t *testing.T) {
 				}(),
 			},
 			expectedStatus:         http.StatusNotFound,
-			expectedBody:           "Invalid download key or database error\n",
+			expectedBody:           `{"type":"/problems/not_found","title":"Not found","status":404,"detail":"Invalid download key or data not found","instance":"/download/invalidDownloadKey","code":"not_found"}` + "\n",
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -327,7 +355,7 @@ func Test_DownloadHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"key": "value"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance: stats.NewStats(),
 					DataService:   &data.Service{StorageInstance: &s},
@@ -336,9 +364,9 @@ func Test_DownloadHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/download/validKey", nil)
+					req, _ := http.NewRequest("GET", "/download/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -410,7 +438,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 				}(),
 			},
 			expectedStatus:         http.StatusNotFound,
-			expectedBodyContains:   []string{"Invalid download key or database error"},
+			expectedBodyContains:   []string{`"code":"not_found"`},
 			expectedHTTPErrorCount: 1,
 			expectedDownloadCount:  0,
 		},
@@ -419,7 +447,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
 				d := map[string]interface{}{"name": "value"}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance:    stats.NewStats(),
 					DataService:      &data.Service{StorageInstance: &s},
@@ -429,9 +457,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/d/validKey/", nil)
+					req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -439,8 +467,8 @@ func Test_DownloadRootHandler(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBodyContains: []string{
 				"Download Options",
-				"/d/validKey/json",
-				"/d/validKey/plain/name",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/name",
 			},
 			expectedHTTPErrorCount: 0,
 			expectedDownloadCount:  1,
@@ -454,7 +482,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 					"temp":   "25",
 					"status": "ok",
 				}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance:    stats.NewStats(),
 					DataService:      &data.Service{StorageInstance: &s},
@@ -464,9 +492,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/d/validKey/", nil)
+					req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -474,10 +502,10 @@ func Test_DownloadRootHandler(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBodyContains: []string{
 				"Download Options",
-				"/d/validKey/json",
-				"/d/validKey/plain/name",
-				"/d/validKey/plain/temp",
-				"/d/validKey/plain/status",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/name",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/temp",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/status",
 			},
 			expectedHTTPErrorCount: 0,
 			expectedDownloadCount:  1,
@@ -490,7 +518,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 					"<script>alert('xss')</script>": "value",
 					"normal_field":                  "value",
 				}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance:    stats.NewStats(),
 					DataService:      &data.Service{StorageInstance: &s},
@@ -500,9 +528,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/d/validKey/", nil)
+					req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -510,9 +538,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBodyContains: []string{
 				"Download Options",
-				"/d/validKey/json",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json",
 				"&lt;script&gt;alert(&#39;xss&#39;)&lt;/script&gt;", // Escaped HTML
-				"/d/validKey/plain/normal_field",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/normal_field",
 			},
 			expectedHTTPErrorCount: 0,
 			expectedDownloadCount:  1,
@@ -534,7 +562,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 					"name_timestamp":  "2026-01-16T10:49:37Z",
 					"timestamp":       "2026-01-16T10:51:07Z",
 				}
-				s.Store(ctx, "validKey", d)
+				s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", d)
 				return Config{
 					StatsInstance:    stats.NewStats(),
 					DataService:      &data.Service{StorageInstance: &s},
@@ -544,9 +572,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/d/validKey/", nil)
+					req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -554,11 +582,11 @@ func Test_DownloadRootHandler(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBodyContains: []string{
 				"Download Options",
-				"/d/validKey/json",
-				"/d/validKey/plain/folder_1/folder_2/name1",
-				"/d/validKey/plain/folder_1/folder_2/timestamp",
-				"/d/validKey/plain/name",
-				"/d/validKey/plain/name1",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/folder_1/folder_2/name1",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/folder_1/folder_2/timestamp",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/name",
+				"/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/plain/name1",
 			},
 			expectedHTTPErrorCount: 0,
 			expectedDownloadCount:  1,
@@ -567,7 +595,7 @@ func Test_DownloadRootHandler(t *testing.T) {
 			name: "DownloadRootHandler - error decoding JSON",
 			c: func() Config {
 				s := storage.NewInMemoryStorage()
-				s.StoreRawForTesting("validKey", []byte(`{"key": "value"`))
+				s.StoreRawForTesting("5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", []byte(`{"key": "value"`))
 				return Config{
 					StatsInstance:    stats.NewStats(),
 					DataService:      &data.Service{StorageInstance: &s},
@@ -577,9 +605,9 @@ func Test_DownloadRootHandler(t *testing.T) {
 			args: args{
 				w: httptest.NewRecorder(),
 				r: func() *http.Request {
-					req, _ := http.NewRequest("GET", "/d/validKey/", nil)
+					req, _ := http.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/", nil)
 					vars := map[string]string{
-						"downloadKey": "validKey",
+						"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
 					}
 					return mux.SetURLVars(req, vars)
 				}(),
@@ -618,3 +646,43 @@ func Test_DownloadRootHandler(t *testing.T) {
 	}
 }
 
+
+func Test_DownloadValueHandler(t *testing.T) {
+	ctx := context.Background()
+	si := storage.NewInMemoryStorage()
+	si.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{"room1": map[string]interface{}{"temp": "21"}, "list": []interface{}{"a", "b"}})
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &si},
+	}
+
+	tests := []struct {
+		name           string
+		downloadKey    string
+		param          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"object", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "room1", http.StatusOK, `{"temp":"21"}` + "\n"},
+		{"array element", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "list/-1", http.StatusOK, `"b"` + "\n"},
+		{"missing path", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "missing", http.StatusNotFound, `"code":"not_found"`},
+		{"unknown key", "unknownKey", "room1", http.StatusNotFound, `"code":"not_found"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/v1/values/"+tt.downloadKey+"/"+tt.param, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey, "param": tt.param})
+			rr := httptest.NewRecorder()
+
+			c.DownloadValueHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("DownloadValueHandler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(rr.Body.String(), tt.expectedBody) {
+				t.Errorf("DownloadValueHandler returned unexpected body: got %q want %q", rr.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package httphandler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// flatField is a single leaf value of a document in the flat output formats.
+type flatField struct {
+	Path  string
+	Value string
+}
+
+// flatFormat renders the leaf values of a document in a line-oriented or
+// flat format for clients that cannot parse nested JSON.
+type flatFormat struct {
+	name        string
+	contentType string
+	render      func(fields []flatField) ([]byte, error)
+}
+
+var (
+	formatCSV = flatFormat{name: "csv", contentType: "text/csv; charset=utf-8", render: renderCSV}
+	formatEnv = flatFormat{name: "env", contentType: "text/plain; charset=utf-8", render: renderEnv}
+	formatXML = flatFormat{name: "xml", contentType: "application/xml; charset=utf-8", render: renderXML}
+)
+
+// flatFormatsByMediaType maps Accept header media types to flat formats.
+var flatFormatsByMediaType = map[string]flatFormat{
+	"text/csv":        formatCSV,
+	"application/xml": formatXML,
+	"text/xml":        formatXML,
+	"text/plain":      formatEnv,
+}
+
+// DownloadCSVHandler handles /d/{downloadKey}/csv and returns all leaf values
+// as CSV with a "path,value" header.
+func (c Config) DownloadCSVHandler(w http.ResponseWriter, r *http.Request) {
+	c.downloadFlat(w, r, formatCSV)
+}
+
+// DownloadEnvHandler handles /d/{downloadKey}/env and returns all leaf values
+// as KEY=value lines.
+func (c Config) DownloadEnvHandler(w http.ResponseWriter, r *http.Request) {
+	c.downloadFlat(w, r, formatEnv)
+}
+
+// DownloadXMLHandler handles /d/{downloadKey}/xml and returns all leaf values
+// as XML elements with a path attribute.
+func (c Config) DownloadXMLHandler(w http.ResponseWriter, r *http.Request) {
+	c.downloadFlat(w, r, formatXML)
+}
+
+func (c Config) downloadFlat(w http.ResponseWriter, r *http.Request, format flatFormat) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(r.Context(), downloadKey)
+	if err != nil {
+		slog.Debug("download "+format.name+": failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		slog.Error("download "+format.name+": failed to decode JSON", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error decoding JSON"))
+		return
+	}
+
+	body, err := format.render(flattenDocument(doc))
+	if err != nil {
+		slog.Error("download "+format.name+": failed to render", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error rendering data"))
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Write(body)
+}
+
+// flattenDocument returns all leaf values of doc sorted by path.
+func flattenDocument(doc map[string]interface{}) []flatField {
+	paths := collectAllPaths(doc, "")
+	fields := make([]flatField, 0, len(paths))
+	for _, path := range paths {
+		value, err := data.TraverseField(doc, path)
+		if err != nil {
+			continue
+		}
+		fields = append(fields, flatField{Path: path, Value: formatFlatValue(value)})
+	}
+	return fields
+}
+
+// formatFlatValue renders a leaf value without JSON quoting.
+func formatFlatValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func renderCSV(fields []flatField) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"path", "value"})
+	for _, f := range fields {
+		cw.Write([]string{f.Path, f.Value})
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+// envKeyInvalidChars matches characters not allowed in environment variable
+// names.
+var envKeyInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// renderEnv writes one KEY=value line per field. Path separators and other
+// characters not allowed in variable names become underscores; values that
+// contain whitespace or shell-significant characters are double-quoted.
+func renderEnv(fields []flatField) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		key := envKeyInvalidChars.ReplaceAllString(f.Path, "_")
+		value := f.Value
+		if strings.ContainsAny(value, " \t\r\n\"'#$`\\=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", key, value)
+	}
+	return buf.Bytes(), nil
+}
+
+type xmlDocument struct {
+	XMLName xml.Name   `xml:"data"`
+	Values  []xmlValue `xml:"value"`
+}
+
+type xmlValue struct {
+	Path  string `xml:"path,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderXML(fields []flatField) ([]byte, error) {
+	doc := xmlDocument{Values: make([]xmlValue, 0, len(fields))}
+	for _, f := range fields {
+		doc.Values = append(doc.Values, xmlValue{Path: f.Path, Value: f.Value})
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
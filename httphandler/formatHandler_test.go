@@ -0,0 +1,146 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func newFormatTestConfig() Config {
+	s := storage.NewInMemoryStorage()
+	s.Store(context.Background(), "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{
+		"temp": "21.5",
+		"room": map[string]interface{}{"name": "Living room, east", "on": true},
+		"list": []interface{}{1.0, "<b>"},
+	})
+	return Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+}
+
+func Test_DownloadFlatHandlers(t *testing.T) {
+	tests := []struct {
+		name                string
+		handler             func(c Config) http.HandlerFunc
+		downloadKey         string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "CSV",
+			handler:             func(c Config) http.HandlerFunc { return c.DownloadCSVHandler },
+			downloadKey:         "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "path,value\nlist/0,1\nlist/1,<b>\nroom/name,\"Living room, east\"\nroom/on,true\ntemp,21.5\n",
+		},
+		{
+			name:                "Env",
+			handler:             func(c Config) http.HandlerFunc { return c.DownloadEnvHandler },
+			downloadKey:         "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "list_0=1\nlist_1=<b>\nroom_name=\"Living room, east\"\nroom_on=true\ntemp=21.5\n",
+		},
+		{
+			name:                "XML",
+			handler:             func(c Config) http.HandlerFunc { return c.DownloadXMLHandler },
+			downloadKey:         "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>
+<data>
+  <value path="list/0">1</value>
+  <value path="list/1">&lt;b&gt;</value>
+  <value path="room/name">Living room, east</value>
+  <value path="room/on">true</value>
+  <value path="temp">21.5</value>
+</data>
+`,
+		},
+		{
+			name:           "CSV unknown key",
+			handler:        func(c Config) http.HandlerFunc { return c.DownloadCSVHandler },
+			downloadKey:    "unknownKey",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFormatTestConfig()
+			req := httptest.NewRequest("GET", "/d/"+tt.downloadKey+"/format", nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey})
+			w := httptest.NewRecorder()
+
+			tt.handler(c)(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedContentType, got)
+			}
+			if got := w.Body.String(); got != tt.expectedBody {
+				t.Errorf("Expected body:\n%s\ngot:\n%s", tt.expectedBody, got)
+			}
+			if w.Header().Get("X-Expires-At") == "" {
+				t.Error("Expected expiry headers")
+			}
+		})
+	}
+}
+
+func Test_DownloadRootHandler_Accept(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+	}{
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8"},
+		{"no accept", "", "text/html; charset=utf-8"},
+		{"wildcard", "*/*", "text/html; charset=utf-8"},
+		{"json", "application/json", "application/json"},
+		{"csv", "text/csv", "text/csv; charset=utf-8"},
+		{"xml", "text/xml", "application/xml; charset=utf-8"},
+		{"plain", "text/plain", "text/plain; charset=utf-8"},
+		{"quality", "application/json;q=0.5, text/csv;q=0.8", "text/csv; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFormatTestConfig()
+			c.DownloadTemplate = getTestDownloadTemplate()
+			req := httptest.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a"})
+			w := httptest.NewRecorder()
+
+			c.DownloadRootHandler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("DownloadRootHandler returned wrong status code: got %v", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.expectedContentType, got)
+			}
+			if vary := w.Header().Values("Vary"); len(vary) != 1 || !strings.EqualFold(vary[0], "Accept") {
+				t.Errorf("Expected a single Vary: Accept header, got %v", vary)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package httphandler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/gorilla/mux"
+)
+
+const defaultDiscoveryPrefix = "homeassistant"
+
+// haObjectIDInvalidChars matches characters not allowed in Home Assistant
+// object ids and MQTT topic levels.
+var haObjectIDInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// haSensor is the JSON shape of /d/{downloadKey}/ha, read by Home Assistant's
+// RESTful sensor with value_template "{{ value_json.state }}" and
+// json_attributes_path "$.attributes".
+type haSensor struct {
+	State             interface{}            `json:"state"`
+	Attributes        map[string]interface{} `json:"attributes"`
+	UnitOfMeasurement string                 `json:"unit_of_measurement,omitempty"`
+}
+
+// mqttDiscoveryMessage is a retained MQTT message that announces one field
+// as a Home Assistant sensor.
+type mqttDiscoveryMessage struct {
+	Topic   string                 `json:"topic"`
+	Retain  bool                   `json:"retain"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// DownloadHomeAssistantHandler handles /d/{downloadKey}/ha and
+// /d/{downloadKey}/ha/{param} in the shape of a Home Assistant RESTful
+// sensor. For a field the state is its value, numeric strings as numbers,
+// with the unit query parameter as unit_of_measurement. For the whole key the
+// state is the time of the last write and every field is an attribute.
+func (c Config) DownloadHomeAssistantHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+	param := strings.Trim(vars["param"], "/")
+
+	doc, expiresAt, err := c.downloadDocument(r, downloadKey)
+	if err != nil {
+		slog.Debug("download ha: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	var sensor haSensor
+	if param == "" {
+		meta, err := c.DataService.DownloadMeta(r.Context(), downloadKey)
+		if err != nil {
+			slog.Error("download ha: failed to retrieve metadata", "error", err, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			writeError(w, r, err, "Error retrieving metadata")
+			return
+		}
+		// Records written before metadata existed have the root timestamp.
+		var state interface{} = meta.UpdatedAt
+		if meta.UpdatedAt == "" {
+			state = doc[data.TimestampField]
+		}
+		sensor = haSensor{State: state, Attributes: make(map[string]interface{})}
+		for _, path := range collectAllPaths(doc, "") {
+			if value, err := data.TraverseField(doc, path); err == nil {
+				sensor.Attributes[path] = value
+			}
+		}
+	} else {
+		value, err := data.TraverseField(doc, param)
+		if err != nil {
+			slog.Debug("download ha: failed to retrieve field", "error", err, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			writeError(w, r, err, "Invalid download key or data not found")
+			return
+		}
+		if !isLeafValue(value) {
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeValidation, fmt.Sprintf("parameter '%s' is not a single value", param)))
+			return
+		}
+		if n, ok := numericValue(value); ok {
+			value = n
+		}
+		sensor = haSensor{
+			State:             value,
+			Attributes:        map[string]interface{}{"path": param},
+			UnitOfMeasurement: r.URL.Query().Get("unit"),
+		}
+		if ts := fieldTimestamp(doc, param); ts != nil {
+			sensor.Attributes[data.TimestampField] = ts
+		}
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	jsonResponse(w, sensor)
+}
+
+// DownloadMQTTDiscoveryHandler handles /d/{downloadKey}/mqtt-discovery and
+// returns one retained Home Assistant MQTT discovery message per field, so
+// that all fields of a key show up as sensors of one device. The sensors read
+// the /d/{downloadKey}/json document from the state_topic query parameter
+// (default iot-ephemeral-value-store/{id}). Query parameters: prefix (the
+// discovery prefix, default homeassistant) and unit.{path} per field, e.g.
+// unit.room1/temp=°C. Server-generated timestamps are not announced.
+func (c Config) DownloadMQTTDiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	downloadKey := mux.Vars(r)["downloadKey"]
+	if err := domain.ValidateDownloadKey(downloadKey); err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInvalidKey, "invalid download key: "+err.Error()))
+		return
+	}
+
+	doc, expiresAt, err := c.downloadDocument(r, downloadKey)
+	if err != nil {
+		slog.Debug("download mqtt discovery: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+	meta, err := c.DataService.DownloadMeta(r.Context(), downloadKey)
+	if err != nil {
+		slog.Error("download mqtt discovery: failed to retrieve metadata", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Error retrieving metadata")
+		return
+	}
+
+	query := r.URL.Query()
+	id := haDeviceID(downloadKey)
+	prefix := strings.Trim(query.Get("prefix"), "/")
+	if prefix == "" {
+		prefix = defaultDiscoveryPrefix
+	}
+	stateTopic := strings.Trim(query.Get("state_topic"), "/")
+	if stateTopic == "" {
+		stateTopic = "iot-ephemeral-value-store/" + id
+	}
+	if strings.ContainsAny(prefix+stateTopic, "+#") {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, "MQTT topics must not contain the wildcards '+' and '#'"))
+		return
+	}
+
+	device := map[string]interface{}{
+		"identifiers":       []string{id},
+		"name":              "IoT values " + id,
+		"manufacturer":      "iot-ephemeral-value-store",
+		"configuration_url": provisioningDownloadURL(r, domain.AddDownloadPrefix(domain.StripDownloadPrefix(downloadKey))),
+	}
+	messages := []mqttDiscoveryMessage{}
+	seen := make(map[string]bool)
+	for _, path := range collectAllPaths(doc, "") {
+		if meta.IsServerTimestamp(path) {
+			continue
+		}
+		value, err := data.TraverseField(doc, path)
+		if err != nil {
+			continue
+		}
+
+		// Paths like "a/b" and "a_b" map to the same object id.
+		base := strings.Trim(haObjectIDInvalidChars.ReplaceAllString(strings.ToLower(path), "_"), "_")
+		if base == "" {
+			base = "field"
+		}
+		objectID := base
+		for i := 2; seen[objectID]; i++ {
+			objectID = fmt.Sprintf("%s_%d", base, i)
+		}
+		seen[objectID] = true
+
+		payload := map[string]interface{}{
+			"name":           path,
+			"unique_id":      id + "_" + objectID,
+			"state_topic":    stateTopic,
+			"value_template": haValueTemplate(path),
+			"device":         device,
+		}
+		if unit := query.Get("unit." + path); unit != "" {
+			payload["unit_of_measurement"] = unit
+		}
+		if _, ok := numericValue(value); ok {
+			payload["state_class"] = "measurement"
+		}
+		messages = append(messages, mqttDiscoveryMessage{
+			Topic:   fmt.Sprintf("%s/sensor/%s/%s/config", prefix, id, objectID),
+			Retain:  true,
+			Payload: payload,
+		})
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	jsonResponse(w, messages)
+}
+
+// isLeafValue reports whether v is neither an object nor an array.
+func isLeafValue(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// fieldTimestamp returns the most specific server-generated timestamp of the
+// field at path: its "<key>_timestamp" sibling, the "timestamp" of its map
+// or the root timestamp.
+func fieldTimestamp(doc map[string]interface{}, path string) interface{} {
+	for _, candidate := range []string{path + data.TimestampSuffix, parentPath(path) + data.TimestampField, data.TimestampField} {
+		if ts, err := data.TraverseField(doc, candidate); err == nil && isLeafValue(ts) {
+			return ts
+		}
+	}
+	return nil
+}
+
+// parentPath returns path up to and including its last slash, or "" for a
+// root field.
+func parentPath(path string) string {
+	return path[:strings.LastIndex(path, "/")+1]
+}
+
+// haDeviceID returns a stable Home Assistant id for downloadKey. The first 12
+// hex characters are unique enough and keep entity ids readable.
+func haDeviceID(downloadKey string) string {
+	key := strings.ToLower(domain.StripDownloadPrefix(downloadKey))
+	return "iev_" + key[:12]
+}
+
+// haValueTemplate returns the Jinja template that reads path from the JSON
+// document, e.g. "{{ value_json['room1']['temp'] }}".
+func haValueTemplate(path string) string {
+	var b strings.Builder
+	b.WriteString("{{ value_json")
+	for _, segment := range strings.Split(path, "/") {
+		if _, err := strconv.Atoi(segment); err == nil {
+			fmt.Fprintf(&b, "[%s]", segment)
+			continue
+		}
+		segment = strings.ReplaceAll(segment, `\`, `\\`)
+		segment = strings.ReplaceAll(segment, `'`, `\'`)
+		fmt.Fprintf(&b, "['%s']", segment)
+	}
+	b.WriteString(" }}")
+	return b.String()
+}
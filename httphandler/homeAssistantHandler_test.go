@@ -0,0 +1,189 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func newHomeAssistantTestConfig(t *testing.T) (Config, string) {
+	s := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := c.DataService.UploadValues(context.Background(), uploadKey, map[string]interface{}{
+		"temp":  "21.5",
+		"state": "on",
+		"room1": map[string]interface{}{"hum": 40.0},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return c, downloadKey
+}
+
+func Test_DownloadHomeAssistantHandler(t *testing.T) {
+	c, downloadKey := newHomeAssistantTestConfig(t)
+
+	tests := []struct {
+		name                 string
+		downloadKey          string
+		param                string
+		query                string
+		expectedStatus       int
+		expectedBodyContains []string
+	}{
+		{"numeric field", downloadKey, "temp", "?unit=°C", http.StatusOK, []string{`"state":21.5`, `"unit_of_measurement":"°C"`, `"path":"temp"`, `"timestamp":"`}},
+		{"text field", downloadKey, "state", "", http.StatusOK, []string{`"state":"on"`}},
+		{"nested field", downloadKey, "room1/hum", "", http.StatusOK, []string{`"state":40`}},
+		{"all fields", downloadKey, "", "", http.StatusOK, []string{`"room1/hum":40`, `"temp":"21.5"`}},
+		{"object", downloadKey, "room1", "", http.StatusBadRequest, []string{"not a single value"}},
+		{"missing field", downloadKey, "pressure", "", http.StatusNotFound, []string{`"code":"not_found"`}},
+		{"unknown key", domain.GenerateRandomKey(), "temp", "", http.StatusNotFound, []string{`"code":"not_found"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/d/"+tt.downloadKey+"/ha/"+tt.param+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey, "param": tt.param})
+			w := httptest.NewRecorder()
+			c.DownloadHomeAssistantHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("DownloadHomeAssistantHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			for _, s := range tt.expectedBodyContains {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("DownloadHomeAssistantHandler body %q does not contain %q", w.Body.String(), s)
+				}
+			}
+		})
+	}
+}
+
+func Test_DownloadHomeAssistantHandler_TimestampModes(t *testing.T) {
+	for _, mode := range []data.TimestampMode{data.TimestampModeNone, data.TimestampModePath, data.TimestampModeField} {
+		t.Run(string(mode), func(t *testing.T) {
+			c, _ := newHomeAssistantTestConfig(t)
+			c.DataService.TimestampMode = mode
+			downloadKey, _, err := c.DataService.UploadValues(context.Background(), domain.GenerateRandomKey(), map[string]interface{}{"temp": "21.5"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "/d/"+downloadKey+"/ha", nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": downloadKey})
+			w := httptest.NewRecorder()
+			c.DownloadHomeAssistantHandler(w, req)
+
+			var sensor haSensor
+			if err := json.Unmarshal(w.Body.Bytes(), &sensor); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			state, _ := sensor.State.(string)
+			if _, err := time.Parse(time.RFC3339, state); err != nil {
+				t.Errorf("Expected the last write time as state, got %v", sensor.State)
+			}
+		})
+	}
+}
+
+func Test_DownloadMQTTDiscoveryHandler(t *testing.T) {
+	c, downloadKey := newHomeAssistantTestConfig(t)
+
+	req := httptest.NewRequest("GET", "/d/"+downloadKey+"/mqtt-discovery?prefix=ha&unit.room1/hum=%25", nil)
+	req = mux.SetURLVars(req, map[string]string{"downloadKey": downloadKey})
+	w := httptest.NewRecorder()
+	c.DownloadMQTTDiscoveryHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("DownloadMQTTDiscoveryHandler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var messages []mqttDiscoveryMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &messages); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected a message for room1/hum, state and temp without timestamps, got %d", len(messages))
+	}
+
+	id := haDeviceID(downloadKey)
+	hum := messages[0]
+	if hum.Topic != "ha/sensor/"+id+"/room1_hum/config" || !hum.Retain {
+		t.Errorf("Unexpected topic %q or retain %v", hum.Topic, hum.Retain)
+	}
+	if hum.Payload["value_template"] != "{{ value_json['room1']['hum'] }}" {
+		t.Errorf("Unexpected value_template %v", hum.Payload["value_template"])
+	}
+	if hum.Payload["unit_of_measurement"] != "%" || hum.Payload["state_class"] != "measurement" {
+		t.Errorf("Unexpected unit %v or state_class %v", hum.Payload["unit_of_measurement"], hum.Payload["state_class"])
+	}
+	if hum.Payload["state_topic"] != "iot-ephemeral-value-store/"+id || hum.Payload["unique_id"] != id+"_room1_hum" {
+		t.Errorf("Unexpected state_topic %v or unique_id %v", hum.Payload["state_topic"], hum.Payload["unique_id"])
+	}
+	if _, ok := messages[1].Payload["state_class"]; ok {
+		t.Error("Expected no state_class for the text field state")
+	}
+
+	req = httptest.NewRequest("GET", "/d/invalid/mqtt-discovery", nil)
+	req = mux.SetURLVars(req, map[string]string{"downloadKey": "invalid"})
+	w = httptest.NewRecorder()
+	c.DownloadMQTTDiscoveryHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("DownloadMQTTDiscoveryHandler returned wrong status code: got %v want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func Test_DownloadMQTTDiscoveryHandler_UserTimestamps(t *testing.T) {
+	c, _ := newHomeAssistantTestConfig(t)
+	downloadKey, _, err := c.DataService.UploadValues(context.Background(), domain.GenerateRandomKey(), map[string]interface{}{
+		"temp":           "21.5",
+		"timestamp":      "1700000000",
+		"boot_timestamp": "1690000000",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/d/"+downloadKey+"/mqtt-discovery", nil)
+	req = mux.SetURLVars(req, map[string]string{"downloadKey": downloadKey})
+	w := httptest.NewRecorder()
+	c.DownloadMQTTDiscoveryHandler(w, req)
+
+	var messages []mqttDiscoveryMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &messages); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var names []string
+	for _, m := range messages {
+		names = append(names, m.Payload["name"].(string))
+	}
+	if strings.Join(names, ",") != "boot_timestamp,temp,timestamp" {
+		t.Errorf("Expected the user values boot_timestamp, temp and timestamp without server timestamps, got %v", names)
+	}
+}
+
+func Test_haValueTemplate(t *testing.T) {
+	tests := map[string]string{
+		"temp":           "{{ value_json['temp'] }}",
+		"sensors/0/temp": "{{ value_json['sensors'][0]['temp'] }}",
+		"it's":           `{{ value_json['it\'s'] }}`,
+	}
+	for path, want := range tests {
+		if got := haValueTemplate(path); got != want {
+			t.Errorf("haValueTemplate(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package httphandler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+// maxDecompressedWriteSize limits the size of a gzip-compressed line
+// protocol body after decompression.
+const maxDecompressedWriteSize = 1024 * 1024
+
+// InfluxWriteHandler handles POST /api/v2/write, compatible with the write
+// endpoint of InfluxDB 2, so agents like Telegraf can write to the store.
+// The upload key is taken from the bucket query parameter or, if that is not
+// a valid upload key, from the "Authorization: Token <key>" header. Each line
+// is patched at the path formed by its measurement and tag values (see
+// data.Point.Path). Errors use the JSON format of InfluxDB.
+func (c Config) InfluxWriteHandler(w http.ResponseWriter, r *http.Request) {
+	uploadKey, ok := influxUploadKey(r)
+	if !ok {
+		slog.Debug("influx write: missing upload key", "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		influxError(w, http.StatusUnauthorized, "unauthorized", "bucket or token must be an upload key")
+		return
+	}
+
+	ops, err := readInfluxWrites(r, uploadKey)
+	if err != nil {
+		status, code := http.StatusBadRequest, "invalid"
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, errWriteTooLarge) {
+			status, code = http.StatusRequestEntityTooLarge, "request too large"
+		}
+		slog.Debug("influx write: invalid body", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		influxError(w, status, code, err.Error())
+		return
+	}
+	if len(ops) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// A body may hold more series than a batch, so the operations are
+	// written in batches of data.MaxBatchSize. Like in InfluxDB, batches
+	// written before a failure are kept.
+	ctx := data.WithSource(r.Context(), data.SourceHTTP)
+	var failures []string
+	for start := 0; start < len(ops); start += data.MaxBatchSize {
+		batch := ops[start:min(start+data.MaxBatchSize, len(ops))]
+		results, err := c.DataService.UploadMany(ctx, batch)
+		if err != nil {
+			slog.Error("influx write: failed", "error", err, "method", r.Method, "path", r.URL.Path)
+			c.StatsInstance.IncrementHTTPErrors()
+			switch data.ErrorCode(err) {
+			case data.CodeValidation:
+				influxError(w, http.StatusBadRequest, "invalid", err.Error())
+			case data.CodeStorageDegraded, data.CodeStorageTimeout:
+				influxError(w, http.StatusServiceUnavailable, "unavailable", "Storage unavailable")
+			default:
+				influxError(w, http.StatusInternalServerError, "internal error", "Database error")
+			}
+			return
+		}
+
+		for _, result := range results {
+			if result.Status == data.WriteStatusOK {
+				c.StatsInstance.IncrementUploads()
+				continue
+			}
+			failures = append(failures, fmt.Sprintf("%s: %s", batch[result.Index].Path, result.Error))
+		}
+	}
+	if len(failures) > 0 {
+		slog.Debug("influx write: partial write", "errors", failures, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		influxError(w, http.StatusBadRequest, "invalid", "partial write: "+strings.Join(failures, "; "))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// influxUploadKey returns the upload key of a write request.
+func influxUploadKey(r *http.Request) (string, bool) {
+	if bucket := r.URL.Query().Get("bucket"); domain.ValidateUploadKey(bucket) == nil {
+		return bucket, true
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if (strings.EqualFold(scheme, "Token") || strings.EqualFold(scheme, "Bearer")) && domain.ValidateUploadKey(token) == nil {
+		return token, true
+	}
+	return "", false
+}
+
+var errWriteTooLarge = errors.New("decompressed body too large")
+
+// readInfluxWrites parses the line protocol body of r into patch operations
+// for uploadKey. Lines with the same path are merged in order, so the last
+// value of a field wins.
+func readInfluxWrites(r *http.Request, uploadKey string) ([]data.WriteOperation, error) {
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		body = &limitedReader{r: gz, n: maxDecompressedWriteSize}
+	}
+
+	var ops []data.WriteOperation
+	indexByPath := make(map[string]int)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxDecompressedWriteSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := data.ParseLineProtocol(line)
+		if err != nil {
+			return nil, err
+		}
+
+		path := p.Path(data.UploadKeyTag)
+		i, ok := indexByPath[path]
+		if !ok {
+			i = len(ops)
+			indexByPath[path] = i
+			ops = append(ops, data.WriteOperation{
+				UploadKey: uploadKey,
+				Path:      path,
+				Mode:      data.WriteModePatch,
+				Values:    make(map[string]interface{}),
+			})
+		}
+		for k, v := range p.Fields {
+			ops[i].Values[k] = sanitizeValue(v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// limitedReader is like io.LimitedReader but fails with errWriteTooLarge
+// instead of silently truncating if r has more than n bytes.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// A body of exactly n bytes is allowed, only a further byte fails.
+		var extra [1]byte
+		if n, err := l.r.Read(extra[:]); n == 0 {
+			return 0, err
+		}
+		return 0, errWriteTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// influxError writes an error in the JSON format of the InfluxDB API.
+func influxError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "message": message})
+}
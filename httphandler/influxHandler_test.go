@@ -0,0 +1,190 @@
+package httphandler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf.String()
+}
+
+func Test_InfluxWriteHandler(t *testing.T) {
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey := mustDeriveDownloadKey(t, uploadKey)
+
+	tests := []struct {
+		name           string
+		query          string
+		authorization  string
+		encoding       string
+		body           string
+		expectedStatus int
+		expectedCode   string
+		expectedDoc    string
+	}{
+		{
+			name:           "bucket as upload key",
+			query:          "?org=home&bucket=" + uploadKey + "&precision=s",
+			body:           "weather,room=kitchen temp=21.5,count=2i\nweather,room=kitchen temp=22\n# comment\n\npower,phase=L1 watts=230u 1700000000",
+			expectedStatus: http.StatusNoContent,
+			expectedDoc:    `{"power":{"L1":{"watts":230}},"weather":{"kitchen":{"count":2,"temp":22}}}`,
+		},
+		{
+			name:           "token as upload key",
+			query:          "?org=home&bucket=telegraf",
+			authorization:  "Token u_" + uploadKey,
+			body:           `cpu,host=pi,upload_key=ignored usage=12.5,label="<b>"`,
+			expectedStatus: http.StatusNoContent,
+			expectedDoc:    `{"cpu":{"pi":{"label":"&lt;b&gt;","usage":12.5}}}`,
+		},
+		{
+			name:           "gzip body",
+			query:          "?bucket=" + uploadKey,
+			encoding:       "gzip",
+			body:           "weather temp=19",
+			expectedStatus: http.StatusNoContent,
+			expectedDoc:    `{"weather":{"temp":19}}`,
+		},
+		{
+			name:           "missing upload key",
+			query:          "?bucket=telegraf",
+			authorization:  "Token secret",
+			body:           "weather temp=19",
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "invalid line",
+			query:          "?bucket=" + uploadKey,
+			body:           "weather temp=19\nweather temp",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid",
+		},
+		{
+			name:           "invalid gzip",
+			query:          "?bucket=" + uploadKey,
+			encoding:       "gzip",
+			body:           "weather temp=19",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid",
+		},
+		{
+			name:           "more paths than a batch",
+			query:          "?bucket=" + uploadKey,
+			body:           manyPaths(data.MaxBatchSize + 1),
+			expectedStatus: http.StatusNoContent,
+			expectedDoc:    manyPathsDoc(data.MaxBatchSize + 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewInMemoryStorage()
+			c := Config{
+				StatsInstance: stats.NewStats(),
+				DataService:   &data.Service{StorageInstance: &s, TimestampMode: data.TimestampModeNone},
+			}
+
+			body := tt.body
+			if tt.encoding == "gzip" && tt.expectedStatus == http.StatusNoContent {
+				body = gzipString(t, body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/write"+tt.query, strings.NewReader(body))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rr := httptest.NewRecorder()
+			c.InfluxWriteHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedCode != "" {
+				var influxErr map[string]string
+				if err := json.Unmarshal(rr.Body.Bytes(), &influxErr); err != nil {
+					t.Fatalf("Invalid error body %q: %v", rr.Body.String(), err)
+				}
+				if influxErr["code"] != tt.expectedCode || influxErr["message"] == "" {
+					t.Errorf("Unexpected error body %v", influxErr)
+				}
+			}
+			if tt.expectedDoc != "" {
+				jsonData, err := c.DataService.DownloadJSON(context.Background(), downloadKey)
+				if err != nil {
+					t.Fatalf("Download failed: %v", err)
+				}
+				var got, want interface{}
+				json.Unmarshal(jsonData, &got)
+				json.Unmarshal([]byte(tt.expectedDoc), &want)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Expected document %s, got %s", tt.expectedDoc, jsonData)
+				}
+			}
+		})
+	}
+}
+
+func manyPaths(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString("many,i=")
+		b.WriteString(strings.Repeat("a", i+1))
+		b.WriteString(" v=1\n")
+	}
+	return b.String()
+}
+
+func manyPathsDoc(n int) string {
+	series := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		series[strings.Repeat("a", i+1)] = map[string]interface{}{"v": 1}
+	}
+	doc, _ := json.Marshal(map[string]interface{}{"many": series})
+	return string(doc)
+}
+
+func Test_limitedReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		wantErr error
+	}{
+		{name: "below limit", body: "abc", limit: 4},
+		{name: "exactly at limit", body: "abcd", limit: 4},
+		{name: "over limit", body: "abcde", limit: 4, wantErr: errWriteTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(&limitedReader{r: strings.NewReader(tt.body), n: tt.limit})
+			if err != tt.wantErr {
+				t.Fatalf("ReadAll error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.body {
+				t.Errorf("ReadAll = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
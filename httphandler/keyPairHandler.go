@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/domain"
 )
 
@@ -12,7 +13,7 @@ func (c Config) KeyPairHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		slog.Error("keypair: failed to generate key pair", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, "Error generating key pair", http.StatusInternalServerError)
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error generating key pair"))
 		return
 	}
 
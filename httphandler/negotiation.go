@@ -0,0 +1,75 @@
+package httphandler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MediaTypeDocumentV2 is the media type clients send in the Accept header to
+// receive the v2 document format with separate values and metadata.
+const MediaTypeDocumentV2 = "application/vnd.iot-ephemeral-value-store.v2+json"
+
+// wantsDocumentV2 reports whether the client asked for the v2 document format,
+// either via the Accept header or via ?format=v2. The /v2 route tree always
+// returns the v2 document format.
+func wantsDocumentV2(r *http.Request) bool {
+	if apiVersion(r) >= 2 || r.URL.Query().Get("format") == "v2" {
+		return true
+	}
+	return acceptsMediaType(r, MediaTypeDocumentV2)
+}
+
+// acceptsMediaType reports whether the Accept header of r explicitly lists
+// mediaType. Wildcards are not considered a match.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		name, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(name), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredMediaType returns the entry of offers with the highest quality in
+// the Accept header of r. Ties are resolved in the order of offers.
+// Wildcards are not considered a match, so an empty string is returned if no
+// offer is listed explicitly.
+func preferredMediaType(r *http.Request, offers []string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		for i, offer := range offers {
+			if !strings.EqualFold(name, offer) {
+				continue
+			}
+			if q > bestQ || (q == bestQ && i < indexOf(offers, best)) {
+				best, bestQ = offer, q
+			}
+		}
+	}
+	return best
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return len(list)
+}
@@ -0,0 +1,69 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_DownloadJsonHandler_DocumentV2(t *testing.T) {
+	s := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, err := c.DataService.Upload(context.Background(), uploadKey, map[string]string{"temp": "21"})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		wantV2 bool
+	}{
+		{"flat by default", "", "", false},
+		{"flat for application/json", "", "application/json", false},
+		{"v2 via Accept", "", MediaTypeDocumentV2 + "; q=1.0", true},
+		{"v2 via query", "?format=v2", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/d/"+downloadKey+"/json"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": downloadKey})
+			w := httptest.NewRecorder()
+
+			c.DownloadJsonHandler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			_, hasValues := body["values"]
+			_, hasMeta := body["meta"]
+			if tt.wantV2 != (hasValues && hasMeta) {
+				t.Errorf("Expected v2=%v, got body %v", tt.wantV2, body)
+			}
+			if !tt.wantV2 && body["temp"] != "21" {
+				t.Errorf("Expected flat temp=21, got %v", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// MediaTypeProblem is the media type of error responses (RFC 9457).
+const MediaTypeProblem = data.MediaTypeProblem
+
+// writeProblem writes p as the response, see data.WriteProblem.
+func writeProblem(w http.ResponseWriter, r *http.Request, p data.Problem) {
+	data.WriteProblem(w, r, p)
+}
+
+// writeError writes the problem matching err, see data.ProblemFor.
+func writeError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeProblem(w, r, data.NewProblem(data.CodeTooLarge, err.Error()))
+		return
+	}
+	writeProblem(w, r, data.ProblemFor(err, detail))
+}
+
+// writeBodyError writes the problem for a request body that could not be
+// read or decoded.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	code := data.CodeValidation
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		code = data.CodeTooLarge
+	}
+	writeProblem(w, r, data.NewProblem(code, err.Error()))
+}
+
+// ProblemTypeHandler handles GET /problems/{code}, the documentation of the
+// problem types, as plain text.
+func (c Config) ProblemTypeHandler(w http.ResponseWriter, r *http.Request) {
+	pt, ok := data.LookupProblemType(mux.Vars(r)["code"])
+	if !ok {
+		writeProblem(w, r, data.NewProblem(data.CodeNotFound, "Unknown problem type"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	fmt.Fprintf(w, "%s: %s (HTTP %d)\n\n%s\n", pt.Code, pt.Title, pt.Status, pt.Description)
+}
+
+// MethodNotAllowed answers requests with a method the route does not accept
+// with 405 and the allowed methods. It is registered for GET on the write
+// routes of route trees without GET writes.
+type MethodNotAllowed []string
+
+func (m MethodNotAllowed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(m, ", "))
+	detail := fmt.Sprintf("%s is not allowed for this route, use %s", r.Method, strings.Join(m, " or "))
+	writeProblem(w, r, data.NewProblem(data.CodeMethodNotAllowed, detail))
+}
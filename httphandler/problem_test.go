@@ -0,0 +1,78 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_writeError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedDetail string
+		retryAfter     bool
+	}{
+		{"degraded storage", fmt.Errorf("error storing data: %w", storage.ErrStorageDegraded), http.StatusServiceUnavailable, data.CodeStorageDegraded, "Error saving data", true},
+		{"storage timeout", storage.ErrStorageTimeout, http.StatusServiceUnavailable, data.CodeStorageTimeout, "Error saving data", true},
+		{"missing key", fmt.Errorf("invalid download key or data not found: %w", storage.ErrNotFound), http.StatusNotFound, data.CodeNotFound, "Error saving data", false},
+		{"body too large", &http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge, data.CodeTooLarge, "http: request body too large", false},
+		{"unknown error", errors.New("disk on fire"), http.StatusInternalServerError, data.CodeInternal, "Error saving data", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/u/key", nil)
+			rr := httptest.NewRecorder()
+			writeError(rr, req, tt.err, "Error saving data")
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.expectedStatus)
+			}
+			if got := rr.Header().Get("Content-Type"); got != MediaTypeProblem {
+				t.Errorf("Content-Type = %q, want %q", got, MediaTypeProblem)
+			}
+			if got := rr.Header().Get("Retry-After") != ""; got != tt.retryAfter {
+				t.Errorf("Retry-After set = %v, want %v", got, tt.retryAfter)
+			}
+
+			var p data.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+				t.Fatalf("invalid problem body %q: %v", rr.Body.String(), err)
+			}
+			if p.Code != tt.expectedCode || p.Status != tt.expectedStatus || p.Detail != tt.expectedDetail || p.Instance != "/u/key" {
+				t.Errorf("problem = %+v, want code %q and detail %q", p, tt.expectedCode, tt.expectedDetail)
+			}
+		})
+	}
+}
+
+func TestProblemTypeHandler(t *testing.T) {
+	c := Config{}
+	for _, code := range []string{data.CodeNotFound, data.CodeStorageDegraded, data.CodeClientCertRequired} {
+		p := data.NewProblem(code, "")
+		req := mux.SetURLVars(httptest.NewRequest("GET", p.Type, nil), map[string]string{"code": code})
+		rr := httptest.NewRecorder()
+		c.ProblemTypeHandler(rr, req)
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), code+": "+p.Title) {
+			t.Errorf("GET %s = %d %q", p.Type, rr.Code, rr.Body.String())
+		}
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/problems/unknown", nil), map[string]string{"code": "unknown"})
+	rr := httptest.NewRecorder()
+	c.ProblemTypeHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown problem type: status %d, want 404", rr.Code)
+	}
+}
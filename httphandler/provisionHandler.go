@@ -0,0 +1,153 @@
+package httphandler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/gorilla/mux"
+)
+
+// provisionPathPattern limits the patch path of a bundle to characters that
+// need no escaping in YAML, C string literals or URLs.
+var provisionPathPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+// provisionBundle is everything a device needs to upload to a key.
+type provisionBundle struct {
+	BaseURL     string `json:"base_url"`
+	UploadKey   string `json:"upload_key"`
+	DownloadKey string `json:"download_key"`
+	Path        string `json:"path,omitempty"`
+	UploadURL   string `json:"upload_url"`
+	DownloadURL string `json:"download_url"`
+	TTLSeconds  int64  `json:"ttl_seconds"`
+}
+
+// ProvisionHandler handles /provision/{uploadKey} and returns a provisioning
+// bundle for the key pair in the format of the format query parameter: json
+// (default), esphome, arduino or homeassistant. With the path query
+// parameter, e.g. path=livingroom/sensor1, the device uploads with a patch to
+// that path instead of replacing all values.
+func (c Config) ProvisionHandler(w http.ResponseWriter, r *http.Request) {
+	uploadKey := mux.Vars(r)["uploadKey"]
+	downloadKey, err := domain.DeriveDownloadKey(uploadKey)
+	if err == nil {
+		err = domain.ValidateUploadKey(uploadKey)
+	}
+	if err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInvalidKey, "invalid upload key: "+err.Error()))
+		return
+	}
+
+	path := strings.Trim(r.URL.Query().Get("path"), "/")
+	if path != "" && !provisionPathPattern.MatchString(path) {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, fmt.Sprintf("invalid path %q, expected segments of letters, digits, '_' and '-' separated by '/'", path)))
+		return
+	}
+
+	uploadKey = domain.AddUploadPrefix(domain.StripUploadPrefix(uploadKey))
+	downloadKey = domain.AddDownloadPrefix(downloadKey)
+	base := requestBaseURL(r) + apiPrefix(r)
+	b := provisionBundle{
+		BaseURL:     base,
+		UploadKey:   uploadKey,
+		DownloadKey: downloadKey,
+		Path:        path,
+		UploadURL:   fmt.Sprintf("%s/u/%s", base, uploadKey),
+		DownloadURL: fmt.Sprintf("%s/d/%s/json", base, downloadKey),
+		TTLSeconds:  int64(c.PersistDuration.Seconds()),
+	}
+	if path != "" {
+		b.UploadURL = fmt.Sprintf("%s/patch/%s/%s", base, uploadKey, path)
+	}
+
+	// The bundle contains the upload key, which is a secret.
+	w.Header().Set("Cache-Control", "no-store")
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		jsonResponse(w, b)
+	case "esphome":
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		w.Write(renderESPHomeBundle(b))
+	case "arduino":
+		w.Header().Set("Content-Type", "text/x-c; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="iot_provisioning.h"`)
+		w.Write(renderArduinoBundle(b))
+	case "homeassistant":
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		w.Write(renderHomeAssistantBundle(b))
+	default:
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, fmt.Sprintf("invalid format %q, expected json, esphome, arduino or homeassistant", format)))
+	}
+}
+
+// writeBundleHeader writes the common comment lines of a bundle, each line
+// prefixed with comment.
+func writeBundleHeader(buf *bytes.Buffer, comment string, b provisionBundle) {
+	fmt.Fprintf(buf, "%s iot-ephemeral-value-store provisioning for %s\n", comment, b.DownloadKey)
+	fmt.Fprintf(buf, "%s Keep this file secret, it contains the upload key.\n", comment)
+	if b.TTLSeconds > 0 {
+		fmt.Fprintf(buf, "%s Values are deleted %d seconds after the last upload.\n", comment, b.TTLSeconds)
+	}
+	fmt.Fprintf(buf, "%s Read the values at %s\n", comment, b.DownloadURL)
+}
+
+// renderESPHomeBundle renders an ESPHome snippet that posts a sensor value as
+// JSON every minute. The sensor id is a placeholder.
+func renderESPHomeBundle(b provisionBundle) []byte {
+	var buf bytes.Buffer
+	writeBundleHeader(&buf, "#", b)
+	fmt.Fprintf(&buf, `
+substitutions:
+  iot_upload_url: "%s"
+
+http_request:
+  timeout: 10s
+
+interval:
+  - interval: 60s
+    then:
+      - http_request.post:
+          url: ${iot_upload_url}
+          json:
+            value: !lambda 'return to_string(id(my_sensor).state);'
+`, b.UploadURL)
+	return buf.Bytes()
+}
+
+// renderArduinoBundle renders a C header with the bundle as defines.
+func renderArduinoBundle(b provisionBundle) []byte {
+	var buf bytes.Buffer
+	writeBundleHeader(&buf, "//", b)
+	buf.WriteString("\n#pragma once\n\n")
+	fmt.Fprintf(&buf, "#define IOT_BASE_URL \"%s\"\n", b.BaseURL)
+	fmt.Fprintf(&buf, "#define IOT_UPLOAD_KEY \"%s\"\n", b.UploadKey)
+	fmt.Fprintf(&buf, "#define IOT_DOWNLOAD_KEY \"%s\"\n", b.DownloadKey)
+	fmt.Fprintf(&buf, "#define IOT_PATCH_PATH \"%s\"\n", b.Path)
+	fmt.Fprintf(&buf, "#define IOT_UPLOAD_URL \"%s\"\n", b.UploadURL)
+	fmt.Fprintf(&buf, "#define IOT_DOWNLOAD_URL \"%s\"\n", b.DownloadURL)
+	fmt.Fprintf(&buf, "#define IOT_TTL_SECONDS %d\n", b.TTLSeconds)
+	return buf.Bytes()
+}
+
+// renderHomeAssistantBundle renders a rest_command for configuration.yaml as
+// in README.HomeAssistant.md. POST with query parameters works in every route
+// tree, also where GET writes are disabled.
+func renderHomeAssistantBundle(b provisionBundle) []byte {
+	var buf bytes.Buffer
+	writeBundleHeader(&buf, "#", b)
+	fmt.Fprintf(&buf, `
+rest_command:
+  iot_upload_value:
+    url: "%s?value={{ value }}"
+    method: post
+`, b.UploadURL)
+	return buf.Bytes()
+}
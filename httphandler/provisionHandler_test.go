@@ -0,0 +1,91 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_ProvisionHandler(t *testing.T) {
+	s := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance:   stats.NewStats(),
+		DataService:     &data.Service{StorageInstance: &s},
+		PersistDuration: 24 * time.Hour,
+	}
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+
+	tests := []struct {
+		name                 string
+		uploadKey            string
+		query                string
+		expectedStatus       int
+		expectedContentType  string
+		expectedBodyContains string
+	}{
+		{"json", uploadKey, "", http.StatusOK, "application/json", `"upload_url":"https://iot.example.com/u/u_` + uploadKey + `"`},
+		{"json with path", uploadKey, "?path=/livingroom/sensor1/", http.StatusOK, "application/json", `"upload_url":"https://iot.example.com/patch/u_` + uploadKey + `/livingroom/sensor1"`},
+		{"ttl", uploadKey, "?format=json", http.StatusOK, "application/json", `"ttl_seconds":86400`},
+		{"esphome", uploadKey, "?format=esphome", http.StatusOK, "application/yaml; charset=utf-8", `iot_upload_url: "https://iot.example.com/u/u_` + uploadKey + `"`},
+		{"arduino", uploadKey, "?format=arduino", http.StatusOK, "text/x-c; charset=utf-8", `#define IOT_DOWNLOAD_KEY "d_` + downloadKey + `"`},
+		{"homeassistant", uploadKey, "?format=homeassistant&path=room1", http.StatusOK, "application/yaml; charset=utf-8", `url: "https://iot.example.com/patch/u_` + uploadKey + `/room1?value={{ value }}"`},
+		{"invalid upload key", "invalidUploadKey", "", http.StatusBadRequest, MediaTypeProblem, `"code":"invalid_key"`},
+		{"invalid format", uploadKey, "?format=toml", http.StatusBadRequest, MediaTypeProblem, "invalid format"},
+		{"invalid path", uploadKey, `?path=a"b`, http.StatusBadRequest, MediaTypeProblem, "invalid path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/provision/"+tt.uploadKey+strings.ReplaceAll(tt.query, `"`, "%22"), nil)
+			req.Host = "iot.example.com"
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req = mux.SetURLVars(req, map[string]string{"uploadKey": tt.uploadKey})
+			w := httptest.NewRecorder()
+			c.ProvisionHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ProvisionHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("ProvisionHandler returned wrong content type: got %q want %q", got, tt.expectedContentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBodyContains) {
+				t.Errorf("ProvisionHandler body %q does not contain %q", w.Body.String(), tt.expectedBodyContains)
+			}
+			if tt.expectedStatus == http.StatusOK && w.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected Cache-Control: no-store for provisioning bundles")
+			}
+		})
+	}
+}
+
+func Test_ProvisionHandlerJSONRoundTrip(t *testing.T) {
+	s := storage.NewInMemoryStorage()
+	c := Config{StatsInstance: stats.NewStats(), DataService: &data.Service{StorageInstance: &s}}
+	uploadKey := domain.AddUploadPrefix(domain.GenerateRandomKey())
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/provision/"+uploadKey, nil), map[string]string{"uploadKey": uploadKey})
+	w := httptest.NewRecorder()
+	c.ProvisionHandler(w, req)
+
+	var b provisionBundle
+	if err := json.Unmarshal(w.Body.Bytes(), &b); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if b.UploadKey != uploadKey || b.BaseURL != "http://example.com" || b.Path != "" {
+		t.Errorf("Unexpected bundle %+v", b)
+	}
+	if derived, _ := domain.DeriveDownloadKey(uploadKey); b.DownloadKey != domain.AddDownloadPrefix(derived) {
+		t.Errorf("Expected the download key derived from the upload key, got %q", b.DownloadKey)
+	}
+}
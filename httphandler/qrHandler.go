@@ -0,0 +1,184 @@
+package httphandler
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/gorilla/mux"
+	"rsc.io/qr"
+)
+
+// qrQuietZone is the white border around a QR code in modules, as required
+// by the QR specification.
+const qrQuietZone = 4
+
+// KeyPairQRHandler handles /kp/qr and renders a fresh key pair as an SVG
+// provisioning card with two QR codes: the upload URL for the device and the
+// download URL for the viewer. The keys are also returned in the X-Upload-Key
+// and X-Download-Key headers.
+func (c Config) KeyPairQRHandler(w http.ResponseWriter, r *http.Request) {
+	uploadKey, downloadKey, err := c.DataService.GenerateKeyPair()
+	if err != nil {
+		slog.Error("keypair qr: failed to generate key pair", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error generating key pair"))
+		return
+	}
+	uploadKey = domain.AddUploadPrefix(uploadKey)
+	downloadKey = domain.AddDownloadPrefix(downloadKey)
+
+	uploadCode, err := qr.Encode(provisioningUploadURL(r, uploadKey), qr.M)
+	if err != nil {
+		slog.Error("keypair qr: failed to encode QR code", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error encoding QR code"))
+		return
+	}
+	downloadCode, err := qr.Encode(provisioningDownloadURL(r, downloadKey), qr.M)
+	if err != nil {
+		slog.Error("keypair qr: failed to encode QR code", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error encoding QR code"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Upload-Key", uploadKey)
+	w.Header().Set("X-Download-Key", downloadKey)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(renderKeyPairCard(uploadCode, uploadKey, downloadCode, downloadKey))
+}
+
+// UploadQRHandler handles /qr/u/{uploadKey}.{format} and renders the upload
+// URL of the key as a PNG or SVG QR code for provisioning a device.
+func (c Config) UploadQRHandler(w http.ResponseWriter, r *http.Request) {
+	uploadKey := mux.Vars(r)["uploadKey"]
+	if err := domain.ValidateUploadKey(uploadKey); err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInvalidKey, "invalid upload key: "+err.Error()))
+		return
+	}
+	c.writeQR(w, r, provisioningUploadURL(r, uploadKey))
+}
+
+// DownloadQRHandler handles /qr/d/{downloadKey}.{format} and renders the
+// download URL of the key as a PNG or SVG QR code for opening it in a
+// browser.
+func (c Config) DownloadQRHandler(w http.ResponseWriter, r *http.Request) {
+	downloadKey := mux.Vars(r)["downloadKey"]
+	if err := domain.ValidateDownloadKey(downloadKey); err != nil {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInvalidKey, "invalid download key: "+err.Error()))
+		return
+	}
+	c.writeQR(w, r, provisioningDownloadURL(r, downloadKey))
+}
+
+// writeQR writes text as a QR code in the format of the format route
+// variable. PNG codes are scaled by the scale query parameter (pixels per
+// module, default 8).
+func (c Config) writeQR(w http.ResponseWriter, r *http.Request, text string) {
+	scale := 8
+	if s := r.URL.Query().Get("scale"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 32 {
+			c.StatsInstance.IncrementHTTPErrors()
+			writeProblem(w, r, data.NewProblem(data.CodeValidation, fmt.Sprintf("invalid scale %q, expected 1 to 32 pixels per module", s)))
+			return
+		}
+		scale = n
+	}
+
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		slog.Error("qr: failed to encode QR code", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeInternal, "Error encoding QR code"))
+		return
+	}
+
+	// The codes contain keys; the upload URL is a secret.
+	w.Header().Set("Cache-Control", "no-store")
+	if mux.Vars(r)["format"] == "png" {
+		code.Scale = scale
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(code.PNG())
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	var buf bytes.Buffer
+	size := code.Size + 2*qrQuietZone
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, size*scale, size*scale, size, size)
+	writeQRModules(&buf, code)
+	buf.WriteString(`</svg>`)
+	w.Write(buf.Bytes())
+}
+
+// provisioningUploadURL returns the URL a device uploads to with uploadKey,
+// in the route tree of the request.
+func provisioningUploadURL(r *http.Request, uploadKey string) string {
+	return fmt.Sprintf("%s%s/u/%s", requestBaseURL(r), apiPrefix(r), uploadKey)
+}
+
+// provisioningDownloadURL returns the browser page of downloadKey, in the
+// route tree of the request.
+func provisioningDownloadURL(r *http.Request, downloadKey string) string {
+	return fmt.Sprintf("%s%s/d/%s/", requestBaseURL(r), apiPrefix(r), downloadKey)
+}
+
+// writeQRModules writes the dark modules of code as a single SVG path in a
+// coordinate system of one unit per module, offset by the quiet zone.
+func writeQRModules(buf *bytes.Buffer, code *qr.Code) {
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" shape-rendering="crispEdges" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(buf, "M%d %dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+}
+
+// renderKeyPairCard renders two QR codes side by side with captions and the
+// keys below them, ready to print or scan from the screen.
+func renderKeyPairCard(uploadCode *qr.Code, uploadKey string, downloadCode *qr.Code, downloadKey string) []byte {
+	const (
+		codeSize = 240
+		margin   = 20
+		width    = 2*codeSize + 3*margin
+		height   = codeSize + 110
+	)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="Key pair QR codes">`, width, height, width, height)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/>`)
+	buf.WriteString(`<g font-family="Verdana,Geneva,DejaVu Sans,sans-serif" text-anchor="middle" fill="#000">`)
+	for i, card := range []struct {
+		code  *qr.Code
+		title string
+		key   string
+	}{
+		{uploadCode, "Upload (device, keep secret)", uploadKey},
+		{downloadCode, "Download (viewer)", downloadKey},
+	} {
+		x := margin + i*(codeSize+margin)
+		size := card.code.Size + 2*qrQuietZone
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="13">%s</text>`, x+codeSize/2, margin+4, html.EscapeString(card.title))
+		fmt.Fprintf(&buf, `<svg x="%d" y="%d" width="%d" height="%d" viewBox="0 0 %d %d">`, x, margin+12, codeSize, codeSize, size, size)
+		writeQRModules(&buf, card.code)
+		buf.WriteString(`</svg>`)
+		// 66 characters do not fit below the code in one line.
+		half := len(card.key) / 2
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="9" font-family="monospace">%s</text>`, x+codeSize/2, margin+codeSize+34, html.EscapeString(card.key[:half]))
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="9" font-family="monospace">%s</text>`, x+codeSize/2, margin+codeSize+48, html.EscapeString(card.key[half:]))
+	}
+	buf.WriteString(`</g></svg>`)
+	return buf.Bytes()
+}
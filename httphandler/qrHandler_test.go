@@ -0,0 +1,106 @@
+package httphandler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func newQRTestConfig() Config {
+	s := storage.NewInMemoryStorage()
+	return Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+}
+
+func Test_QRHandlers(t *testing.T) {
+	c := newQRTestConfig()
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+
+	tests := []struct {
+		name                string
+		handler             http.HandlerFunc
+		vars                map[string]string
+		query               string
+		expectedStatus      int
+		expectedContentType string
+		expectedBodyPrefix  string
+	}{
+		{"upload svg", c.UploadQRHandler, map[string]string{"uploadKey": uploadKey, "format": "svg"}, "", http.StatusOK, "image/svg+xml", "<svg"},
+		{"upload png", c.UploadQRHandler, map[string]string{"uploadKey": uploadKey, "format": "png"}, "?scale=4", http.StatusOK, "image/png", "\x89PNG"},
+		{"download svg", c.DownloadQRHandler, map[string]string{"downloadKey": domain.AddDownloadPrefix(downloadKey), "format": "svg"}, "", http.StatusOK, "image/svg+xml", "<svg"},
+		{"invalid upload key", c.UploadQRHandler, map[string]string{"uploadKey": "invalidUploadKey", "format": "svg"}, "", http.StatusBadRequest, MediaTypeProblem, `{"type":"/problems/invalid_key"`},
+		{"invalid download key", c.DownloadQRHandler, map[string]string{"downloadKey": "https://example.com", "format": "svg"}, "", http.StatusBadRequest, MediaTypeProblem, `{"type":"/problems/invalid_key"`},
+		{"invalid scale", c.UploadQRHandler, map[string]string{"uploadKey": uploadKey, "format": "png"}, "?scale=100", http.StatusBadRequest, MediaTypeProblem, `{"type":"/problems/validation"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/qr/x"+tt.query, nil), tt.vars)
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("QR handler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("QR handler returned wrong content type: got %q want %q", got, tt.expectedContentType)
+			}
+			if !bytes.HasPrefix(w.Body.Bytes(), []byte(tt.expectedBodyPrefix)) {
+				t.Errorf("QR handler body does not start with %q", tt.expectedBodyPrefix)
+			}
+			if tt.expectedStatus == http.StatusOK && w.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected Cache-Control: no-store for QR codes of keys")
+			}
+		})
+	}
+}
+
+func Test_KeyPairQRHandler(t *testing.T) {
+	c := newQRTestConfig()
+
+	req := httptest.NewRequest("GET", "/kp/qr", nil)
+	w := httptest.NewRecorder()
+	c.KeyPairQRHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("KeyPairQRHandler returned wrong status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	uploadKey := w.Header().Get("X-Upload-Key")
+	downloadKey := w.Header().Get("X-Download-Key")
+	if err := domain.ValidateUploadKey(uploadKey); err != nil {
+		t.Errorf("Expected a valid upload key in X-Upload-Key, got %q", uploadKey)
+	}
+	if derived, _ := domain.DeriveDownloadKey(uploadKey); domain.AddDownloadPrefix(derived) != downloadKey {
+		t.Errorf("Expected X-Download-Key to be derived from the upload key, got %q", downloadKey)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, uploadKey[:len(uploadKey)/2]) || !strings.Contains(body, downloadKey[len(downloadKey)/2:]) {
+		t.Error("Expected the keys as text below the QR codes")
+	}
+}
+
+func Test_ProvisioningURLs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/qr/u/key.svg", nil)
+	req.Host = "iot.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req = req.WithContext(context.WithValue(req.Context(), apiVersionKey{}, 1))
+
+	if got := provisioningUploadURL(req, "u_abc"); got != "https://iot.example.com/v1/u/u_abc" {
+		t.Errorf("provisioningUploadURL() = %q", got)
+	}
+	if got := provisioningDownloadURL(req, "d_abc"); got != "https://iot.example.com/v1/d/d_abc/" {
+		t.Errorf("provisioningDownloadURL() = %q", got)
+	}
+}
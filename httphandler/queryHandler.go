@@ -0,0 +1,46 @@
+package httphandler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// DownloadQueryHandler handles requests to /d/{downloadKey}/query?q=... and
+// returns the values selected by a JSONPath expression as a JSON array, e.g.
+// ?q=$..temp for all temperatures in any room. With ?paths=true each entry
+// is an object with the path and the value.
+func (c Config) DownloadQueryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+
+	expr := r.URL.Query().Get("q")
+	if expr == "" {
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, "Missing query parameter q, e.g. ?q=$..temp"))
+		return
+	}
+
+	matches, err := c.DataService.Query(r.Context(), downloadKey, expr)
+	if err != nil {
+		slog.Debug("download query: failed", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+
+	if withPaths, _ := strconv.ParseBool(r.URL.Query().Get("paths")); withPaths {
+		jsonResponse(w, matches)
+		return
+	}
+	values := make([]interface{}, 0, len(matches))
+	for _, m := range matches {
+		values = append(values, m.Value)
+	}
+	jsonResponse(w, values)
+}
@@ -0,0 +1,71 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_DownloadQueryHandler(t *testing.T) {
+	ctx := context.Background()
+
+	newConfig := func() Config {
+		s := storage.NewInMemoryStorage()
+		s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{
+			"kitchen": map[string]interface{}{"temp": "21", "battery": "15"},
+			"garage":  map[string]interface{}{"temp": "8", "battery": "90"},
+		})
+		return Config{
+			StatsInstance: stats.NewStats(),
+			DataService:   &data.Service{StorageInstance: &s},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		downloadKey    string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"all temperatures", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "q=" + url.QueryEscape("$..temp"), http.StatusOK, `["8","21"]`},
+		{"filter", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "q=" + url.QueryEscape("$..[?(@.battery<20)].temp"), http.StatusOK, `["21"]`},
+		{"with paths", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "paths=true&q=" + url.QueryEscape("$.garage.temp"), http.StatusOK, `[{"path":"garage/temp","value":"8"}]`},
+		{"no match", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "q=" + url.QueryEscape("$.attic"), http.StatusOK, `[]`},
+		{"missing q", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "", http.StatusBadRequest, ""},
+		{"invalid q", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "q=temp", http.StatusBadRequest, ""},
+		{"unknown key", "unknownKey", "q=" + url.QueryEscape("$..temp"), http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			req := httptest.NewRequest("GET", "/d/"+tt.downloadKey+"/query?"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey})
+			w := httptest.NewRecorder()
+
+			c.DownloadQueryHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("DownloadQueryHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if c.StatsInstance.GetCurrentStats().HTTPErrorCount != 1 {
+					t.Errorf("Expected HTTPErrorCount to be incremented")
+				}
+				return
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.expectedBody {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, got)
+			}
+		})
+	}
+}
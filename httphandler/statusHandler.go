@@ -0,0 +1,55 @@
+package httphandler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// DownloadStatusHandler handles requests to /d/{downloadKey}/status and
+// reports which value paths have not been updated within the max age. The
+// max age can be overridden per request with the max_age query parameter
+// (e.g. ?max_age=30m).
+func (c Config) DownloadStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+
+	maxAge, err := parseMaxAge(r)
+	if err != nil {
+		slog.Debug("download status: invalid max_age", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeProblem(w, r, data.NewProblem(data.CodeValidation, "Invalid max_age, expected a duration like 30m or 2h"))
+		return
+	}
+
+	report, err := c.DataService.Status(r.Context(), downloadKey, maxAge)
+	if err != nil {
+		slog.Debug("download status: failed to retrieve data", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Invalid download key or data not found")
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	jsonResponse(w, report)
+}
+
+// parseMaxAge reads the optional max_age query parameter. A missing parameter
+// yields zero, which lets the data service apply its configured default.
+func parseMaxAge(r *http.Request) (time.Duration, error) {
+	raw := r.URL.Query().Get("max_age")
+	if raw == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// wantsStaleMarkers reports whether the client asked for stale markers in the
+// JSON output via ?stale or ?max_age.
+func wantsStaleMarkers(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("stale") || q.Has("max_age")
+}
@@ -0,0 +1,106 @@
+package httphandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_DownloadStatusHandler(t *testing.T) {
+	ctx := context.Background()
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+
+	newConfig := func() Config {
+		s := storage.NewInMemoryStorage()
+		s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{"temp": "21", "timestamp": old})
+		return Config{
+			StatsInstance: stats.NewStats(),
+			DataService:   &data.Service{StorageInstance: &s},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		downloadKey    string
+		query          string
+		expectedStatus int
+		expectedStale  bool
+	}{
+		{"default max age", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "", http.StatusOK, true},
+		{"max_age override", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "?max_age=3h", http.StatusOK, false},
+		{"invalid max_age", "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "?max_age=soon", http.StatusBadRequest, false},
+		{"unknown key", "unknownKey", "", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig()
+			req := httptest.NewRequest("GET", "/d/"+tt.downloadKey+"/status"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey})
+			w := httptest.NewRecorder()
+
+			c.DownloadStatusHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("DownloadStatusHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var report data.StatusReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Failed to decode status report: %v", err)
+			}
+			if report.Stale != tt.expectedStale {
+				t.Errorf("Expected stale=%v, got %v", tt.expectedStale, report.Stale)
+			}
+		})
+	}
+}
+
+func Test_DownloadJsonHandler_StaleMarkers(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{"temp": "21", "timestamp": old})
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+
+	t.Run("without stale query", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json", nil), map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a"})
+		w := httptest.NewRecorder()
+		c.DownloadJsonHandler(w, req)
+
+		var doc map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &doc)
+		if _, ok := doc["_stale"]; ok {
+			t.Error("Expected no _stale marker without stale query")
+		}
+	})
+
+	t.Run("with stale query", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/json?stale", nil), map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a"})
+		w := httptest.NewRecorder()
+		c.DownloadJsonHandler(w, req)
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("Failed to decode JSON: %v", err)
+		}
+		stale, ok := doc["_stale"].([]interface{})
+		if !ok || len(stale) != 1 || stale[0] != "temp" {
+			t.Errorf("Expected _stale [temp], got %v", doc["_stale"])
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package httphandler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// TemplateHandler handles PUT /templates/{uploadKey}/{name} and stores the
+// request body as a text template that renders the data of the key at
+// /d/{downloadKey}/render/{name}.
+func (c Config) TemplateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uploadKey := vars["uploadKey"]
+	name := vars["name"]
+
+	source, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("template: failed to read body", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeBodyError(w, r, err)
+		return
+	}
+
+	downloadKey, err := c.DataService.SetTemplate(r.Context(), uploadKey, name, string(source))
+	if err != nil {
+		slog.Error("template: failed to store template", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Error storing template")
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"message":    "Template stored successfully",
+		"render_url": fmt.Sprintf("%s%s/d/%s/render/%s", requestBaseURL(r), apiPrefix(r), downloadKey, name),
+	})
+}
+
+// DeleteTemplateHandler handles DELETE /templates/{uploadKey}/{name}.
+func (c Config) DeleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uploadKey := vars["uploadKey"]
+	name := vars["name"]
+
+	if _, err := c.DataService.DeleteTemplate(r.Context(), uploadKey, name); err != nil {
+		slog.Error("template: failed to delete template", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Error deleting template")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK\n"))
+}
+
+// DownloadRenderHandler handles /d/{downloadKey}/render/{name} and renders
+// the stored data with the text template name, e.g. "T:21.5 H:40" for an LCD.
+func (c Config) DownloadRenderHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	downloadKey := vars["downloadKey"]
+	name := vars["name"]
+
+	out, expiresAt, err := c.DataService.RenderTemplate(r.Context(), downloadKey, name)
+	if err != nil {
+		slog.Debug("render: failed to render template", "error", err, "template", name, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "Template or data not found")
+		return
+	}
+
+	c.StatsInstance.IncrementDownloads()
+	setExpiryHeaders(w, expiresAt)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(out)
+}
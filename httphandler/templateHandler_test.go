@@ -0,0 +1,125 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_TemplateHandler(t *testing.T) {
+	uploadKey := domain.GenerateRandomKey()
+
+	tests := []struct {
+		name                 string
+		uploadKey            string
+		tmplName             string
+		body                 string
+		expectedStatus       int
+		expectedBodyContains string
+	}{
+		{"valid template", uploadKey, "lcd", "T:{{.temp}}", http.StatusOK, "/render/lcd"},
+		{"invalid upload key", "invalidUploadKey", "lcd", "T:{{.temp}}", http.StatusBadRequest, `"code":"invalid_key"`},
+		{"syntax error", uploadKey, "lcd", "T:{{.temp", http.StatusBadRequest, "invalid template"},
+		{"invalid name", uploadKey, "lcd.txt", "T:{{.temp}}", http.StatusBadRequest, "invalid template name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewInMemoryStorage()
+			c := Config{
+				StatsInstance: stats.NewStats(),
+				DataService:   &data.Service{StorageInstance: &s},
+			}
+
+			req := httptest.NewRequest("PUT", "/templates/"+tt.uploadKey+"/"+tt.tmplName, strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"uploadKey": tt.uploadKey, "name": tt.tmplName})
+			w := httptest.NewRecorder()
+			c.TemplateHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("TemplateHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBodyContains) {
+				t.Errorf("TemplateHandler body %q does not contain %q", w.Body.String(), tt.expectedBodyContains)
+			}
+		})
+	}
+}
+
+func Test_DownloadRenderHandler(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _, _ := c.DataService.UploadValues(ctx, uploadKey, map[string]interface{}{"temp": 21.46, "humidity": "40"})
+	c.DataService.SetTemplate(ctx, uploadKey, "lcd", "T:{{.temp | round 1}} H:{{.humidity}} P:{{.pressure | default \"--\"}}")
+	c.DataService.SetTemplate(ctx, uploadKey, "broken", "{{round 1 \"warm\"}}")
+
+	tests := []struct {
+		name                 string
+		downloadKey          string
+		tmplName             string
+		expectedStatus       int
+		expectedContentType  string
+		expectedBodyContains string
+	}{
+		{"rendered", downloadKey, "lcd", http.StatusOK, "text/plain; charset=utf-8", "T:21.5 H:40 P:--"},
+		{"unknown template", downloadKey, "oled", http.StatusNotFound, MediaTypeProblem, `"code":"not_found"`},
+		{"unknown key", domain.GenerateRandomKey(), "lcd", http.StatusNotFound, MediaTypeProblem, `"code":"not_found"`},
+		{"render error", downloadKey, "broken", http.StatusBadRequest, MediaTypeProblem, "error rendering template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/d/"+tt.downloadKey+"/render/"+tt.tmplName, nil)
+			req = mux.SetURLVars(req, map[string]string{"downloadKey": tt.downloadKey, "name": tt.tmplName})
+			w := httptest.NewRecorder()
+			c.DownloadRenderHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("DownloadRenderHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectedContentType {
+				t.Errorf("DownloadRenderHandler returned wrong content type: got %q want %q", got, tt.expectedContentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBodyContains) {
+				t.Errorf("DownloadRenderHandler body %q does not contain %q", w.Body.String(), tt.expectedBodyContains)
+			}
+		})
+	}
+}
+
+func Test_DeleteTemplateHandler(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+
+	uploadKey := domain.GenerateRandomKey()
+	c.DataService.SetTemplate(ctx, uploadKey, "lcd", "T:{{.temp}}")
+
+	for _, expectedStatus := range []int{http.StatusOK, http.StatusNotFound} {
+		req := httptest.NewRequest("DELETE", "/templates/"+uploadKey+"/lcd", nil)
+		req = mux.SetURLVars(req, map[string]string{"uploadKey": uploadKey, "name": "lcd"})
+		w := httptest.NewRecorder()
+		c.DeleteTemplateHandler(w, req)
+
+		if w.Code != expectedStatus {
+			t.Errorf("DeleteTemplateHandler returned wrong status code: got %v want %v", w.Code, expectedStatus)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package httphandler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/gorilla/mux"
+)
+
+// TouchHandler handles requests to /touch/{uploadKey} and renews the TTL of
+// the stored data without rewriting it.
+func (c Config) TouchHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uploadKey := vars["uploadKey"]
+
+	_, expiresAt, err := c.DataService.Touch(r.Context(), uploadKey)
+	if err != nil {
+		slog.Debug("touch: failed to extend TTL", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "No data stored for this upload key")
+		return
+	}
+
+	setExpiryHeaders(w, expiresAt)
+	jsonResponse(w, map[string]interface{}{
+		"message":     "TTL extended successfully",
+		"expires_at":  expiresAt.Format(time.RFC3339),
+		"ttl_seconds": data.RemainingTTLSeconds(expiresAt, time.Now()),
+	})
+}
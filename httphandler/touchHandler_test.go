@@ -0,0 +1,88 @@
+package httphandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
+)
+
+func Test_TouchHandler(t *testing.T) {
+	ctx := context.Background()
+	storedKey := domain.GenerateRandomKey()
+	emptyKey := domain.GenerateRandomKey()
+
+	tests := []struct {
+		name                 string
+		uploadKey            string
+		expectedStatus       int
+		expectedBodyContains string
+	}{
+		{"invalid upload key", "invalidUploadKey", http.StatusBadRequest, "invalid upload key"},
+		{"no data stored", emptyKey, http.StatusNotFound, "No data stored"},
+		{"data stored", storedKey, http.StatusOK, "expires_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storage.NewInMemoryStorage()
+			c := Config{
+				StatsInstance: stats.NewStats(),
+				DataService:   &data.Service{StorageInstance: &s},
+			}
+			c.DataService.Upload(ctx, storedKey, map[string]string{"temp": "21"})
+
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/touch/"+tt.uploadKey, nil), map[string]string{"uploadKey": tt.uploadKey})
+			w := httptest.NewRecorder()
+			c.TouchHandler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("TouchHandler returned wrong status code: got %v want %v", w.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBodyContains) {
+				t.Errorf("TouchHandler body %q does not contain %q", w.Body.String(), tt.expectedBodyContains)
+			}
+			if tt.expectedStatus == http.StatusOK && w.Header().Get("X-Expires-At") == "" {
+				t.Error("Expected X-Expires-At header")
+			}
+		})
+	}
+}
+
+func Test_DownloadHandlers_ExpiryHeaders(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewInMemoryStorage()
+	s.Store(ctx, "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", map[string]interface{}{"temp": "21"})
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &s},
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		"json":  c.DownloadJsonHandler,
+		"plain": c.DownloadPlainHandler,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/d/5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a/"+name, nil), map[string]string{"downloadKey": "5f0c1e8a7b2d4c6e9a3b1d0f8e7c6b5a4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a", "param": "temp"})
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+			for _, h := range []string{"Expires", "X-Expires-At", "X-TTL-Seconds"} {
+				if w.Header().Get(h) == "" {
+					t.Errorf("Expected %s header to be set", h)
+				}
+			}
+		})
+	}
+}
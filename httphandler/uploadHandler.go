@@ -1,17 +1,22 @@
 package httphandler
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
 
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/gorilla/mux"
 )
 
 func (c Config) UploadAndPatchHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	uploadKey := vars["uploadKey"]
-	path := vars["param"]
+	// /patch/{uploadKey}/room/ addresses the same map as /patch/{uploadKey}/room.
+	path := strings.TrimRight(vars["param"], "/")
 
 	c.handleUpload(w, r, uploadKey, path, true)
 }
@@ -24,44 +29,112 @@ func (c Config) UploadHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c Config) handleUpload(w http.ResponseWriter, r *http.Request, uploadKey, path string, isPatch bool) {
-	paramMap := collectParams(r.URL.Query())
+	values, err := collectValues(r)
+	if err != nil {
+		slog.Error("upload: failed to read values", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeBodyError(w, r, err)
+		return
+	}
 
-	// HTTP-specific: add per-key timestamps
-	addTimestampToThisData(paramMap, path)
+	arrays, err := data.ParseArrayMergeMode(r.URL.Query().Get("arrays"))
+	if err != nil {
+		slog.Error("upload: invalid array merge mode", "error", err, "method", r.Method, "path", r.URL.Path)
+		c.StatsInstance.IncrementHTTPErrors()
+		writeError(w, r, err, "")
+		return
+	}
 
 	var downloadKey string
-	var err error
 
+	ctx := data.WithSource(r.Context(), data.SourceHTTP)
 	if isPatch {
-		downloadKey, _, err = c.DataService.Patch(r.Context(), uploadKey, path, paramMap)
+		downloadKey, _, err = c.DataService.PatchValues(ctx, uploadKey, path, values, data.MergeOptions{Arrays: arrays})
 	} else {
-		downloadKey, _, err = c.DataService.Upload(r.Context(), uploadKey, paramMap)
+		downloadKey, _, err = c.DataService.UploadValues(ctx, uploadKey, values)
 	}
 
 	if err != nil {
 		slog.Error("upload: failed to store data", "error", err, "method", r.Method, "path", r.URL.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err, "Error storing data")
 		return
 	}
 
 	c.StatsInstance.IncrementUploads()
 
-	constructAndReturnResponse(w, r, downloadKey, paramMap)
+	paths := append(data.CollectPaths(values, ""), c.DataService.TimestampKeys(path, values)...)
+	constructAndReturnResponse(w, r, downloadKey, paths)
 }
 
-func constructAndReturnResponse(w http.ResponseWriter, r *http.Request, downloadKey string, params map[string]string) {
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
+// collectValues returns the values to store. An object in the request body
+// (Content-Type: application/json, application/cbor or application/msgpack)
+// may contain nested objects and arrays; otherwise the query parameters are
+// used. String values are sanitized in all cases.
+func collectValues(r *http.Request) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if codec, ok := requestBodyCodec(r); ok {
+		var err error
+		values, err = decodeBinaryBody(r, codec)
+		if err != nil {
+			return nil, err
+		}
+	} else if hasJSONBody(r) {
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		if values == nil {
+			return nil, fmt.Errorf("invalid JSON body: expected an object")
+		}
+	} else {
+		values = make(map[string]interface{})
+		for k, v := range collectParams(r.URL.Query()) {
+			values[k] = v
+		}
+		return values, nil
 	}
 
+	for k, v := range values {
+		values[k] = sanitizeValue(v)
+	}
+	return values, nil
+}
+
+// hasJSONBody reports whether the request carries a JSON document.
+func hasJSONBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// sanitizeValue applies sanitizeInput to all strings in a decoded JSON value.
+func sanitizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return sanitizeInput(val)
+	case map[string]interface{}:
+		for k, elem := range val {
+			val[k] = sanitizeValue(elem)
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = sanitizeValue(elem)
+		}
+	}
+	return v
+}
+
+func constructAndReturnResponse(w http.ResponseWriter, r *http.Request, downloadKey string, paths []string) {
+	base := requestBaseURL(r) + apiPrefix(r)
+
 	urls := make(map[string]string)
-	for key := range params {
-		urls[key] = fmt.Sprintf("%s://%s/d/%s/plain/%s", scheme, r.Host, downloadKey, key)
+	for _, path := range paths {
+		urls[path] = fmt.Sprintf("%s/d/%s/plain/%s", base, downloadKey, path)
 	}
 
-	downloadURL := fmt.Sprintf("%s://%s/d/%s/json", scheme, r.Host, downloadKey)
+	downloadURL := fmt.Sprintf("%s/d/%s/json", base, downloadKey)
 
 	jsonResponse(w, map[string]interface{}{
 		"message":        "Data uploaded successfully",
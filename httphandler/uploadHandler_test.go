@@ -1,12 +1,17 @@
 package httphandler
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
 	"github.com/dhcgn/iot-ephemeral-value-store/storage"
 	"github.com/gorilla/mux"
@@ -248,3 +253,141 @@ func Test_UploadAndPatchHandler(t *testing.T) {
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func Test_UploadHandler_JSONBody(t *testing.T) {
+	const uploadKey = "7790e6a7c72e97c2493334f7b22ffbaa2a41fc53a95268a4fbb45a9c34d9c5d1"
+
+	si := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &si},
+	}
+
+	post := func(handler http.HandlerFunc, target, body string, vars map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, mux.SetURLVars(req, vars))
+		return w
+	}
+
+	w := post(c.UploadHandler, "/u/"+uploadKey, `{"sensors":[{"temp":"20"},{"temp":"<b>21</b>"}]}`,
+		map[string]string{"uploadKey": uploadKey})
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload returned %d: %s", w.Code, w.Body.String())
+	}
+	if !contains(w.Body.String(), "/plain/sensors/1/temp") {
+		t.Errorf("expected parameter URL for array element, got %s", w.Body.String())
+	}
+
+	w = post(c.UploadAndPatchHandler, "/patch/"+uploadKey+"?arrays=append", `{"sensors":[{"temp":"22"}]}`,
+		map[string]string{"uploadKey": uploadKey})
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch returned %d: %s", w.Code, w.Body.String())
+	}
+
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	for path, want := range map[string]interface{}{
+		"sensors/1/temp":  "&lt;b&gt;21&lt;/b&gt;",
+		"sensors/-1/temp": "22",
+	} {
+		got, err := c.DataService.DownloadField(context.Background(), downloadKey, path)
+		if err != nil {
+			t.Fatalf("DownloadField(%s) error: %v", path, err)
+		}
+		if got != want {
+			t.Errorf("DownloadField(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{name: "invalid JSON", target: "/patch/" + uploadKey, body: `{"sensors":`},
+		{name: "JSON array body", target: "/patch/" + uploadKey, body: `[1,2]`},
+		{name: "unknown array mode", target: "/patch/" + uploadKey + "?arrays=concat", body: `{"a":"1"}`},
+		{name: "index out of range", target: "/patch/" + uploadKey + "/sensors/9", body: `{"a":"1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{"uploadKey": uploadKey}
+			if _, param, ok := strings.Cut(strings.TrimPrefix(tt.target, "/patch/"+uploadKey), "/"); ok {
+				vars["param"] = param
+			}
+			w := post(c.UploadAndPatchHandler, tt.target, tt.body, vars)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// Test_UploadAndPatchHandler_BaselineTimestamps pins the documents and
+// parameter URLs of earlier versions for the default timestamp mode.
+func Test_UploadAndPatchHandler_BaselineTimestamps(t *testing.T) {
+	const uploadKey = "7790e6a7c72e97c2493334f7b22ffbaa2a41fc53a95268a4fbb45a9c34d9c5d1"
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+
+	si := storage.NewInMemoryStorage()
+	c := Config{
+		StatsInstance: stats.NewStats(),
+		DataService:   &data.Service{StorageInstance: &si},
+	}
+
+	patch := func(target, param string) map[string]string {
+		t.Helper()
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		c.UploadAndPatchHandler(w, mux.SetURLVars(req, map[string]string{"uploadKey": uploadKey, "param": param}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("patch %s returned %d: %s", target, w.Code, w.Body.String())
+		}
+		var resp struct {
+			ParameterURLs map[string]string `json:"parameter_urls"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.ParameterURLs
+	}
+
+	urls := patch("/patch/"+uploadKey+"/?name=MyHome", "")
+	if got, want := sortedKeys(urls), []string{"name", "name_timestamp", "timestamp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("root patch parameter_urls = %v, want %v", got, want)
+	}
+	if want := "http://example.com/d/" + downloadKey + "/plain/name_timestamp"; urls["name_timestamp"] != want {
+		t.Errorf("parameter_urls[name_timestamp] = %q, want %q", urls["name_timestamp"], want)
+	}
+
+	urls = patch("/patch/"+uploadKey+"/living_room/?temp=22", "living_room")
+	if got, want := sortedKeys(urls), []string{"temp", "timestamp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nested patch parameter_urls = %v, want %v", got, want)
+	}
+
+	raw, err := c.DataService.DownloadJSON(context.Background(), downloadKey)
+	if err != nil {
+		t.Fatalf("DownloadJSON error: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+	if got, want := sortedKeys(doc), []string{"living_room", "name", "name_timestamp", "timestamp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("document keys = %v, want %v", got, want)
+	}
+	room, _ := doc["living_room"].(map[string]interface{})
+	if got, want := sortedKeys(room), []string{"temp", "timestamp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("living_room keys = %v, want %v", got, want)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
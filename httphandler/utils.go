@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"html"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
 )
 
 func sanitizeInput(input string) string {
@@ -26,3 +30,29 @@ func collectParams(params map[string][]string) map[string]string {
 	}
 	return paramMap
 }
+
+// setExpiryHeaders tells the client when the returned data will be deleted.
+// Cache-Control: no-cache keeps HTTP/1.1 caches from treating Expires as a
+// freshness lifetime, since values can change at any time before expiry.
+func setExpiryHeaders(w http.ResponseWriter, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Expires", expiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Expires-At", expiresAt.UTC().Format(time.RFC3339))
+	w.Header().Set("X-TTL-Seconds", strconv.FormatInt(data.RemainingTTLSeconds(expiresAt, time.Now()), 10))
+}
+
+// requestBaseURL returns the scheme and host the client used: https on the
+// native TLS listener and, behind a TLS terminating proxy, the scheme from
+// X-Forwarded-Proto, as on the index page.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
@@ -1,6 +1,7 @@
 package httphandler
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
@@ -146,3 +147,55 @@ func Test_collectParams(t *testing.T) {
 		})
 	}
 }
+
+func Test_requestBaseURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		tls            bool
+		forwardedProto string
+		expected       string
+	}{
+		{"plain http", false, "", "http://iot.example.com"},
+		{"native TLS", true, "", "https://iot.example.com"},
+		{"TLS terminating proxy", false, "https", "https://iot.example.com"},
+		{"invalid forwarded proto", false, "javascript", "http://iot.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/u/key", nil)
+			req.Host = "iot.example.com"
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+			if got := requestBaseURL(req); got != tt.expected {
+				t.Errorf("requestBaseURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_constructAndReturnResponse_TLS(t *testing.T) {
+	req := httptest.NewRequest("POST", "/u/key", nil)
+	req.Host = "iot.example.com"
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	constructAndReturnResponse(w, req, "d_abc", []string{"temp"})
+
+	var resp struct {
+		DownloadURL   string            `json:"download_url"`
+		ParameterURLs map[string]string `json:"parameter_urls"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.DownloadURL != "https://iot.example.com/d/d_abc/json" {
+		t.Errorf("download_url = %q", resp.DownloadURL)
+	}
+	if resp.ParameterURLs["temp"] != "https://iot.example.com/d/d_abc/plain/temp" {
+		t.Errorf("parameter_urls[temp] = %q", resp.ParameterURLs["temp"])
+	}
+}
@@ -0,0 +1,37 @@
+package httphandler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type apiVersionKey struct{}
+
+// APIVersion returns a middleware that marks requests as served by the
+// /v<version> route tree. Requests to the unversioned legacy routes have
+// version 0 and behave like v1.
+func APIVersion(version int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+// apiVersion returns the API version of r, see APIVersion.
+func apiVersion(r *http.Request) int {
+	version, _ := r.Context().Value(apiVersionKey{}).(int)
+	return version
+}
+
+// apiPrefix returns the path prefix of the route tree that served r, so
+// links in responses stay within the same API version.
+func apiPrefix(r *http.Request) string {
+	if version := apiVersion(r); version > 0 {
+		return fmt.Sprintf("/v%d", version)
+	}
+	return ""
+}
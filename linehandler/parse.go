@@ -0,0 +1,111 @@
+package linehandler
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+// lineWrite is one line translated to a patch.
+type lineWrite struct {
+	uploadKey string
+	path      string
+	values    map[string]interface{}
+}
+
+// parseLine translates a line in any of the supported formats. A line
+// starting with a valid upload key uses the simple format
+//
+//	<uploadKey> [path] key=value [key=value...]
+//
+// where keys and values may be percent-encoded. A line whose metric name
+// starts with a valid upload key uses the Graphite plaintext format
+//
+//	<uploadKey>.<path...>.<key> <value> [timestamp]
+//
+// Any other line is parsed as InfluxDB line protocol with the upload key in
+// the upload_key tag; the measurement and the remaining tag values form the
+// path.
+func parseLine(line string) (lineWrite, error) {
+	first, rest, _ := strings.Cut(line, " ")
+	if domain.ValidateUploadKey(first) == nil {
+		return parseSimpleLine(first, rest)
+	}
+	if prefix, _, ok := strings.Cut(first, "."); ok && domain.ValidateUploadKey(prefix) == nil {
+		return parseGraphiteLine(line)
+	}
+	return parseInfluxLine(line)
+}
+
+func parseSimpleLine(uploadKey, rest string) (lineWrite, error) {
+	fields := strings.Fields(rest)
+	w := lineWrite{uploadKey: uploadKey, values: make(map[string]interface{})}
+	if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		w.path = strings.Trim(fields[0], "/")
+		fields = fields[1:]
+	}
+
+	for _, field := range fields {
+		rawKey, rawValue, _ := strings.Cut(field, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || key == "" {
+			return lineWrite{}, fmt.Errorf("invalid key in %q", field)
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return lineWrite{}, fmt.Errorf("invalid value in %q", field)
+		}
+		w.values[key] = html.EscapeString(value)
+	}
+	if len(w.values) == 0 {
+		return lineWrite{}, fmt.Errorf("no values given")
+	}
+	return w, nil
+}
+
+func parseGraphiteLine(line string) (lineWrite, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return lineWrite{}, fmt.Errorf("invalid Graphite line %q: expected metric, value and optional timestamp", line)
+	}
+
+	segments := strings.Split(fields[0], ".")
+	key := segments[len(segments)-1]
+	if len(segments) < 2 || key == "" {
+		return lineWrite{}, fmt.Errorf("invalid Graphite line %q: missing metric name", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return lineWrite{}, fmt.Errorf("invalid Graphite line %q: invalid value %q", line, fields[1])
+	}
+
+	return lineWrite{
+		uploadKey: segments[0],
+		path:      strings.Join(segments[1:len(segments)-1], "/"),
+		values:    map[string]interface{}{key: value},
+	}, nil
+}
+
+func parseInfluxLine(line string) (lineWrite, error) {
+	p, err := data.ParseLineProtocol(line)
+	if err != nil {
+		return lineWrite{}, err
+	}
+	uploadKey, ok := p.Tags[data.UploadKeyTag]
+	if !ok {
+		return lineWrite{}, fmt.Errorf("missing %s tag in %q", data.UploadKeyTag, line)
+	}
+
+	for k, v := range p.Fields {
+		if s, ok := v.(string); ok {
+			p.Fields[k] = html.EscapeString(s)
+		}
+	}
+	return lineWrite{uploadKey: uploadKey, path: p.Path(data.UploadKeyTag), values: p.Fields}, nil
+}
@@ -0,0 +1,70 @@
+package linehandler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+)
+
+func TestParseLine(t *testing.T) {
+	uploadKey := domain.GenerateRandomKey()
+
+	tests := []struct {
+		name    string
+		line    string
+		want    lineWrite
+		wantErr bool
+	}{
+		{
+			name: "simple with path",
+			line: uploadKey + " room1/sensor temp=21.5 hum=40",
+			want: lineWrite{uploadKey: uploadKey, path: "room1/sensor", values: map[string]interface{}{"temp": "21.5", "hum": "40"}},
+		},
+		{
+			name: "simple without path",
+			line: "u_" + uploadKey + " temp=21.5",
+			want: lineWrite{uploadKey: "u_" + uploadKey, values: map[string]interface{}{"temp": "21.5"}},
+		},
+		{
+			name: "simple root path and encoded values",
+			line: uploadKey + " / name=living%20room note=%3Cb%3E empty=",
+			want: lineWrite{uploadKey: uploadKey, values: map[string]interface{}{"name": "living room", "note": "&lt;b&gt;", "empty": ""}},
+		},
+		{
+			name: "influx",
+			line: "weather,upload_key=" + uploadKey + `,room=kitchen temp=21.5,count=2i,label="<hot>" 1700000000`,
+			want: lineWrite{uploadKey: uploadKey, path: "weather/kitchen", values: map[string]interface{}{"temp": 21.5, "count": int64(2), "label": "&lt;hot&gt;"}},
+		},
+		{
+			name: "graphite",
+			line: "u_" + uploadKey + ".house.kitchen.temp 21.5 1700000000",
+			want: lineWrite{uploadKey: "u_" + uploadKey, path: "house/kitchen", values: map[string]interface{}{"temp": 21.5}},
+		},
+		{
+			name: "graphite without path",
+			line: uploadKey + ".temp -3",
+			want: lineWrite{uploadKey: uploadKey, values: map[string]interface{}{"temp": -3.0}},
+		},
+		{name: "graphite invalid value", line: uploadKey + ".temp warm", wantErr: true},
+		{name: "graphite missing metric", line: uploadKey + ". 1", wantErr: true},
+		{name: "graphite too many fields", line: uploadKey + ".temp 1 2 3", wantErr: true},
+		{name: "simple without values", line: uploadKey + " room1", wantErr: true},
+		{name: "simple invalid key", line: uploadKey + " =1", wantErr: true},
+		{name: "simple invalid encoding", line: uploadKey + " temp=%zz", wantErr: true},
+		{name: "influx without upload key", line: "weather temp=1", wantErr: true},
+		{name: "invalid influx", line: "weather,upload_key=" + uploadKey, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLine() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
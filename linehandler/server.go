@@ -0,0 +1,302 @@
+// Package linehandler accepts writes as plain text lines over UDP and TCP
+// for microcontrollers that cannot afford an HTTP stack and for agents that
+// speak Graphite plaintext or InfluxDB line protocol. Every line is
+// translated to a patch of data.Service, see parseLine for the formats.
+// Nothing is sent back to the client; invalid lines are logged and counted
+// as errors.
+package linehandler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+)
+
+// DefaultMaxLineLength is used if Config.MaxLineLength is zero.
+const DefaultMaxLineLength = 1024 * 10
+
+// DefaultIdleTimeout is used if Config.IdleTimeout is zero.
+const DefaultIdleTimeout = 2 * time.Minute
+
+// maxDatagramSize is the largest UDP payload read from the socket. Smaller
+// servers accept datagrams of at most datagramLines lines of MaxLineLength.
+const (
+	maxDatagramSize = 64 * 1024
+	datagramLines   = 4
+)
+
+// ErrServerClosed is returned by the Serve methods after Close.
+var ErrServerClosed = errors.New("line: server closed")
+
+// RateLimiter decides whether another request from a client IP is allowed.
+// middleware.Config implements it.
+type RateLimiter interface {
+	Allow(ip string) bool
+}
+
+// Config holds the dependencies of the line protocol listeners.
+type Config struct {
+	DataService   *data.Service
+	StatsInstance *stats.Stats
+
+	// RateLimiter limits the requests per client IP. Every line counts as
+	// one request, except empty lines and comments in UDP datagrams. Nil
+	// disables rate limiting.
+	RateLimiter RateLimiter
+
+	// MaxLineLength limits the length of a line. Zero means
+	// DefaultMaxLineLength.
+	MaxLineLength int
+
+	// IdleTimeout closes TCP connections without a line for this long. Zero
+	// means DefaultIdleTimeout.
+	IdleTimeout time.Duration
+}
+
+// Server serves the line protocol on any number of UDP and TCP listeners. It
+// must be created with NewServer.
+type Server struct {
+	Config
+
+	mu          sync.Mutex
+	closed      bool
+	packetConns []net.PacketConn
+	listeners   []net.Listener
+	conns       map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+// NewServer returns a Server for the given configuration.
+func NewServer(c Config) *Server {
+	if c.MaxLineLength == 0 {
+		c.MaxLineLength = DefaultMaxLineLength
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	return &Server{Config: c, conns: make(map[net.Conn]struct{})}
+}
+
+// ListenAndServeUDP listens on the UDP address addr and serves datagrams
+// until Close is called.
+func (s *Server) ListenAndServeUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeUDP(conn)
+}
+
+// ListenAndServeTCP listens on the TCP address addr and serves connections
+// until Close is called.
+func (s *Server) ListenAndServeTCP(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTCP(l)
+}
+
+// ServeUDP reads datagrams from conn. A datagram may contain several lines;
+// datagrams longer than datagramLimit are dropped.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	if !s.track(func() { s.packetConns = append(s.packetConns, conn) }) {
+		conn.Close()
+		return ErrServerClosed
+	}
+
+	// One spare byte detects datagrams truncated to the buffer size.
+	limit := s.datagramLimit()
+	buf := make([]byte, limit+1)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		ip := hostIP(addr)
+		if n > limit {
+			slog.Debug("line: datagram too long", "source", data.SourceUDP, "remote", ip)
+			s.StatsInstance.IncrementHTTPErrors()
+			continue
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if skipLine(string(line)) {
+				continue
+			}
+			// Every line is a write of its own, so it is charged like one
+			// and the rest of the datagram is dropped at the limit.
+			if !s.allow(ip, data.SourceUDP) {
+				break
+			}
+			s.handleLine(string(line), ip, data.SourceUDP)
+		}
+	}
+}
+
+// datagramLimit returns the size of the largest datagram accepted by
+// ServeUDP.
+func (s *Server) datagramLimit() int {
+	return min(maxDatagramSize, datagramLines*s.MaxLineLength)
+}
+
+// ServeTCP accepts connections from l and reads newline-separated lines from
+// each of them.
+func (s *Server) ServeTCP(l net.Listener) error {
+	if !s.track(func() { s.listeners = append(s.listeners, l) }) {
+		l.Close()
+		return ErrServerClosed
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		registered := s.track(func() {
+			s.conns[conn] = struct{}{}
+			s.wg.Add(1)
+		})
+		if !registered {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	ip := hostIP(conn.RemoteAddr())
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, min(4096, s.MaxLineLength)), s.MaxLineLength)
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		if s.allow(ip, data.SourceTCP) {
+			s.handleLine(scanner.Text(), ip, data.SourceTCP)
+		}
+	}
+	if err := scanner.Err(); err != nil && !s.isClosed() {
+		slog.Debug("line: closing connection", "error", err, "source", data.SourceTCP, "remote", ip)
+		if errors.Is(err, bufio.ErrTooLong) {
+			s.StatsInstance.IncrementHTTPErrors()
+		}
+	}
+}
+
+// Close stops all listeners, closes open connections and waits for the
+// lines being processed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var errs []error
+	for _, conn := range s.packetConns {
+		errs = append(errs, conn.Close())
+	}
+	for _, l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// track runs register under the lock unless the server is closed.
+func (s *Server) track(register func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	register()
+	return true
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) allow(ip, source string) bool {
+	if s.RateLimiter == nil || s.RateLimiter.Allow(ip) {
+		return true
+	}
+	slog.Error("line: rate limit exceeded", "remote_addr", ip, "source", source)
+	s.StatsInstance.IncrementHTTPErrors()
+	s.StatsInstance.RecordRateLimitHit(ip)
+	return false
+}
+
+// handleLine stores the values of one line. Empty lines and comments
+// starting with # are ignored.
+func (s *Server) handleLine(line, ip, source string) {
+	if skipLine(line) {
+		return
+	}
+	line = strings.TrimSpace(line)
+	if len(line) > s.MaxLineLength {
+		slog.Debug("line: line too long", "length", len(line), "source", source, "remote", ip)
+		s.StatsInstance.IncrementHTTPErrors()
+		return
+	}
+
+	w, err := parseLine(line)
+	if err != nil {
+		slog.Debug("line: invalid line", "error", err, "source", source, "remote", ip)
+		s.StatsInstance.IncrementHTTPErrors()
+		return
+	}
+
+	ctx := data.WithSource(context.Background(), source)
+	if _, _, err := s.DataService.PatchValues(ctx, w.uploadKey, w.path, w.values, data.MergeOptions{}); err != nil {
+		slog.Debug("line: failed to store data", "error", err, "source", source, "remote", ip)
+		s.StatsInstance.IncrementHTTPErrors()
+		return
+	}
+	s.StatsInstance.IncrementUploads()
+}
+
+// skipLine reports whether line is empty or a comment.
+func skipLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// hostIP returns the IP of a UDP or TCP address without the port.
+func hostIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,259 @@
+package linehandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+)
+
+// countingLimiter allows the first limit requests per IP.
+type countingLimiter struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[string]int
+}
+
+func (l *countingLimiter) Allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen[ip]++
+	return l.seen[ip] <= l.limit
+}
+
+func newTestServer(t *testing.T, limiter RateLimiter) (*Server, *data.Service, *stats.Stats) {
+	t.Helper()
+	si := storage.NewInMemoryStorage()
+	svc := &data.Service{StorageInstance: &si}
+	st := stats.NewStats()
+	srv := NewServer(Config{DataService: svc, StatsInstance: st, RateLimiter: limiter, MaxLineLength: 200})
+	return srv, svc, st
+}
+
+// waitForChange fails the test if changes receives no notification in time.
+func waitForChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for write")
+	}
+}
+
+func downloadDoc(t *testing.T, svc *data.Service, downloadKey string) map[string]interface{} {
+	t.Helper()
+	jsonData, err := svc.DownloadJSON(context.Background(), downloadKey)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return doc
+}
+
+func TestServeUDP(t *testing.T) {
+	srv, svc, st := newTestServer(t, nil)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.ServeUDP(conn) }()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	changes, cancel := svc.Subscribe(downloadKey)
+	defer cancel()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	datagram := strings.Join([]string{
+		"# comment",
+		uploadKey + " room1 temp=21",
+		"not a valid line",
+		"",
+		"weather,upload_key=" + uploadKey + ",room=room2 temp=19.5,ok=t",
+	}, "\n")
+	if _, err := client.Write([]byte(datagram)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	waitForChange(t, changes)
+	waitForChange(t, changes)
+
+	doc := downloadDoc(t, svc, downloadKey)
+	if room1, _ := doc["room1"].(map[string]interface{}); room1["temp"] != "21" {
+		t.Errorf("room1 = %v, want temp 21", doc["room1"])
+	}
+	weather, _ := doc["weather"].(map[string]interface{})
+	if room2, _ := weather["room2"].(map[string]interface{}); room2["temp"] != 19.5 || room2["ok"] != true {
+		t.Errorf("weather = %v, want room2 temp 19.5 and ok true", doc["weather"])
+	}
+
+	current := st.GetCurrentStats()
+	if current.UploadCount != 2 || current.HTTPErrorCount != 1 {
+		t.Errorf("stats = %d uploads, %d errors, want 2 and 1", current.UploadCount, current.HTTPErrorCount)
+	}
+
+	srv.Close()
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("ServeUDP returned %v, want ErrServerClosed", err)
+	}
+}
+
+func TestServeUDPLimits(t *testing.T) {
+	limiter := &countingLimiter{limit: 2, seen: make(map[string]int)}
+	srv, svc, st := newTestServer(t, limiter)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.ServeUDP(conn) }()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	changes, cancel := svc.Subscribe(downloadKey)
+	defer cancel()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	// A datagram over four times MaxLineLength is dropped as a whole.
+	oversized := uploadKey + " big value=1\n" + strings.Repeat("#", 4*200)
+	if _, err := client.Write([]byte(oversized)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// Every line is charged, the third one exceeds the rate limit.
+	var datagram strings.Builder
+	datagram.WriteString("# comment\n\n")
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(&datagram, "%s counter value=%d\n", uploadKey, i)
+	}
+	if _, err := client.Write([]byte(datagram.String())); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	waitForChange(t, changes)
+	waitForChange(t, changes)
+	select {
+	case <-changes:
+		t.Error("rate limited line was stored")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	doc := downloadDoc(t, svc, downloadKey)
+	if counter, _ := doc["counter"].(map[string]interface{}); counter["value"] != "2" {
+		t.Errorf("counter = %v, want value 2", doc["counter"])
+	}
+	if _, ok := doc["big"]; ok {
+		t.Error("oversized datagram was stored")
+	}
+	current := st.GetCurrentStats()
+	if current.RateLimitHitCount != 1 || current.HTTPErrorCount != 2 {
+		t.Errorf("stats = %d rate limit hits, %d errors, want 1 and 2", current.RateLimitHitCount, current.HTTPErrorCount)
+	}
+
+	srv.Close()
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("ServeUDP returned %v, want ErrServerClosed", err)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	limiter := &countingLimiter{limit: 2, seen: make(map[string]int)}
+	srv, svc, st := newTestServer(t, limiter)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.ServeTCP(l) }()
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+	changes, cancel := svc.Subscribe(downloadKey)
+	defer cancel()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(client, "%s counter value=%d\n", uploadKey, i)
+	}
+	waitForChange(t, changes)
+	waitForChange(t, changes)
+
+	// The third line exceeds the rate limit and is dropped.
+	select {
+	case <-changes:
+		t.Error("rate limited line was stored")
+	case <-time.After(100 * time.Millisecond):
+	}
+	doc := downloadDoc(t, svc, downloadKey)
+	if counter, _ := doc["counter"].(map[string]interface{}); counter["value"] != "2" {
+		t.Errorf("counter = %v, want value 2", doc["counter"])
+	}
+	if hits := st.GetCurrentStats().RateLimitHitCount; hits != 1 {
+		t.Errorf("rate limit hits = %d, want 1", hits)
+	}
+
+	// A line longer than MaxLineLength closes the connection.
+	fmt.Fprintf(client, "%s\n", strings.Repeat("x", 300))
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("expected connection to be closed, got %v", err)
+	}
+
+	srv.Close()
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("ServeTCP returned %v, want ErrServerClosed", err)
+	}
+}
+
+func TestServeTCPIdleTimeout(t *testing.T) {
+	srv, _, _ := newTestServer(t, nil)
+	srv.IdleTimeout = 50 * time.Millisecond
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	go srv.ServeTCP(l)
+	defer srv.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("expected idle connection to be closed, got %v", err)
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
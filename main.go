@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
@@ -12,17 +15,24 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/dhcgn/iot-ephemeral-value-store/certreload"
+	"github.com/dhcgn/iot-ephemeral-value-store/coaphandler"
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/domain"
+	"github.com/dhcgn/iot-ephemeral-value-store/grpchandler"
 	"github.com/dhcgn/iot-ephemeral-value-store/httphandler"
+	"github.com/dhcgn/iot-ephemeral-value-store/linehandler"
 	"github.com/dhcgn/iot-ephemeral-value-store/mcphandler"
 	"github.com/dhcgn/iot-ephemeral-value-store/middleware"
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
 	"github.com/dhcgn/iot-ephemeral-value-store/storage"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -34,13 +44,29 @@ const (
 	// Database and server paths
 	DefaultStorePath       = "./data"
 	DefaultPersistDuration = "24h"
+	DefaultStaleAfter      = "1h"
+	DefaultTimestampMode   = "legacy"
+	DefaultTimestampFormat = "rfc3339"
 
 	// Server timeout settings
 	WriteTimeout = 15 * time.Second
 	ReadTimeout  = 15 * time.Second
 
+	// TLSCheckInterval is how often the TLS certificate files are checked
+	// for changes.
+	TLSCheckInterval = 10 * time.Second
+
+	// DefaultShutdownTimeout is how long in-flight requests may take to
+	// finish after SIGINT or SIGTERM. Docker kills a container 10s after
+	// SIGTERM, which leaves a second to flush the database.
+	DefaultShutdownTimeout = "9s"
+
 	// HTTP server configuration
 	DefaultPort = 8080
+
+	// LegacyRoutesDeprecated is the date since which the unversioned API
+	// routes are deprecated in favor of /v1 and /v2.
+	LegacyRoutesDeprecated = "2026-10-18"
 )
 
 //go:embed static/*
@@ -48,9 +74,22 @@ var staticFiles embed.FS
 
 var (
 	persistDurationString string
+	staleAfterString      string
+	shutdownTimeoutString string
+	timestampModeFlag     string
+	timestampFormatFlag   string
 	storePath             string
 	port                  int
+	coapPort              int
+	lineUDPPort           int
+	lineTCPPort           int
+	grpcPort              int
+	legacySunsetFlag      string
+	legacyGetWrites       = true // default of -legacy-get-writes, also used by tests that skip initFlags
 	healthcheck           bool
+	tlsCertFile           string
+	tlsKeyFile            string
+	tlsClientCAFile       string
 	trustedProxiesFlag    string
 )
 
@@ -64,8 +103,21 @@ var (
 func initFlags() {
 	myFlags := flag.NewFlagSet("iot-ephemeral-value-store", flag.ExitOnError)
 	myFlags.StringVar(&persistDurationString, "persist-values-for", DefaultPersistDuration, "Duration for which the values are stored before they are deleted.")
+	myFlags.StringVar(&staleAfterString, "stale-after", DefaultStaleAfter, "Maximum age of a value before it is reported as stale by /d/{downloadKey}/status.")
+	myFlags.StringVar(&timestampModeFlag, "timestamp-mode", DefaultTimestampMode, "Server-generated timestamps added on every write: none, root, path, field or legacy.")
+	myFlags.StringVar(&timestampFormatFlag, "timestamp-format", DefaultTimestampFormat, "Encoding of server-generated timestamps: rfc3339 or unixms.")
 	myFlags.StringVar(&storePath, "store", DefaultStorePath, "Path to the directory where the values will be stored.")
 	myFlags.IntVar(&port, "port", DefaultPort, "The port number on which the server will listen.")
+	myFlags.IntVar(&coapPort, "coap-port", 0, "UDP port of the optional CoAP server (the standard port is 5683). 0 disables CoAP.")
+	myFlags.IntVar(&lineUDPPort, "line-udp-port", 0, "UDP port accepting writes as text lines (simple format or InfluxDB line protocol). 0 disables the listener.")
+	myFlags.IntVar(&lineTCPPort, "line-tcp-port", 0, "TCP port accepting writes as text lines (simple format or InfluxDB line protocol). 0 disables the listener.")
+	myFlags.IntVar(&grpcPort, "grpc-port", 0, "TCP port of the optional gRPC server. 0 disables gRPC.")
+	myFlags.BoolVar(&legacyGetWrites, "legacy-get-writes", true, "Accept uploads, patches, TTL renewals and deletes as GET requests on /u/, /patch/, /touch/ and /delete/ for devices that can only send GET. Disable to protect data from link prefetchers and crawlers.")
+	myFlags.StringVar(&legacySunsetFlag, "legacy-sunset", "", "Date (YYYY-MM-DD) announced in the Sunset header of the unversioned API routes. Empty omits the header.")
+	myFlags.StringVar(&shutdownTimeoutString, "shutdown-timeout", DefaultShutdownTimeout, "Time in-flight requests get to finish on SIGINT or SIGTERM before connections are closed and the database is flushed.")
+	myFlags.StringVar(&tlsCertFile, "tls-cert", "", "PEM certificate file (chain) to serve HTTPS on -port. Reloaded when the file changes or on SIGHUP. Requires -tls-key.")
+	myFlags.StringVar(&tlsKeyFile, "tls-key", "", "PEM private key file of -tls-cert.")
+	myFlags.StringVar(&tlsClientCAFile, "tls-client-ca", "", "PEM CA certificates. When set, HTTP uploads and other writes require a TLS client certificate signed by one of them; downloads stay open. Cannot be combined with -coap-port, -line-udp-port, -line-tcp-port or -grpc-port, which accept writes without certificates.")
 	myFlags.BoolVar(&healthcheck, "healthcheck", false, "Perform a health check against the running server and exit.")
 	myFlags.StringVar(&trustedProxiesFlag, "trusted-proxies", "", "Comma-separated list of trusted proxy CIDRs or IPs (e.g. 172.19.0.0/16). When set, X-Real-IP and X-Forwarded-For headers from these proxies are used for rate limiting.")
 
@@ -76,11 +128,18 @@ var (
 	createStorage = func(storePath string, persistDuration time.Duration) storage.StorageInstance {
 		return storage.NewPersistentStorage(storePath, persistDuration)
 	}
-	listenAndServe = func(srv *http.Server) {
-		if err := srv.ListenAndServe(); err != nil {
-			log.Fatal("Failed to start server:", err)
+	listenAndServe = func(srv *http.Server) error {
+		serve := srv.ListenAndServe
+		if srv.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate.
+			serve = func() error { return srv.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != http.ErrServerClosed {
+			return err
 		}
+		return nil
 	}
+	exit = os.Exit
 )
 
 func main() {
@@ -93,7 +152,9 @@ func main() {
 	initFlags()
 
 	if healthcheck {
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/health", port))
+		// The certificate is issued for the public name, not localhost.
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		resp, err := client.Get(fmt.Sprintf("%s://localhost:%d/health", serverScheme(), port))
 		if err != nil || resp.StatusCode != http.StatusOK {
 			os.Exit(1)
 		}
@@ -105,17 +166,79 @@ func main() {
 		log.Fatalf("Failed to parse duration: %v", err)
 	}
 
+	staleAfter, err := time.ParseDuration(staleAfterString)
+	if err != nil {
+		log.Fatalf("Failed to parse stale-after duration: %v", err)
+	}
+
+	shutdownTimeout, err := time.ParseDuration(shutdownTimeoutString)
+	if err != nil {
+		log.Fatalf("Failed to parse shutdown-timeout duration: %v", err)
+	}
+
+	timestampMode, err := data.ParseTimestampMode(timestampModeFlag)
+	if err != nil {
+		log.Fatalf("Failed to parse timestamp mode: %v", err)
+	}
+
+	timestampFormat, err := data.ParseTimestampFormat(timestampFormatFlag)
+	if err != nil {
+		log.Fatalf("Failed to parse timestamp format: %v", err)
+	}
+
+	if listeners := unprotectedWriteListeners(); tlsClientCAFile != "" && len(listeners) > 0 {
+		log.Fatalf("-tls-client-ca cannot be combined with %s: these listeners accept writes without a client certificate", strings.Join(listeners, ", "))
+	}
+
+	var tlsConfig *tls.Config
+	var certReloader *certreload.Reloader
+	if tlsCertFile != "" || tlsKeyFile != "" || tlsClientCAFile != "" {
+		tlsConfig, certReloader, err = newTLSConfig(tlsCertFile, tlsKeyFile, tlsClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+	}
+
+	legacyDeprecatedAt, _ := time.Parse(time.DateOnly, LegacyRoutesDeprecated)
+	var legacySunset time.Time
+	if legacySunsetFlag != "" {
+		legacySunset, err = time.Parse(time.DateOnly, legacySunsetFlag)
+		if err != nil {
+			log.Fatalf("Failed to parse legacy-sunset date: %v", err)
+		}
+	}
+
 	restStats := stats.NewStats()
 	mcpStats := stats.NewStats()
 
+	// A failed listener ends the process with status 1, after the deferred
+	// shutdown below has run.
+	failed := false
+	defer func() {
+		if failed {
+			exit(1)
+		}
+	}()
+
 	storage := createStorage(storePath, persistDuration)
-	defer storage.Close()
+	// Deferred first so it runs last, after all servers have stopped.
+	defer func() {
+		if err := storage.Close(); err != nil {
+			slog.Error("shutdown: failed to close storage", "error", err)
+		}
+	}()
 
-	dataService := &data.Service{StorageInstance: &storage}
+	dataService := &data.Service{
+		StorageInstance: &storage,
+		StaleAfter:      staleAfter,
+		TimestampMode:   timestampMode,
+		TimestampFormat: timestampFormat,
+	}
 
 	httphandlerConfig := httphandler.Config{
-		DataService:   dataService,
-		StatsInstance: restStats,
+		DataService:     dataService,
+		StatsInstance:   restStats,
+		PersistDuration: persistDuration,
 	}
 
 	middlewareConfig := middleware.Config{
@@ -124,9 +247,82 @@ func main() {
 		MaxRequestSize:     MaxRequestSize,
 		StatsInstance:      restStats,
 		TrustedProxies:     parseTrustedProxies(trustedProxiesFlag),
+		LegacyDeprecatedAt: legacyDeprecatedAt,
+		LegacySunset:       legacySunset,
+
+		RequireClientCertForWrites: tlsClientCAFile != "",
+	}
+
+	// Registered before serving, so that an early signal is not fatal.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Listeners report failures here instead of exiting, so that the
+	// shutdown still drains the HTTP requests and closes the storage.
+	serveErrs := make(chan error, 5)
+
+	if coapPort > 0 {
+		coapServer := coaphandler.NewServer(coaphandler.Config{
+			DataService:    dataService,
+			StatsInstance:  restStats,
+			RateLimiter:    middlewareConfig,
+			MaxPayloadSize: MaxRequestSize,
+		})
+		defer coapServer.Close()
+		go func() {
+			if err := coapServer.ListenAndServe(fmt.Sprintf(":%d", coapPort)); err != nil && err != coaphandler.ErrServerClosed {
+				serveErrs <- fmt.Errorf("CoAP server: %w", err)
+			}
+		}()
+		fmt.Printf("Starting CoAP server on coap://localhost:%v\n", coapPort)
 	}
 
-	r := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, &storage)
+	if lineUDPPort > 0 || lineTCPPort > 0 {
+		lineServer := linehandler.NewServer(linehandler.Config{
+			DataService:   dataService,
+			StatsInstance: restStats,
+			RateLimiter:   middlewareConfig,
+			MaxLineLength: MaxRequestSize,
+		})
+		defer lineServer.Close()
+		if lineUDPPort > 0 {
+			go func() {
+				if err := lineServer.ListenAndServeUDP(fmt.Sprintf(":%d", lineUDPPort)); err != nil && err != linehandler.ErrServerClosed {
+					serveErrs <- fmt.Errorf("UDP line listener: %w", err)
+				}
+			}()
+			fmt.Printf("Accepting line protocol on udp://localhost:%v\n", lineUDPPort)
+		}
+		if lineTCPPort > 0 {
+			go func() {
+				if err := lineServer.ListenAndServeTCP(fmt.Sprintf(":%d", lineTCPPort)); err != nil && err != linehandler.ErrServerClosed {
+					serveErrs <- fmt.Errorf("TCP line listener: %w", err)
+				}
+			}()
+			fmt.Printf("Accepting line protocol on tcp://localhost:%v\n", lineTCPPort)
+		}
+	}
+
+	if grpcPort > 0 {
+		grpcServer := grpchandler.NewServer(grpchandler.Config{
+			DataService:   dataService,
+			StatsInstance: restStats,
+		})
+		defer grpcServer.Stop()
+		go func() {
+			l, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+			if err != nil {
+				serveErrs <- fmt.Errorf("gRPC server: %w", err)
+				return
+			}
+			if err := grpcServer.Serve(l); err != nil && err != grpc.ErrServerStopped {
+				serveErrs <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+		fmt.Printf("Starting gRPC server on localhost:%v\n", grpcPort)
+	}
+
+	r, mcpServer := newRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, &storage)
 
 	serverAddress := fmt.Sprintf(":%d", port)
 	srv := &http.Server{
@@ -134,13 +330,214 @@ func main() {
 		Addr:         serverAddress,
 		WriteTimeout: WriteTimeout,
 		ReadTimeout:  ReadTimeout,
+		TLSConfig:    tlsConfig,
+	}
+	// MCP streams stay open until the client leaves; Shutdown would wait for
+	// them until the timeout.
+	srv.RegisterOnShutdown(mcpServer.Close)
+
+	if certReloader != nil {
+		go certReloader.Watch(ctx, TLSCheckInterval)
+		go reloadOnSIGHUP(ctx, certReloader)
 	}
 
-	fmt.Printf("Starting server on http://localhost:%v\n", port)
-	listenAndServe(srv)
+	fmt.Printf("Starting server on %s://localhost:%v\n", serverScheme(), port)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := listenAndServe(srv); err != nil {
+			serveErrs <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown: signal received, draining connections", "timeout", shutdownTimeout)
+	case serveErr = <-serveErrs:
+	case <-served:
+		// The error of the HTTP server is sent before served is closed.
+		select {
+		case serveErr = <-serveErrs:
+		default:
+		}
+	}
+	if serveErr != nil {
+		slog.Error("shutdown: listener failed, draining connections", "error", serveErr, "timeout", shutdownTimeout)
+		failed = true
+		stop()
+	}
+	shutdownServer(srv, shutdownTimeout)
+	<-served
+}
+
+// serverScheme returns the scheme of the HTTP server, https with -tls-cert.
+func serverScheme() string {
+	if tlsCertFile != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// unprotectedWriteListeners returns the flags of the enabled listeners
+// that accept writes outside the HTTP server, where -tls-client-ca cannot
+// require a client certificate.
+func unprotectedWriteListeners() []string {
+	var listeners []string
+	for _, l := range []struct {
+		flag string
+		port int
+	}{
+		{"-coap-port", coapPort},
+		{"-line-udp-port", lineUDPPort},
+		{"-line-tcp-port", lineTCPPort},
+		{"-grpc-port", grpcPort},
+	} {
+		if l.port > 0 {
+			listeners = append(listeners, l.flag)
+		}
+	}
+	return listeners
+}
+
+// newTLSConfig returns the TLS configuration of the HTTP server. With a
+// client CA, client certificates are verified if given; RequireClientCert
+// enforces them for writes only, so downloads stay open to browsers.
+func newTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, *certreload.Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("-tls-cert and -tls-key must be set together, -tls-client-ca requires both")
+	}
+	reloader, err := certreload.New(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile != "" {
+		pool, err := certreload.LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, reloader, nil
+}
+
+// reloadOnSIGHUP reloads the TLS certificate on SIGHUP until ctx is done.
+func reloadOnSIGHUP(ctx context.Context, reloader *certreload.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := reloader.Reload(); err != nil {
+				slog.Error("tls: failed to reload certificate on SIGHUP", "error", err)
+				continue
+			}
+			slog.Info("tls: reloaded certificate on SIGHUP")
+		}
+	}
+}
+
+// shutdownServer stops srv from accepting connections and waits up to
+// timeout for in-flight requests before closing the remaining connections.
+func shutdownServer(srv *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("shutdown: requests did not finish in time, closing connections", "error", err, "timeout", timeout)
+		srv.Close()
+	}
+}
+
+// registerAPIRoutes registers the data API routes of one route tree. Legacy
+// devices can only send GET requests, which link prefetchers and crawlers
+// follow as well. Without getWrites the upload, patch and touch routes accept
+// POST only and answer GET with 405, and /delete/ is not registered. Routes
+// that change data are wrapped with RequireClientCert.
+func registerAPIRoutes(r *mux.Router, hhc httphandler.Config, mc middleware.Config, getWrites bool) {
+	writeMethods := []string{"POST"}
+	if getWrites {
+		writeMethods = []string{"GET", "POST"}
+	}
+	handleWrite := func(path string, handler http.HandlerFunc) {
+		r.Handle(path, mc.RequireClientCert(handler)).Methods(writeMethods...)
+		if !getWrites {
+			r.Handle(path, httphandler.MethodNotAllowed{"POST"}).Methods("GET")
+		}
+	}
+
+	r.HandleFunc("/kp", hhc.KeyPairHandler).Methods("GET")
+	r.HandleFunc("/kp/qr", hhc.KeyPairQRHandler).Methods("GET")
+	r.HandleFunc("/qr/u/{uploadKey}.{format:png|svg}", hhc.UploadQRHandler).Methods("GET")
+	r.HandleFunc("/qr/d/{downloadKey}.{format:png|svg}", hhc.DownloadQRHandler).Methods("GET")
+	r.HandleFunc("/provision/{uploadKey}", hhc.ProvisionHandler).Methods("GET")
+
+	handleWrite("/u/{uploadKey}", hhc.UploadHandler)
+	handleWrite("/u/{uploadKey}/", hhc.UploadHandler)
+
+	r.HandleFunc("/d/{downloadKey}/json", hhc.DownloadJsonHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/status", hhc.DownloadStatusHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/query", hhc.DownloadQueryHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/csv", hhc.DownloadCSVHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/env", hhc.DownloadEnvHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/xml", hhc.DownloadXMLHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/plain/{param:.*}", hhc.DownloadPlainHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/plain-from-base64url/{param:.*}", hhc.DownloadBase64Handler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/badge/{param:.+}.svg", hhc.DownloadBadgeHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/sparkline/{param:.+}.svg", hhc.DownloadSparklineHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/render/{name}", hhc.DownloadRenderHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/ha", hhc.DownloadHomeAssistantHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/ha/{param:.+}", hhc.DownloadHomeAssistantHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/mqtt-discovery", hhc.DownloadMQTTDiscoveryHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}/", hhc.DownloadRootHandler).Methods("GET")
+	r.HandleFunc("/d/{downloadKey}", hhc.DownloadRootHandler).Methods("GET")
+
+	r.HandleFunc("/batch/download", hhc.BatchDownloadHandler).Methods("POST")
+	r.Handle("/batch/upload", mc.RequireClientCert(http.HandlerFunc(hhc.BatchUploadHandler))).Methods("POST")
+
+	handleWrite("/patch/{uploadKey}", hhc.UploadAndPatchHandler)
+	handleWrite("/patch/{uploadKey}/{param:.*}", hhc.UploadAndPatchHandler)
+
+	r.Handle("/templates/{uploadKey}/{name}", mc.RequireClientCert(http.HandlerFunc(hhc.TemplateHandler))).Methods("PUT")
+	r.Handle("/templates/{uploadKey}/{name}", mc.RequireClientCert(http.HandlerFunc(hhc.DeleteTemplateHandler))).Methods("DELETE")
+
+	handleWrite("/touch/{uploadKey}", hhc.TouchHandler)
+	handleWrite("/touch/{uploadKey}/", hhc.TouchHandler)
+
+	// Admin
+	if getWrites {
+		r.Handle("/delete/{uploadKey}", mc.RequireClientCert(http.HandlerFunc(hhc.DeleteHandler))).Methods("GET")
+		r.Handle("/delete/{uploadKey}/", mc.RequireClientCert(http.HandlerFunc(hhc.DeleteHandler))).Methods("GET")
+	}
+}
+
+// registerResourceRoutes registers the REST resource routes, which exist
+// only in the versioned route trees. The /keys routes change data and are
+// wrapped with RequireClientCert.
+func registerResourceRoutes(r *mux.Router, hhc httphandler.Config, mc middleware.Config) {
+	r.Handle("/keys/{uploadKey}", mc.RequireClientCert(http.HandlerFunc(hhc.UploadHandler))).Methods("PUT")
+	r.Handle("/keys/{uploadKey}", mc.RequireClientCert(http.HandlerFunc(hhc.UploadAndPatchHandler))).Methods("PATCH")
+	r.Handle("/keys/{uploadKey}/{param:.*}", mc.RequireClientCert(http.HandlerFunc(hhc.UploadAndPatchHandler))).Methods("PATCH")
+	r.Handle("/keys/{uploadKey}", mc.RequireClientCert(http.HandlerFunc(hhc.DeleteHandler))).Methods("DELETE")
+	r.HandleFunc("/values/{downloadKey}", hhc.DownloadJsonHandler).Methods("GET")
+	r.HandleFunc("/values/{downloadKey}/{param:.*}", hhc.DownloadValueHandler).Methods("GET")
 }
 
 func createRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats.Stats, mcpStats *stats.Stats, storageInst *storage.StorageInstance) *mux.Router {
+	r, _ := newRouter(hhc, mc, restStats, mcpStats, storageInst)
+	return r
+}
+
+// newRouter creates the router and returns the MCP server of its /mcp route
+// as well, which has to be closed on shutdown.
+func newRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats.Stats, mcpStats *stats.Stats, storageInst *storage.StorageInstance) (*mux.Router, *mcphandler.MCPServer) {
 	// Template parsing
 	tmpl, err := template.ParseFS(staticFiles, "static/index.html")
 	if err != nil {
@@ -165,14 +562,16 @@ func createRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats
 	mcpConfig := mcphandler.Config{
 		DataService:   hhc.DataService,
 		StatsInstance: mcpStats,
-		ServerHost:    fmt.Sprintf("http://localhost:%d", port),
+		ServerHost:    fmt.Sprintf("%s://localhost:%d", serverScheme(), port),
 		Version:       Version,
 	}
 	mcpServer, err := mcphandler.NewMCPServer(mcpConfig)
 	if err != nil {
 		log.Fatal("Error creating MCP server:", err)
 	}
-	r.HandleFunc("/mcp", mcpServer.ServeHTTP).Methods("GET", "POST")
+	// GET only returns server information, tool calls are POSTed.
+	r.HandleFunc("/mcp", mcpServer.ServeHTTP).Methods("GET")
+	r.Handle("/mcp", mc.RequireClientCert(mcpServer)).Methods("POST")
 
 	// OAuth 2.0 Authorization Server Metadata stub (RFC 8414 / MCP spec requirement).
 	// This server does not implement OAuth; the stub suppresses "needs authentication"
@@ -196,8 +595,6 @@ func createRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats
 		json.NewEncoder(w).Encode(health)
 	}).Methods("GET")
 
-	r.HandleFunc("/kp", hhc.KeyPairHandler).Methods("GET")
-
 	// Static files that need explicit handling before upload routes
 	r.HandleFunc("/llm.txt", func(w http.ResponseWriter, r *http.Request) {
 		content, err := staticFiles.ReadFile("static/llm.txt")
@@ -209,24 +606,39 @@ func createRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats
 		w.Write(content)
 	}).Methods("GET")
 
+	// The OpenAPI document is generated after all routes are registered.
+	var openAPISpec []byte
+	r.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}).Methods("GET")
+
+	// Documentation of the problem types of error responses
+	r.HandleFunc("/problems/{code}", hhc.ProblemTypeHandler).Methods("GET")
+
 	// Viewer page
 	r.HandleFunc("/viewer", viewerHandler())
 
-	r.HandleFunc("/u/{uploadKey}", hhc.UploadHandler).Methods("GET")
-	r.HandleFunc("/u/{uploadKey}/", hhc.UploadHandler).Methods("GET")
+	// InfluxDB 2 compatible write endpoint, at the path InfluxDB clients use
+	r.Handle("/api/v2/write", mc.RequireClientCert(http.HandlerFunc(hhc.InfluxWriteHandler))).Methods("POST")
 
-	r.HandleFunc("/d/{downloadKey}/json", hhc.DownloadJsonHandler).Methods("GET")
-	r.HandleFunc("/d/{downloadKey}/plain/{param:.*}", hhc.DownloadPlainHandler).Methods("GET")
-	r.HandleFunc("/d/{downloadKey}/plain-from-base64url/{param:.*}", hhc.DownloadBase64Handler).Methods("GET")
-	r.HandleFunc("/d/{downloadKey}/", hhc.DownloadRootHandler).Methods("GET")
-	r.HandleFunc("/d/{downloadKey}", hhc.DownloadRootHandler).Methods("GET")
+	// Data API route trees. /v1 serves the current behavior and /v2 returns
+	// the v2 document format with separate metadata and accepts writes only
+	// with write methods. The unversioned legacy paths serve v1 and announce
+	// their removal with Deprecation and Sunset headers.
+	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.Use(httphandler.APIVersion(1))
+	registerAPIRoutes(v1, hhc, mc, legacyGetWrites)
+	registerResourceRoutes(v1, hhc, mc)
 
-	r.HandleFunc("/patch/{uploadKey}", hhc.UploadAndPatchHandler).Methods("GET")
-	r.HandleFunc("/patch/{uploadKey}/{param:.*}", hhc.UploadAndPatchHandler).Methods("GET")
+	v2 := r.PathPrefix("/v2").Subrouter()
+	v2.Use(httphandler.APIVersion(2))
+	registerAPIRoutes(v2, hhc, mc, false)
+	registerResourceRoutes(v2, hhc, mc)
 
-	// Admin
-	r.HandleFunc("/delete/{uploadKey}", hhc.DeleteHandler).Methods("GET")
-	r.HandleFunc("/delete/{uploadKey}/", hhc.DeleteHandler).Methods("GET")
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(mc.Deprecated)
+	registerAPIRoutes(legacy, hhc, mc, legacyGetWrites)
 
 	r.HandleFunc("/", templateHandler(tmpl, restStats, mcpStats))
 
@@ -239,7 +651,12 @@ func createRouter(hhc httphandler.Config, mc middleware.Config, restStats *stats
 	staticSubFS, _ := fs.Sub(staticFiles, "static")
 	r.PathPrefix("/").Handler(http.FileServer(http.FS(staticSubFS)))
 
-	return r
+	openAPISpec, err = buildOpenAPISpec(r)
+	if err != nil {
+		log.Fatal("Error generating OpenAPI document:", err)
+	}
+
+	return r, mcpServer
 }
 
 func templateHandler(tmpl *template.Template, restStats *stats.Stats, mcpStats *stats.Stats) http.HandlerFunc {
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/httphandler"
 	"github.com/dhcgn/iot-ephemeral-value-store/middleware"
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
 	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +48,7 @@ func createTestEnvironment(t *testing.T) (*stats.Stats, *stats.Stats, httphandle
 		RateLimitBurst:     RateLimitBurst,
 		MaxRequestSize:     MaxRequestSize,
 		StatsInstance:      restStats,
+		LegacyDeprecatedAt: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
 	}
 
 	return restStats, mcpStats, httphandlerConfig, middlewareConfig, &storageInMemory
@@ -81,6 +87,12 @@ func TestCreateRouter(t *testing.T) {
 	tests := []testCase{
 		{"GET /", "/", http.StatusOK, false, "", ""},
 		{"GET /kp", "/kp", http.StatusOK, false, "", ""},
+		{"GET /kp/qr", "/kp/qr", http.StatusOK, true, "<svg", ""},
+		{"GET /v1/qr/u/{uploadKey}.svg", buildURL("/v1/qr/u/%s.svg", keyUp), http.StatusOK, true, "<svg", ""},
+		{"GET /v1/qr/d/{downloadKey}.png", buildURL("/v1/qr/d/%s.png", keyDown), http.StatusOK, true, "PNG", ""},
+		{"GET /v1/qr/u/{uploadKey}.gif", buildURL("/v1/qr/u/%s.gif", keyUp), http.StatusNotFound, false, "", ""},
+		{"GET /v1/provision/{uploadKey}", buildURL("/v1/provision/%s?path=room1", keyUp), http.StatusOK, true, "/v1/patch/u_" + keyUp + "/room1", ""},
+		{"GET /v2/provision/{uploadKey} arduino", buildURL("/v2/provision/%s?format=arduino", keyUp), http.StatusOK, true, "#define IOT_UPLOAD_URL", ""},
 		{"unknown path", "/wrong_upload_key", http.StatusNotFound, false, "", ""},
 		{"OAuth well-known", "/.well-known/oauth-authorization-server", http.StatusOK, true, `"issuer"`, ""},
 	}
@@ -96,6 +108,7 @@ func TestRoutesUploadDownload(t *testing.T) {
 		{"Upload", buildURL("/u/%s/?value=8923423", keyUp), http.StatusOK, true, "Data uploaded successfully", ""},
 		{"Download Plain", buildURL("/d/%s/plain/value", keyDown), http.StatusOK, true, "8923423\n", ""},
 		{"Download JSON", buildURL("/d/%s/json", keyDown), http.StatusOK, true, "\"value\":\"8923423\"", ""},
+		{"Download status", buildURL("/d/%s/status", keyDown), http.StatusOK, true, "\"stale_paths\":[]", ""},
 	}
 
 	runTests(t, router, tests)
@@ -123,9 +136,11 @@ func TestRoutesUploadDownloadDelete(t *testing.T) {
 		{"Upload", buildURL("/u/%s/?value=8923423", keyUp), http.StatusOK, true, "Data uploaded successfully", ""},
 		{"Download plain", buildURL("/d/%s/plain/value", keyDown), http.StatusOK, true, "8923423\n", ""},
 		{"Download json", buildURL("/d/%s/json", keyDown), http.StatusOK, true, "\"value\":\"8923423\"", ""},
+		{"Touch", buildURL("/touch/%s", keyUp), http.StatusOK, true, "expires_at", ""},
 		{"Delete", buildURL("/delete/%s/", keyUp), http.StatusOK, true, "OK", ""},
 		{"Download after delete plain", buildURL("/d/%s/plain/value", keyDown), http.StatusNotFound, false, "", ""},
 		{"Download after delete json", buildURL("/d/%s/json", keyDown), http.StatusNotFound, false, "", ""},
+		{"Touch after delete", buildURL("/touch/%s", keyUp), http.StatusNotFound, false, "", ""},
 	}
 
 	runTests(t, router, tests)
@@ -144,7 +159,411 @@ func TestRoutesPatchDownload(t *testing.T) {
 		{"Download json level 0", buildURL("/d/%s/json", keyDown), http.StatusOK, true, "\"value\":\"1_4324232\"", ""},
 		{"Download json level 2", buildURL("/d/%s/json", keyDown), http.StatusOK, true, "\"value\":\"2_8923423\"", ""},
 		{"Download not contains empty key", buildURL("/d/%s/json", keyDown), http.StatusOK, false, "", "\"\""},
+		{"Download csv", buildURL("/d/%s/csv", keyDown), http.StatusOK, true, "1/2/value,2_8923423", ""},
+		{"Download env", buildURL("/d/%s/env", keyDown), http.StatusOK, true, "1_2_value=2_8923423", ""},
+		{"Download xml", buildURL("/d/%s/xml", keyDown), http.StatusOK, true, `<value path="1/2/value">2_8923423</value>`, ""},
+		{"Download badge", buildURL("/d/%s/badge/1/2/value.svg", keyDown), http.StatusOK, true, `aria-label="value: 2_8923423"`, ""},
+		{"Download badge missing", buildURL("/d/%s/badge/1/2/missing.svg", keyDown), http.StatusNotFound, true, "missing: n/a", ""},
+		{"Query recursive", buildURL("/d/%s/query?q=$..value", keyDown), http.StatusOK, true, "[\"1_4324232\",\"2_8923423\"]", ""},
+		{"Query invalid", buildURL("/d/%s/query?q=value", keyDown), http.StatusBadRequest, false, "", ""},
 	}
 
 	runTests(t, router, tests)
 }
+
+func TestRoutesBatchDownload(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	runTests(t, router, []testCase{
+		{"Upload for batch", buildURL("/u/%s/?temp=21", keyUp), http.StatusOK, true, "Data uploaded successfully", ""},
+	})
+
+	body := fmt.Sprintf(`{"keys": [{"download_key": "%s", "paths": ["temp"]}]}`, keyDown)
+	req, err := http.NewRequest(http.MethodPost, "/batch/download", strings.NewReader(body))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"values":{"temp":"21"}`)
+}
+
+func TestRoutesInfluxWrite(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	req, err := http.NewRequest(http.MethodPost, "/api/v2/write?org=home&bucket="+keyUp, strings.NewReader("weather,room=kitchen temp=21.5"))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	runTests(t, router, []testCase{
+		{"Download written value", buildURL("/d/%s/plain/weather/kitchen/temp", keyDown), http.StatusOK, true, "21.5", ""},
+	})
+}
+
+func TestRoutesTemplates(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	runTests(t, router, []testCase{
+		{"Upload for template", buildURL("/u/%s/?temp=21.46&hum=40", keyUp), http.StatusOK, true, "Data uploaded successfully", ""},
+	})
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+		bodyContains       string
+	}{
+		{"Put template", http.MethodPut, "/v1/templates/" + keyUp + "/lcd", "T:{{.temp | round 1}} H:{{.hum}}", http.StatusOK, "/v1/d/" + keyDown + "/render/lcd"},
+		{"Render", http.MethodGet, "/v1/d/" + keyDown + "/render/lcd", "", http.StatusOK, "T:21.5 H:40"},
+		{"Render legacy path", http.MethodGet, "/d/" + keyDown + "/render/lcd", "", http.StatusOK, "T:21.5 H:40"},
+		{"Delete template", http.MethodDelete, "/v2/templates/" + keyUp + "/lcd", "", http.StatusOK, "OK"},
+		{"Render deleted", http.MethodGet, "/v1/d/" + keyDown + "/render/lcd", "", http.StatusNotFound, `"code":"not_found"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.bodyContains)
+		})
+	}
+}
+
+func TestRoutesHomeAssistant(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	runTests(t, router, []testCase{
+		{"Upload for Home Assistant", buildURL("/u/%s/?temp=21.5", keyUp), http.StatusOK, true, "Data uploaded successfully", ""},
+		{"RESTful sensor", buildURL("/v1/d/%s/ha/temp?unit=%%C2%%B0C", keyDown), http.StatusOK, true, `"state":21.5`, ""},
+		{"RESTful sensor all fields", buildURL("/v2/d/%s/ha", keyDown), http.StatusOK, true, `"attributes":{"temp":"21.5"`, ""},
+		{"MQTT discovery", buildURL("/v1/d/%s/mqtt-discovery?unit.temp=%%C2%%B0C", keyDown), http.StatusOK, true, `"unit_of_measurement":"°C"`, "temp_timestamp"},
+	})
+}
+
+func TestRoutesRESTResources(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+		bodyContains       string
+	}{
+		{"Put", http.MethodPut, "/v1/keys/" + keyUp, `{"temp":21.5}`, http.StatusOK, "Data uploaded successfully"},
+		{"Patch root", http.MethodPatch, "/v1/keys/" + keyUp, `{"hum":40}`, http.StatusOK, "Data uploaded successfully"},
+		{"Patch path", http.MethodPatch, "/v1/keys/" + keyUp + "/room1", `{"temp":19}`, http.StatusOK, "Data uploaded successfully"},
+		{"Get values", http.MethodGet, "/v1/values/" + keyDown, "", http.StatusOK, `"hum":40`},
+		{"Get nested value", http.MethodGet, "/v1/values/" + keyDown + "/room1", "", http.StatusOK, `"temp":19`},
+		{"Get missing value", http.MethodGet, "/v1/values/" + keyDown + "/missing", "", http.StatusNotFound, `"code":"not_found"`},
+		{"Preflight", http.MethodOptions, "/v1/keys/" + keyUp, "", http.StatusOK, ""},
+		{"Delete", http.MethodDelete, "/v1/keys/" + keyUp, "", http.StatusOK, "OK"},
+		{"Get after delete", http.MethodGet, "/v1/values/" + keyDown, "", http.StatusNotFound, `"code":"not_found"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			assert.NoError(t, err)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.bodyContains)
+			assert.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), "PATCH")
+		})
+	}
+}
+
+func TestRoutesProvisionedHomeAssistantURL(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	req := httptest.NewRequest(http.MethodGet, buildURL("/v1/provision/%s?format=homeassistant&path=room", keyUp), nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var uploadURL string
+	for _, line := range strings.Split(rr.Body.String(), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "url: "); ok {
+			uploadURL = strings.Trim(rest, `"`)
+		}
+	}
+	u, err := url.Parse(strings.ReplaceAll(uploadURL, "{{ value }}", "21"))
+	assert.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodPost, u.RequestURI(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, buildURL("/v1/d/%s/plain/room/value", keyDown), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "21\n", rr.Body.String())
+}
+
+func TestRoutesWithoutLegacyGetWrites(t *testing.T) {
+	legacyGetWrites = false
+	defer func() { legacyGetWrites = true }()
+
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	runTests(t, router, []testCase{
+		{"Upload via GET", buildURL("/u/%s/?value=1", keyUp), http.StatusMethodNotAllowed, false, "", ""},
+		{"Patch via GET", buildURL("/patch/%s/room?value=1", keyUp), http.StatusMethodNotAllowed, false, "", ""},
+		{"Touch via GET", buildURL("/touch/%s", keyUp), http.StatusMethodNotAllowed, false, "", ""},
+		{"v1 touch via GET", buildURL("/v1/touch/%s", keyUp), http.StatusMethodNotAllowed, false, "", ""},
+		{"Delete via GET", buildURL("/delete/%s", keyUp), http.StatusNotFound, false, "", ""},
+	})
+
+	req, err := http.NewRequest(http.MethodGet, buildURL("/touch/%s", keyUp), nil)
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, "POST", rr.Header().Get("Allow"))
+	assert.Contains(t, rr.Body.String(), `"code":"method_not_allowed"`)
+
+	req, err = http.NewRequest(http.MethodPost, buildURL("/u/%s?value=1", keyUp), nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	req, err = http.NewRequest(http.MethodPost, buildURL("/touch/%s", keyUp), nil)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRoutesRequireClientCert(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	middlewareConfig.RequireClientCertForWrites = true
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		expectedStatusCode int
+	}{
+		{"legacy upload", http.MethodGet, "/u/" + keyUp + "?temp=21", http.StatusForbidden},
+		{"v1 patch", http.MethodPost, "/v1/patch/" + keyUp + "/room?temp=21", http.StatusForbidden},
+		{"v2 touch", http.MethodPost, "/v2/touch/" + keyUp, http.StatusForbidden},
+		{"v2 resource write", http.MethodPut, "/v2/keys/" + keyUp, http.StatusForbidden},
+		{"batch upload", http.MethodPost, "/v1/batch/upload", http.StatusForbidden},
+		{"template", http.MethodPut, "/v1/templates/" + keyUp + "/page", http.StatusForbidden},
+		{"legacy delete", http.MethodGet, "/delete/" + keyUp, http.StatusForbidden},
+		{"influx write", http.MethodPost, "/api/v2/write?bucket=" + keyUp, http.StatusForbidden},
+		{"mcp call", http.MethodPost, "/mcp", http.StatusForbidden},
+		{"mcp info", http.MethodGet, "/mcp", http.StatusOK},
+		{"download", http.MethodGet, "/v1/d/" + keyDown + "/json", http.StatusNotFound},
+		{"batch download", http.MethodPost, "/v1/batch/download", http.StatusBadRequest},
+		{"key pair", http.MethodGet, "/kp", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			req.RemoteAddr = "127.0.0.1:1234"
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			if tt.expectedStatusCode == http.StatusForbidden {
+				assert.Contains(t, rr.Body.String(), `"code":"client_certificate_required"`)
+			}
+		})
+	}
+}
+
+func TestRoutesVersionTrees(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+		bodyContains       string
+		deprecated         bool
+	}{
+		{"v1 upload via GET", http.MethodGet, "/v1/u/" + keyUp + "?temp=21", "", http.StatusOK, "/v1/d/" + keyDown + "/json", false},
+		{"v2 upload via GET", http.MethodGet, "/v2/u/" + keyUp + "?temp=21", "", http.StatusMethodNotAllowed, "", false},
+		{"v2 upload via POST", http.MethodPost, "/v2/u/" + keyUp + "?temp=22", "", http.StatusOK, "/v2/d/" + keyDown + "/json", false},
+		{"v1 download", http.MethodGet, "/v1/d/" + keyDown + "/json", "", http.StatusOK, `"temp":"22"`, false},
+		{"v2 download", http.MethodGet, "/v2/d/" + keyDown + "/json", "", http.StatusOK, `"values":{"temp":"22"`, false},
+		{"v2 values", http.MethodGet, "/v2/values/" + keyDown, "", http.StatusOK, `"meta":`, false},
+		{"legacy download", http.MethodGet, "/d/" + keyDown + "/json", "", http.StatusOK, `"temp":"22"`, true},
+		{"influx write is not versioned", http.MethodPost, "/api/v2/write?bucket=" + keyUp, "weather temp=21.5", http.StatusNoContent, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.bodyContains)
+			if tt.deprecated {
+				assert.Equal(t, "@1792281600", rr.Header().Get("Deprecation"))
+				assert.Equal(t, `</v1/d/`+keyDown+`/json>; rel="successor-version"`, rr.Header().Get("Link"))
+			} else {
+				assert.Empty(t, rr.Header().Get("Deprecation"))
+			}
+		})
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	for _, getWrites := range []bool{true, false} {
+		t.Run(fmt.Sprintf("legacy-get-writes=%v", getWrites), func(t *testing.T) {
+			legacyGetWrites = getWrites
+			defer func() { legacyGetWrites = true }()
+
+			restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+			router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+
+			req, err := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+			assert.NoError(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var spec struct {
+				OpenAPI string                                `json:"openapi"`
+				Paths   map[string]map[string]json.RawMessage `json:"paths"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+			assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."), "openapi version %q", spec.OpenAPI)
+
+			registered := make(map[string]map[string]bool)
+			err = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+				tpl, err := route.GetPathTemplate()
+				if err != nil || route.GetHandler() == nil {
+					return nil
+				}
+				if _, ok := route.GetHandler().(httphandler.MethodNotAllowed); ok {
+					return nil
+				}
+				methods, err := route.GetMethods()
+				if err != nil {
+					methods = []string{http.MethodGet}
+				}
+				path := openAPIPath(tpl)
+				if registered[path] == nil {
+					registered[path] = make(map[string]bool)
+				}
+				for _, method := range methods {
+					registered[path][strings.ToLower(method)] = true
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+
+			// The documented methods of every path are exactly the
+			// registered ones.
+			for path, methods := range registered {
+				var documented []string
+				for method := range spec.Paths[path] {
+					documented = append(documented, method)
+				}
+				assert.ElementsMatch(t, methodList(methods), documented, "methods of %s", path)
+			}
+			for path := range spec.Paths {
+				assert.NotNil(t, registered[path], "the OpenAPI document lists %s, which is not registered", path)
+			}
+			if getWrites {
+				_, documented := spec.Paths["/delete/{uploadKey}"]["get"]
+				assert.True(t, documented, "GET /delete/{uploadKey} is not documented")
+			} else {
+				_, documented := spec.Paths["/u/{uploadKey}"]["get"]
+				assert.False(t, documented, "GET /u/{uploadKey} is documented without GET writes")
+			}
+		})
+	}
+}
+
+// TestOpenAPIMetadataUsed fails for operations in openapi/ that do not
+// belong to a registered route.
+func TestOpenAPIMetadataUsed(t *testing.T) {
+	restStats, mcpStats, httphandlerConfig, middlewareConfig, storageInst := createTestEnvironment(t)
+	router := createRouter(httphandlerConfig, middlewareConfig, restStats, mcpStats, storageInst)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+
+	documented := func(path, method string) bool {
+		_, ok := spec.Paths[path][method]
+		return ok
+	}
+	for _, name := range []string{"openapi/base.json", "openapi/tree.json"} {
+		content, err := openAPIFiles.ReadFile(name)
+		assert.NoError(t, err)
+		var file struct {
+			Paths     openAPIOperations `json:"paths"`
+			Overrides map[string]struct {
+				Paths openAPIOperations `json:"paths"`
+			} `json:"overrides"`
+		}
+		assert.NoError(t, json.Unmarshal(content, &file))
+		for path, operations := range file.Paths {
+			for method := range operations {
+				used := documented(path, method)
+				if name == "openapi/tree.json" {
+					used = used || documented("/v1"+path, method) || documented("/v2"+path, method)
+				}
+				assert.True(t, used, "%s documents %s %s, which is not registered", name, strings.ToUpper(method), path)
+			}
+		}
+		for prefix, override := range file.Overrides {
+			for path, operations := range override.Paths {
+				for method := range operations {
+					assert.True(t, documented(prefix+path, method), "%s overrides %s %s%s, which is not registered", name, strings.ToUpper(method), prefix, path)
+				}
+			}
+		}
+	}
+}
+
+func methodList(methods map[string]bool) []string {
+	var list []string
+	for method := range methods {
+		list = append(list, method)
+	}
+	return list
+}
@@ -1,12 +1,28 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dhcgn/iot-ephemeral-value-store/certreload"
 	"github.com/dhcgn/iot-ephemeral-value-store/storage"
 )
 
@@ -18,7 +34,7 @@ func TestMainFunction(t *testing.T) {
 	createStorage = func(storePath string, persistDuration time.Duration) storage.StorageInstance {
 		return storage.NewInMemoryStorage()
 	}
-	listenAndServe = func(srv *http.Server) {
+	listenAndServe = func(srv *http.Server) error {
 		// Create a test server
 		ts := httptest.NewServer(srv.Handler)
 		defer ts.Close()
@@ -33,12 +49,297 @@ func TestMainFunction(t *testing.T) {
 		if resp.StatusCode != http.StatusOK {
 			t.Fatalf("Expected status OK, got %v", resp.Status)
 		}
+		return nil
 	}
 
 	// Call the main function
 	main()
 }
 
+func TestMainGracefulShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending os.Interrupt to the own process is not supported on Windows")
+	}
+	os.Args = []string{"cmd", "-store=./testdata", "-port=8082", "-shutdown-timeout=5s"}
+
+	var db *badger.DB
+	createStorage = func(storePath string, persistDuration time.Duration) (s storage.StorageInstance) {
+		s = storage.NewInMemoryStorage()
+		db = s.Db
+		return
+	}
+
+	result := make(chan string, 1)
+	listenAndServe = func(srv *http.Server) error {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+
+		// A request that is still running when the signal arrives.
+		started := make(chan struct{})
+		handler := srv.Handler
+		srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/slow" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("drained"))
+		})
+		go func() {
+			resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			result <- string(body)
+		}()
+		go func() {
+			<-started
+			p, _ := os.FindProcess(os.Getpid())
+			p.Signal(os.Interrupt)
+		}()
+
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			t.Errorf("Expected http.ErrServerClosed, got %v", err)
+		}
+		return nil
+	}
+
+	main()
+
+	select {
+	case got := <-result:
+		if got != "drained" {
+			t.Errorf("Expected the in-flight request to finish, got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("In-flight request did not finish")
+	}
+	if !db.IsClosed() {
+		t.Error("Expected the storage to be closed after shutdown")
+	}
+}
+
+func TestMainListenerFailure(t *testing.T) {
+	// The CoAP port is taken, so the CoAP server fails after the HTTP
+	// server has started.
+	taken, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer taken.Close()
+	coapPort := taken.LocalAddr().(*net.UDPAddr).Port
+	os.Args = []string{"cmd", "-store=./testdata", "-port=8084", fmt.Sprintf("-coap-port=%d", coapPort)}
+
+	var db *badger.DB
+	createStorage = func(storePath string, persistDuration time.Duration) (s storage.StorageInstance) {
+		s = storage.NewInMemoryStorage()
+		db = s.Db
+		return
+	}
+	listenAndServe = func(srv *http.Server) error {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			t.Errorf("Expected http.ErrServerClosed, got %v", err)
+		}
+		return nil
+	}
+	exitCode := 0
+	exit = func(code int) { exitCode = code }
+	defer func() { exit = os.Exit }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		main()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected main to shut down after the listener failed")
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", exitCode)
+	}
+	if !db.IsClosed() {
+		t.Error("Expected the storage to be closed after shutdown")
+	}
+}
+
+func TestShutdownServerTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go srv.Serve(l)
+	go http.Get("http://" + l.Addr().String() + "/")
+	<-started
+
+	start := time.Now()
+	shutdownServer(srv, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected shutdownServer to give up after the timeout, took %v", elapsed)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 that is
+// valid as server certificate, client CA and client certificate.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "iot-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestMainTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	os.Args = []string{"cmd", "-store=./testdata", "-port=8083", "-tls-cert=" + certFile, "-tls-key=" + keyFile, "-tls-client-ca=" + certFile}
+
+	createStorage = func(storePath string, persistDuration time.Duration) storage.StorageInstance {
+		return storage.NewInMemoryStorage()
+	}
+	listenAndServe = func(srv *http.Server) error {
+		if srv.TLSConfig == nil {
+			t.Fatal("Expected a TLS configuration")
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		go srv.ServeTLS(l, "", "")
+		base := "https://" + l.Addr().String()
+
+		pool, err := certreload.LoadCertPool(certFile)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		device := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}}}}
+		uploadKey := "8e88f1b62b946dd3fccfd8eaf54c9a2e5e27747c3662f2e20645073e4626d7c5"
+
+		tests := []struct {
+			name           string
+			client         *http.Client
+			method         string
+			path           string
+			expectedStatus int
+			bodyContains   string
+		}{
+			{"index without client certificate", anonymous, "GET", "/", http.StatusOK, ""},
+			{"upload without client certificate", anonymous, "POST", "/v1/u/" + uploadKey + "?temp=21", http.StatusForbidden, "client_certificate_required"},
+			{"upload with client certificate", device, "POST", "/v1/u/" + uploadKey + "?temp=21", http.StatusOK, `"download_url":"https://`},
+		}
+		for _, tt := range tests {
+			req, _ := http.NewRequest(tt.method, base+tt.path, nil)
+			resp, err := tt.client.Do(req)
+			if err != nil {
+				t.Fatalf("%s: request failed: %v", tt.name, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("%s: got status %v want %v", tt.name, resp.StatusCode, tt.expectedStatus)
+			}
+			if !strings.Contains(string(body), tt.bodyContains) {
+				t.Errorf("%s: body %q does not contain %q", tt.name, body, tt.bodyContains)
+			}
+		}
+		return nil
+	}
+
+	main()
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+
+	cfg, reloader, err := newTLSConfig(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if reloader == nil || cfg.GetCertificate == nil || cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("Unexpected TLS configuration without client CA: %+v", cfg)
+	}
+
+	cfg, _, err = newTLSConfig(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.ClientAuth != tls.VerifyClientCertIfGiven || cfg.ClientCAs == nil {
+		t.Errorf("Expected optional verified client certificates, got %v", cfg.ClientAuth)
+	}
+
+	for _, args := range [][3]string{
+		{certFile, "", ""},
+		{"", "", certFile},
+		{filepath.Join(dir, "missing.pem"), keyFile, ""},
+		{certFile, keyFile, keyFile},
+	} {
+		if _, _, err := newTLSConfig(args[0], args[1], args[2]); err == nil {
+			t.Errorf("Expected an error for %q", args)
+		}
+	}
+}
+
+func TestUnprotectedWriteListeners(t *testing.T) {
+	defer func() { coapPort, lineUDPPort, lineTCPPort, grpcPort = 0, 0, 0, 0 }()
+
+	coapPort, lineUDPPort, lineTCPPort, grpcPort = 0, 0, 0, 0
+	if listeners := unprotectedWriteListeners(); len(listeners) != 0 {
+		t.Errorf("Expected no listeners, got %v", listeners)
+	}
+
+	coapPort, grpcPort = 5683, 9090
+	listeners := unprotectedWriteListeners()
+	if strings.Join(listeners, ",") != "-coap-port,-grpc-port" {
+		t.Errorf("Expected -coap-port and -grpc-port, got %v", listeners)
+	}
+}
+
 func TestParseTrustedProxies(t *testing.T) {
 	tests := []struct {
 		name string
@@ -3,6 +3,7 @@ package mcphandler
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -73,6 +74,16 @@ func (m *MCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Method not allowed. Use POST for MCP requests or GET for server information.", http.StatusMethodNotAllowed)
 }
 
+// Close closes all MCP sessions, ending their open streams so that an HTTP
+// server shutdown does not wait for idle clients.
+func (m *MCPServer) Close() {
+	// Sessions remove themselves from the server when closed, so collect
+	// them before closing.
+	for _, session := range slices.Collect(m.server.Sessions()) {
+		session.Close()
+	}
+}
+
 // handleInfoRequest returns server information for GET requests
 func (m *MCPServer) handleInfoRequest(w http.ResponseWriter, r *http.Request) {
 	// Use provided version or default
@@ -1,14 +1,18 @@
 package mcphandler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
 	"github.com/dhcgn/iot-ephemeral-value-store/storage"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func newServerTestConfig(version string) Config {
@@ -161,3 +165,37 @@ func Test_handleInfoRequest_registeredTools(t *testing.T) {
 		t.Errorf("expected %d tools, got %d", len(RegisteredToolNames), len(available))
 	}
 }
+
+func Test_Close(t *testing.T) {
+	srv, err := NewMCPServer(newServerTestConfig("1.0.0"))
+	if err != nil {
+		t.Fatalf("failed to create MCP server: %v", err)
+	}
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := srv.server.Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatalf("failed to connect server: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("failed to connect client: %v", err)
+	}
+
+	srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		clientSession.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the client session to end after Close")
+	}
+	if n := len(slices.Collect(srv.server.Sessions())); n != 0 {
+		t.Errorf("expected no sessions after Close, got %d", n)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"time"
 
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
 	"github.com/dhcgn/iot-ephemeral-value-store/domain"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -33,7 +35,15 @@ var toolRegistry = []ToolDescriptor{
 	},
 	{
 		Name:        "download_data",
-		Description: "Download data from the IoT ephemeral value store using a download key. You can retrieve all data as a JSON object, or specify a parameter path (e.g., 'temp' or 'living_room/temp') to get a specific value. The download key is read-only and cannot be used to modify data. Supports nested parameter paths using '/' separator.",
+		Description: "Download data from the IoT ephemeral value store using a download key. You can retrieve all data as a JSON object, or specify a parameter path (e.g., 'temp' or 'living_room/temp') to get a specific value. The response includes the expiry time (expires_at) and remaining TTL in seconds (ttl_seconds). The download key is read-only and cannot be used to modify data. Supports nested parameter paths using '/' separator.",
+	},
+	{
+		Name:        "download_many",
+		Description: "Download data for several download keys in one call, e.g. to refresh a dashboard. Each entry names a download key and optionally a list of parameter paths (e.g. 'temp' or 'living_room/temp'); without paths the whole JSON object is returned. The result is keyed by download key. Keys that cannot be read are reported with an error in their entry without failing the other entries.",
+	},
+	{
+		Name:        "touch_data",
+		Description: "Renew the retention period (TTL) of the data associated with an upload key without changing the stored values. Use this to keep rarely updated data from expiring. Returns the new expiry time. Requires the upload key (not the download key).",
 	},
 	{
 		Name:        "delete_data",
@@ -66,6 +76,28 @@ func toolResult(data map[string]interface{}) (*mcp.CallToolResult, error) {
 	}, nil
 }
 
+// problemError reports a failed tool call as a problem details body. The
+// SDK returns its message as the text content of a result with isError set.
+type problemError struct {
+	problem data.Problem
+	err     error
+}
+
+func (e *problemError) Error() string {
+	b, err := json.Marshal(e.problem)
+	if err != nil {
+		return e.err.Error()
+	}
+	return string(b)
+}
+
+func (e *problemError) Unwrap() error { return e.err }
+
+// toolError wraps err as a problem error, see data.ProblemFor.
+func toolError(err error, detail string) error {
+	return &problemError{problem: data.ProblemFor(err, detail), err: err}
+}
+
 // GenerateKeyPairInput represents the input for generating a key pair.
 // The noop field exists to satisfy schema generators that disallow empty objects.
 type GenerateKeyPairInput struct {
@@ -91,6 +123,16 @@ type DownloadDataInput struct {
 	Parameter   string `json:"parameter,omitempty" jsonschema:"Optional parameter path to retrieve (e.g. 'temp' or 'room1/temp'). If not provided returns all data as JSON"`
 }
 
+// DownloadManyInput represents the input for downloading several keys at once
+type DownloadManyInput struct {
+	Keys []data.DownloadRequest `json:"keys" jsonschema:"The download keys to read, each with optional parameter paths"`
+}
+
+// TouchDataInput represents the input for renewing the TTL of data
+type TouchDataInput struct {
+	UploadKey string `json:"upload_key" jsonschema:"The upload key for the data whose TTL should be renewed"`
+}
+
 // DeleteDataInput represents the input for deleting data
 type DeleteDataInput struct {
 	UploadKey string `json:"upload_key" jsonschema:"The upload key for the data to delete"`
@@ -106,7 +148,7 @@ func (c Config) GenerateKeyPairHandler(ctx context.Context, req *mcp.CallToolReq
 	if err != nil {
 		slog.Error("mcp generate_key_pair: failed", "error", err)
 		c.StatsInstance.IncrementHTTPErrors()
-		return nil, nil, err
+		return nil, nil, toolError(err, "Error generating key pair")
 	}
 
 	result, err := toolResult(map[string]interface{}{
@@ -126,11 +168,11 @@ func (c Config) UploadDataHandler(ctx context.Context, req *mcp.CallToolRequest,
 		return nil, nil, ctx.Err()
 	}
 
-	downloadKey, _, err := c.DataService.Upload(ctx, params.UploadKey, params.Parameters)
+	downloadKey, _, err := c.DataService.Upload(data.WithSource(ctx, data.SourceMCP), params.UploadKey, params.Parameters)
 	if err != nil {
 		slog.Error("mcp upload_data: failed", "error", err)
 		c.StatsInstance.IncrementHTTPErrors()
-		return nil, nil, err
+		return nil, nil, toolError(err, "Error saving data")
 	}
 	c.StatsInstance.IncrementUploads()
 
@@ -151,11 +193,11 @@ func (c Config) PatchDataHandler(ctx context.Context, req *mcp.CallToolRequest,
 		return nil, nil, ctx.Err()
 	}
 
-	downloadKey, _, err := c.DataService.Patch(ctx, params.UploadKey, params.Path, params.Parameters)
+	downloadKey, _, err := c.DataService.Patch(data.WithSource(ctx, data.SourceMCP), params.UploadKey, params.Path, params.Parameters)
 	if err != nil {
 		slog.Error("mcp patch_data: failed", "error", err, "path", params.Path)
 		c.StatsInstance.IncrementHTTPErrors()
-		return nil, nil, err
+		return nil, nil, toolError(err, "Error saving data")
 	}
 	c.StatsInstance.IncrementUploads()
 
@@ -177,39 +219,35 @@ func (c Config) DownloadDataHandler(ctx context.Context, req *mcp.CallToolReques
 		return nil, nil, ctx.Err()
 	}
 
-	var resultMap map[string]interface{}
-
-	if params.Parameter == "" {
-		jsonData, err := c.DataService.DownloadJSON(ctx, params.DownloadKey)
-		if err != nil {
-			slog.Error("mcp download_data: failed to retrieve data", "error", err)
-			c.StatsInstance.IncrementHTTPErrors()
-			return nil, nil, err
-		}
+	jsonData, expiresAt, err := c.DataService.DownloadJSONWithExpiry(ctx, params.DownloadKey)
+	if err != nil {
+		slog.Error("mcp download_data: failed to retrieve data", "error", err)
+		c.StatsInstance.IncrementHTTPErrors()
+		return nil, nil, toolError(err, "Invalid download key or data not found")
+	}
 
-		var dataMap map[string]interface{}
-		if err := json.Unmarshal(jsonData, &dataMap); err != nil {
-			slog.Error("mcp download_data: failed to decode JSON", "error", err)
-			c.StatsInstance.IncrementHTTPErrors()
-			return nil, nil, fmt.Errorf("error decoding JSON: %w", err)
-		}
+	var dataMap map[string]interface{}
+	if err := json.Unmarshal(jsonData, &dataMap); err != nil {
+		slog.Error("mcp download_data: failed to decode JSON", "error", err)
+		c.StatsInstance.IncrementHTTPErrors()
+		return nil, nil, toolError(err, "Error decoding JSON")
+	}
 
-		c.StatsInstance.IncrementDownloads()
+	var resultMap map[string]interface{}
 
+	if params.Parameter == "" {
 		resultMap = map[string]interface{}{
 			"data":    dataMap,
 			"message": "Retrieved all data as JSON",
 		}
 	} else {
-		value, err := c.DataService.DownloadField(ctx, params.DownloadKey, params.Parameter)
+		value, err := data.TraverseField(dataMap, params.Parameter)
 		if err != nil {
 			slog.Error("mcp download_data: failed to retrieve field", "error", err, "parameter", params.Parameter)
 			c.StatsInstance.IncrementHTTPErrors()
-			return nil, nil, err
+			return nil, nil, toolError(err, "Parameter not found")
 		}
 
-		c.StatsInstance.IncrementDownloads()
-
 		resultMap = map[string]interface{}{
 			"data":      value,
 			"parameter": params.Parameter,
@@ -217,6 +255,13 @@ func (c Config) DownloadDataHandler(ctx context.Context, req *mcp.CallToolReques
 		}
 	}
 
+	c.StatsInstance.IncrementDownloads()
+
+	if !expiresAt.IsZero() {
+		resultMap["expires_at"] = expiresAt.Format(time.RFC3339)
+		resultMap["ttl_seconds"] = data.RemainingTTLSeconds(expiresAt, time.Now())
+	}
+
 	result, err := toolResult(resultMap)
 	if err != nil {
 		return nil, nil, err
@@ -224,6 +269,63 @@ func (c Config) DownloadDataHandler(ctx context.Context, req *mcp.CallToolReques
 	return result, nil, nil
 }
 
+// DownloadManyHandler handles retrieval of several download keys
+func (c Config) DownloadManyHandler(ctx context.Context, req *mcp.CallToolRequest, params *DownloadManyInput) (*mcp.CallToolResult, any, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	results, err := c.DataService.DownloadMany(ctx, params.Keys)
+	if err != nil {
+		slog.Error("mcp download_many: failed", "error", err)
+		c.StatsInstance.IncrementHTTPErrors()
+		return nil, nil, toolError(err, "Error reading data")
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			continue
+		}
+		c.StatsInstance.IncrementDownloads()
+	}
+
+	result, err := toolResult(map[string]interface{}{
+		"results": results,
+		"message": fmt.Sprintf("Retrieved %d of %d keys", len(results)-failed, len(results)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+// TouchDataHandler handles renewing the TTL of stored data
+func (c Config) TouchDataHandler(ctx context.Context, req *mcp.CallToolRequest, params *TouchDataInput) (*mcp.CallToolResult, any, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	downloadKey, expiresAt, err := c.DataService.Touch(ctx, params.UploadKey)
+	if err != nil {
+		slog.Error("mcp touch_data: failed", "error", err)
+		c.StatsInstance.IncrementHTTPErrors()
+		return nil, nil, toolError(err, "No data stored for this upload key")
+	}
+
+	result, err := toolResult(map[string]interface{}{
+		"message":      "TTL extended successfully",
+		"download_key": domain.AddDownloadPrefix(downloadKey),
+		"expires_at":   expiresAt.Format(time.RFC3339),
+		"ttl_seconds":  data.RemainingTTLSeconds(expiresAt, time.Now()),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
 // DeleteDataHandler handles data deletion
 func (c Config) DeleteDataHandler(ctx context.Context, req *mcp.CallToolRequest, params *DeleteDataInput) (*mcp.CallToolResult, any, error) {
 	if ctx.Err() != nil {
@@ -234,7 +336,7 @@ func (c Config) DeleteDataHandler(ctx context.Context, req *mcp.CallToolRequest,
 	if err != nil {
 		slog.Error("mcp delete_data: failed", "error", err)
 		c.StatsInstance.IncrementHTTPErrors()
-		return nil, nil, err
+		return nil, nil, toolError(err, "Error deleting data")
 	}
 
 	result, err := toolResult(map[string]interface{}{
@@ -277,6 +379,20 @@ func (c Config) RegisterTools(server *mcp.Server) {
 		Description: tool.Description,
 	}, c.DownloadDataHandler)
 
+	// Tool: download_many
+	tool = getToolByName("download_many")
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        tool.Name,
+		Description: tool.Description,
+	}, c.DownloadManyHandler)
+
+	// Tool: touch_data
+	tool = getToolByName("touch_data")
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        tool.Name,
+		Description: tool.Description,
+	}, c.TouchDataHandler)
+
 	// Tool: delete_data
 	tool = getToolByName("delete_data")
 	mcp.AddTool(server, &mcp.Tool{
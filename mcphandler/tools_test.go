@@ -143,6 +143,11 @@ func TestUploadDataHandler(t *testing.T) {
 	if dataMap["timestamp"] == nil {
 		t.Error("Expected timestamp to be set")
 	}
+
+	// Per-field timestamps are a data.Service policy and apply to MCP writes too
+	if dataMap["temp_timestamp"] == nil {
+		t.Error("Expected per-field timestamp temp_timestamp to be set")
+	}
 }
 
 func TestPatchDataHandler(t *testing.T) {
@@ -280,6 +285,9 @@ func TestDownloadDataHandler(t *testing.T) {
 		if responseData["data"] == nil {
 			t.Error("Expected data field in response")
 		}
+		if responseData["expires_at"] == nil || responseData["ttl_seconds"] == nil {
+			t.Error("Expected expires_at and ttl_seconds in response")
+		}
 	})
 
 	t.Run("Download specific parameter", func(t *testing.T) {
@@ -367,6 +375,140 @@ func TestDeleteDataHandler(t *testing.T) {
 	}
 }
 
+func TestDownloadManyHandler(t *testing.T) {
+	config, si := newTestConfig()
+	ctx := context.Background()
+
+	si.Store(ctx, "aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11", map[string]interface{}{"temp": "21"})
+	si.Store(ctx, "bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22", map[string]interface{}{"room": map[string]interface{}{"temp": "19"}})
+
+	result, _, err := config.DownloadManyHandler(ctx, &mcp.CallToolRequest{}, &DownloadManyInput{
+		Keys: []data.DownloadRequest{
+			{DownloadKey: "aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11"},
+			{DownloadKey: "bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22", Paths: []string{"room/temp"}},
+			{DownloadKey: "missing"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	textContent, ok := result.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatal("Expected TextContent")
+	}
+	var response struct {
+		Results map[string]data.DownloadResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(textContent.Text), &response); err != nil {
+		t.Fatalf("Failed to parse result JSON: %v", err)
+	}
+	if response.Results["aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11"].Data["temp"] != "21" {
+		t.Errorf("Unexpected result for aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11: %+v", response.Results["aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11aa11"])
+	}
+	if response.Results["bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22"].Values["room/temp"] != "19" {
+		t.Errorf("Unexpected result for bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22: %+v", response.Results["bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22bb22"])
+	}
+	if response.Results["missing"].Error == "" {
+		t.Error("Expected error for missing key")
+	}
+
+	if _, _, err := config.DownloadManyHandler(ctx, &mcp.CallToolRequest{}, &DownloadManyInput{}); err == nil {
+		t.Error("Expected error for empty batch")
+	}
+}
+
+func TestTouchDataHandler(t *testing.T) {
+	config, si := newTestConfig()
+	ctx := context.Background()
+	req := &mcp.CallToolRequest{}
+
+	uploadKey := domain.GenerateRandomKey()
+	downloadKey, _ := domain.DeriveDownloadKey(uploadKey)
+
+	t.Run("Touch without data", func(t *testing.T) {
+		_, _, err := config.TouchDataHandler(ctx, req, &TouchDataInput{UploadKey: uploadKey})
+		if err == nil {
+			t.Error("Expected error when no data is stored")
+		}
+	})
+
+	t.Run("Touch existing data", func(t *testing.T) {
+		si.Store(ctx, downloadKey, map[string]interface{}{"temp": "23.5"})
+
+		result, _, err := config.TouchDataHandler(ctx, req, &TouchDataInput{UploadKey: uploadKey})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		textContent := result.Content[0].(*mcp.TextContent)
+		var responseData map[string]interface{}
+		if err := json.Unmarshal([]byte(textContent.Text), &responseData); err != nil {
+			t.Fatalf("Expected valid JSON response, got error: %v", err)
+		}
+		if responseData["expires_at"] == nil || responseData["ttl_seconds"] == nil {
+			t.Errorf("Expected expires_at and ttl_seconds in response, got %v", responseData)
+		}
+	})
+}
+
+func TestToolErrorProblems(t *testing.T) {
+	config, _ := newTestConfig()
+	ctx := context.Background()
+	req := &mcp.CallToolRequest{}
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantCode   string
+		wantStatus int
+	}{
+		{
+			name: "unknown download key",
+			call: func() error {
+				_, _, err := config.DownloadDataHandler(ctx, req, &DownloadDataInput{DownloadKey: domain.GenerateRandomKey()})
+				return err
+			},
+			wantCode:   data.CodeNotFound,
+			wantStatus: 404,
+		},
+		{
+			name: "invalid upload key",
+			call: func() error {
+				_, _, err := config.UploadDataHandler(ctx, req, &UploadDataInput{UploadKey: "invalid"})
+				return err
+			},
+			wantCode:   data.CodeInvalidKey,
+			wantStatus: 400,
+		},
+		{
+			name: "empty batch",
+			call: func() error {
+				_, _, err := config.DownloadManyHandler(ctx, req, &DownloadManyInput{})
+				return err
+			},
+			wantCode:   data.CodeValidation,
+			wantStatus: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			var p data.Problem
+			if jsonErr := json.Unmarshal([]byte(err.Error()), &p); jsonErr != nil {
+				t.Fatalf("Expected problem details JSON, got %q", err.Error())
+			}
+			if p.Code != tt.wantCode || p.Status != tt.wantStatus {
+				t.Errorf("problem = %+v, want code %q and status %d", p, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
 func TestInvalidUploadKey(t *testing.T) {
 	config, _ := newTestConfig()
 
@@ -464,6 +606,18 @@ func TestAllCapabilitiesImplemented(t *testing.T) {
 					Parameter:   "",
 				})
 				testCalled = true // Tool exists even if it fails
+			case "download_many":
+				// This will report a per-key error but proves the tool exists
+				_, _, _ = config.DownloadManyHandler(ctx, &mcp.CallToolRequest{}, &DownloadManyInput{
+					Keys: []data.DownloadRequest{{DownloadKey: "invalid"}},
+				})
+				testCalled = true // Tool exists even if it fails
+			case "touch_data":
+				// This will fail validation but proves the tool exists
+				_, _, _ = config.TouchDataHandler(ctx, &mcp.CallToolRequest{}, &TouchDataInput{
+					UploadKey: "invalid",
+				})
+				testCalled = true // Tool exists even if validation fails
 			case "delete_data":
 				// This will fail validation but proves the tool exists
 				_, _, _ = config.DeleteDataHandler(ctx, &mcp.CallToolRequest{}, &DeleteDataInput{
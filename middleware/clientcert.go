@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
+)
+
+// RequireClientCert wraps the handler of a route that changes data and
+// rejects requests unless the client presented a certificate verified
+// against the client CA of the TLS listener. Routes are tagged as writes
+// where they are registered; downloads are not wrapped and stay open to
+// every client. It does nothing unless RequireClientCertForWrites is set.
+func (c Config) RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.RequireClientCertForWrites || hasVerifiedClientCert(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		slog.Error("middleware: write without client certificate", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		c.StatsInstance.IncrementHTTPErrors()
+		data.WriteProblem(w, r, data.NewProblem(data.CodeClientCertRequired, "Writes require a TLS client certificate signed by the configured client CA"))
+	})
+}
+
+// hasVerifiedClientCert reports whether the TLS handshake of r verified a
+// client certificate chain.
+func hasVerifiedClientCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
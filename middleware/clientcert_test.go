@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/stats"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	unverified := &tls.ConnectionState{}
+
+	tests := []struct {
+		name           string
+		required       bool
+		write          bool
+		tls            *tls.ConnectionState
+		expectedStatus int
+	}{
+		{"write without certificate", true, true, unverified, http.StatusForbidden},
+		{"write without TLS", true, true, nil, http.StatusForbidden},
+		{"write with certificate", true, true, verified, http.StatusOK},
+		{"read without certificate", true, false, unverified, http.StatusOK},
+		{"write when not required", false, true, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{RequireClientCertForWrites: tt.required, StatsInstance: stats.NewStats()}
+
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+			if tt.write {
+				handler = c.RequireClientCert(handler)
+			}
+
+			req := httptest.NewRequest("POST", "/v1/u/abc", nil)
+			req.TLS = tt.tls
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("RequireClientCert returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus == http.StatusForbidden && rr.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("Expected a problem response, got %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
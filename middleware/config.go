@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net"
+	"time"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
 )
@@ -18,4 +19,13 @@ type Config struct {
 	// empty, proxy headers are ignored and r.RemoteAddr is used directly.
 	// Set this to the CIDR of your reverse proxy (e.g. Traefik) Docker network.
 	TrustedProxies []*net.IPNet
+
+	// LegacyDeprecatedAt and LegacySunset are sent by Deprecated on the
+	// unversioned legacy routes. Zero values omit the header.
+	LegacyDeprecatedAt time.Time
+	LegacySunset       time.Time
+
+	// RequireClientCertForWrites makes RequireClientCert reject writes
+	// without a TLS client certificate verified against the client CA.
+	RequireClientCertForWrites bool
 }
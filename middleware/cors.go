@@ -8,7 +8,7 @@ func (Config) EnableCORS(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
 		// Specify methods that you want to allow
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 
 		// Specify headers that you want to allow
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -75,7 +75,7 @@ func TestEnableCORS(t *testing.T) {
 				headers := rr.Header()
 				expectedHeaders := map[string]string{
 					"Access-Control-Allow-Origin":  "*",
-					"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
+					"Access-Control-Allow-Methods": "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 					"Access-Control-Allow-Headers": "Content-Type, Authorization",
 				}
 
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// deprecationLogInterval is the minimum time between two warnings about
+	// the same client using the legacy routes.
+	deprecationLogInterval = time.Hour
+	// maxDeprecationLogClients bounds the clients remembered for
+	// deprecationLogInterval. When it is reached, all are forgotten.
+	maxDeprecationLogClients = 4096
+)
+
+var deprecationLogged = make(map[string]time.Time)
+var deprecationMtx sync.Mutex
+
+// Deprecated marks responses of the unversioned legacy routes as deprecated
+// (RFC 9745) with a link to the same path in the /v1 route tree. If
+// LegacySunset is set, the Sunset header (RFC 8594) announces when the
+// legacy routes will be removed. Each client is logged as a warning at most
+// once per hour, so device owners can find the devices to update.
+func (c Config) Deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.LegacyDeprecatedAt.IsZero() {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", c.LegacyDeprecatedAt.Unix()))
+		}
+		if !c.LegacySunset.IsZero() {
+			w.Header().Set("Sunset", c.LegacySunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Add("Link", fmt.Sprintf(`</v1%s>; rel="successor-version"`, r.URL.EscapedPath()))
+		c.logDeprecatedUse(r, time.Now())
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logDeprecatedUse warns that the client of r uses a legacy route, unless it
+// was already warned about within deprecationLogInterval.
+func (c Config) logDeprecatedUse(r *http.Request, now time.Time) {
+	ip := realIP(r, c.TrustedProxies)
+
+	deprecationMtx.Lock()
+	last, seen := deprecationLogged[ip]
+	if seen && now.Sub(last) < deprecationLogInterval {
+		deprecationMtx.Unlock()
+		return
+	}
+	if !seen && len(deprecationLogged) >= maxDeprecationLogClients {
+		clear(deprecationLogged)
+	}
+	deprecationLogged[ip] = now
+	deprecationMtx.Unlock()
+
+	slog.Warn("middleware: deprecated legacy route used, switch to /v1", "method", r.Method, "path", r.URL.Path, "remote_addr", ip)
+}
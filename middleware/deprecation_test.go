@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	deprecatedAt := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		config              Config
+		path                string
+		expectedDeprecation string
+		expectedSunset      string
+		expectedLink        string
+	}{
+		{
+			name:                "deprecation and sunset",
+			config:              Config{LegacyDeprecatedAt: deprecatedAt, LegacySunset: sunset},
+			path:                "/d/abc/plain/room%2F1",
+			expectedDeprecation: "@1792281600",
+			expectedSunset:      "Wed, 30 Jun 2027 00:00:00 GMT",
+			expectedLink:        `</v1/d/abc/plain/room%2F1>; rel="successor-version"`,
+		},
+		{
+			name:                "no sunset configured",
+			config:              Config{LegacyDeprecatedAt: deprecatedAt},
+			path:                "/u/abc",
+			expectedDeprecation: "@1792281600",
+			expectedLink:        `</v1/u/abc>; rel="successor-version"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			tt.config.Deprecated(handler).ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Deprecation"); got != tt.expectedDeprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.expectedDeprecation)
+			}
+			if got := rr.Header().Get("Sunset"); got != tt.expectedSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.expectedSunset)
+			}
+			if got := rr.Header().Get("Link"); got != tt.expectedLink {
+				t.Errorf("Link = %q, want %q", got, tt.expectedLink)
+			}
+		})
+	}
+}
+
+func TestDeprecatedLogsRateLimited(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	deprecationMtx.Lock()
+	clear(deprecationLogged)
+	deprecationMtx.Unlock()
+
+	c := Config{}
+	now := time.Now()
+	request := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest("GET", "/u/abc", nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	c.logDeprecatedUse(request("198.51.100.1:1234"), now)
+	c.logDeprecatedUse(request("198.51.100.1:1234"), now.Add(time.Minute))
+	c.logDeprecatedUse(request("198.51.100.2:1234"), now.Add(time.Minute))
+	c.logDeprecatedUse(request("198.51.100.1:1234"), now.Add(deprecationLogInterval+time.Minute))
+
+	if got := strings.Count(buf.String(), "deprecated legacy route used"); got != 3 {
+		t.Errorf("Expected 3 warnings, got %d:\n%s", got, buf.String())
+	}
+	if !strings.Contains(buf.String(), "path=/u/abc") || !strings.Contains(buf.String(), "remote_addr=198.51.100.2") {
+		t.Errorf("Expected path and remote address in the warning, got %s", buf.String())
+	}
+}
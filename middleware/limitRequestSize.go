@@ -3,6 +3,8 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/data"
 )
 
 func (c Config) LimitRequestSize(next http.Handler) http.Handler {
@@ -11,10 +13,16 @@ func (c Config) LimitRequestSize(next http.Handler) http.Handler {
 		if r.ContentLength > c.MaxRequestSize {
 			slog.Error("middleware: request too large", "content_length", r.ContentLength, "max", c.MaxRequestSize, "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
 			c.StatsInstance.IncrementHTTPErrors()
-			http.Error(w, "Request size is too large", http.StatusRequestEntityTooLarge)
+			data.WriteProblem(w, r, data.NewProblem(data.CodeTooLarge, "Request size is too large"))
 			return
 		}
 
+		// Bodies without a declared length (chunked encoding) are capped
+		// while being read.
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, c.MaxRequestSize)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -2,8 +2,11 @@
 package middleware
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/dhcgn/iot-ephemeral-value-store/stats"
@@ -62,6 +65,9 @@ func TestLimitRequestSize(t *testing.T) {
 			}
 
 			if tt.expectedStatus == http.StatusRequestEntityTooLarge {
+				if !strings.Contains(rr.Body.String(), `"code":"too_large"`) {
+					t.Errorf("handler returned unexpected body: %q", rr.Body.String())
+				}
 				if mockStats.GetCurrentStats().HTTPErrorCount != 1 {
 					t.Errorf("IncrementHTTPErrors was not called")
 				}
@@ -73,3 +79,24 @@ func TestLimitRequestSize(t *testing.T) {
 		})
 	}
 }
+
+func TestLimitRequestSize_UnknownLength(t *testing.T) {
+	config := Config{
+		MaxRequestSize: 10,
+		StatsInstance:  stats.NewStats(),
+	}
+
+	var readErr error
+	handler := config.LimitRequestSize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 100)))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("expected body read to fail with MaxBytesError, got %v", readErr)
+	}
+}
@@ -69,14 +69,7 @@ func (c Config) RateLimit(next http.Handler) http.Handler {
 			return
 		}
 
-		// Exclude local IP addresses from rate limiting
-		if ip == "127.0.0.1" || ip == "::1" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		limiter := c.getLimiter(ip)
-		if !limiter.Allow() {
+		if !c.Allow(ip) {
 			slog.Error("middleware: rate limit exceeded", "remote_addr", ip, "method", r.Method, "path", r.URL.Path)
 			c.StatsInstance.IncrementHTTPErrors()
 			c.StatsInstance.RecordRateLimitHit(ip)
@@ -88,6 +81,17 @@ func (c Config) RateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// Allow reports whether another request from ip is within the rate limit. It
+// shares the per-IP buckets of RateLimit, so other frontends such as the line
+// protocol listeners are limited together with HTTP. Local addresses are
+// always allowed.
+func (c Config) Allow(ip string) bool {
+	if ip == "127.0.0.1" || ip == "::1" {
+		return true
+	}
+	return c.getLimiter(ip).Allow()
+}
+
 func (c Config) getLimiter(ip string) *rate.Limiter {
 	mtx.Lock()
 	defer mtx.Unlock()
@@ -204,3 +204,31 @@ func TestRateLimit_BehindReverseProxy(t *testing.T) {
 		t.Error("client B should not be rate limited when only client A has exceeded the limit")
 	}
 }
+
+func TestAllow(t *testing.T) {
+	config := Config{RateLimitPerSecond: 1, RateLimitBurst: 2}
+
+	tests := []struct {
+		name    string
+		ip      string
+		allowed int
+	}{
+		{name: "remote IP is limited to the burst", ip: "198.51.100.77", allowed: 2},
+		{name: "IPv4 loopback is not limited", ip: "127.0.0.1", allowed: 5},
+		{name: "IPv6 loopback is not limited", ip: "::1", allowed: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := 0
+			for i := 0; i < 5; i++ {
+				if config.Allow(tt.ip) {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("Allow() permitted %d of 5 requests, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
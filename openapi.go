@@ -0,0 +1,192 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dhcgn/iot-ephemeral-value-store/httphandler"
+	"github.com/gorilla/mux"
+)
+
+// The OpenAPI document is generated from the registered routes.
+// openapi/base.json holds the document without the data API and the
+// operations of the routes outside the route trees. openapi/tree.json holds
+// the operations of one data API route tree, with paths relative to the tree
+// and $TREE standing for its prefix, plus per-tree overrides. Routes of the
+// unversioned legacy tree are documented as deprecated.
+//
+//go:embed openapi/*.json
+var openAPIFiles embed.FS
+
+type openAPIOperations map[string]map[string]map[string]interface{}
+
+type openAPITree struct {
+	Paths     openAPIOperations `json:"paths"`
+	Overrides map[string]struct {
+		Paths openAPIOperations `json:"paths"`
+	} `json:"overrides"`
+}
+
+var openAPIParamPattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// openAPIPath converts a mux path template to the path of the OpenAPI
+// document. Trailing-slash aliases share the entry of the route without it.
+func openAPIPath(tpl string) string {
+	tpl = openAPIParamPattern.ReplaceAllString(tpl, "{$1}")
+	if len(tpl) > 1 {
+		tpl = strings.TrimSuffix(tpl, "/")
+	}
+	return tpl
+}
+
+// buildOpenAPISpec returns the OpenAPI document of the routes registered on
+// r, so only the methods of the current configuration are documented, e.g.
+// no GET writes without -legacy-get-writes. Routes without a method
+// restriction are documented as GET. It fails for a route without
+// documentation.
+func buildOpenAPISpec(r *mux.Router) ([]byte, error) {
+	baseJSON, err := openAPIFiles.ReadFile("openapi/base.json")
+	if err != nil {
+		return nil, err
+	}
+	var spec map[string]interface{}
+	var base struct {
+		Paths openAPIOperations `json:"paths"`
+	}
+	if err := json.Unmarshal(baseJSON, &spec); err != nil {
+		return nil, fmt.Errorf("openapi/base.json: %w", err)
+	}
+	if err := json.Unmarshal(baseJSON, &base); err != nil {
+		return nil, fmt.Errorf("openapi/base.json: %w", err)
+	}
+	treeJSON, err := openAPIFiles.ReadFile("openapi/tree.json")
+	if err != nil {
+		return nil, err
+	}
+	trees := make(map[string]*openAPITree)
+
+	paths := make(map[string]map[string]interface{})
+	err = r.Walk(func(route *mux.Route, _ *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil {
+			// Subrouter mount points are covered by their routes.
+			return nil
+		}
+		if _, ok := route.GetHandler().(httphandler.MethodNotAllowed); ok {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+		path := openAPIPath(tpl)
+
+		operations := base.Paths[path]
+		prefix, inTree := routeTreePrefix(ancestors)
+		if inTree {
+			tree, ok := trees[prefix]
+			if !ok {
+				tree = &openAPITree{}
+				expanded := strings.ReplaceAll(string(treeJSON), "$TREE", prefix)
+				if err := json.Unmarshal([]byte(expanded), tree); err != nil {
+					return fmt.Errorf("openapi/tree.json: %w", err)
+				}
+				trees[prefix] = tree
+			}
+			relative := strings.TrimPrefix(path, prefix)
+			operations = tree.Overrides[prefix].Paths[relative]
+			if operations == nil {
+				operations = tree.Paths[relative]
+			}
+		}
+
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			op, ok := operations[method]
+			if !ok {
+				return fmt.Errorf("route %s %s is not documented in openapi/", strings.ToUpper(method), path)
+			}
+			if inTree {
+				op = treeOperation(op, prefix, path)
+			}
+			if paths[path] == nil {
+				paths[path] = make(map[string]interface{})
+			}
+			paths[path][method] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	spec["paths"] = paths
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// routeTreePrefix returns the path prefix of the data API route tree a
+// route is registered in. The legacy tree has no prefix.
+func routeTreePrefix(ancestors []*mux.Route) (string, bool) {
+	if len(ancestors) == 0 {
+		return "", false
+	}
+	prefix, err := ancestors[len(ancestors)-1].GetPathTemplate()
+	if err != nil {
+		return "", true
+	}
+	return prefix, true
+}
+
+// treeOperation adapts an operation of openapi/tree.json to the tree with
+// prefix. Operation IDs get the version as suffix; operations of the legacy
+// tree are marked deprecated and their responses announce the Deprecation,
+// Sunset and Link headers.
+func treeOperation(op map[string]interface{}, prefix, path string) map[string]interface{} {
+	adapted := make(map[string]interface{}, len(op)+1)
+	for k, v := range op {
+		adapted[k] = v
+	}
+	id, _ := op["operationId"].(string)
+	if prefix != "" {
+		adapted["operationId"] = id + strings.ToUpper(prefix[1:2]) + prefix[2:]
+		return adapted
+	}
+
+	adapted["deprecated"] = true
+	notice := fmt.Sprintf("Deprecated, use `/v1%s`.", path)
+	if description, _ := op["description"].(string); description != "" {
+		notice = description + " " + notice
+	}
+	adapted["description"] = notice
+
+	responses, _ := op["responses"].(map[string]interface{})
+	deprecatedResponses := make(map[string]interface{}, len(responses))
+	for code, response := range responses {
+		deprecatedResponses[code] = response
+		response, _ := response.(map[string]interface{})
+		if !strings.HasPrefix(code, "2") || response == nil || response["$ref"] != nil {
+			continue
+		}
+		withHeaders := make(map[string]interface{}, len(response)+1)
+		for k, v := range response {
+			withHeaders[k] = v
+		}
+		headers := make(map[string]interface{})
+		if existing, ok := response["headers"].(map[string]interface{}); ok {
+			for k, v := range existing {
+				headers[k] = v
+			}
+		}
+		for _, name := range []string{"Deprecation", "Sunset", "Link"} {
+			headers[name] = map[string]interface{}{"$ref": "#/components/headers/" + name}
+		}
+		withHeaders["headers"] = headers
+		deprecatedResponses[code] = withHeaders
+	}
+	adapted["responses"] = deprecatedResponses
+	return adapted
+}
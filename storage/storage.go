@@ -9,12 +9,17 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
+// ErrNotFound is returned when a key does not exist or has expired. It is
+// the error of the underlying Badger database.
+var ErrNotFound = badger.ErrKeyNotFound
+
 // ErrStorageTimeout indicates a storage operation exceeded its deadline.
 // The underlying write may still complete; callers must not assume the
 // operation failed.
@@ -64,6 +69,8 @@ type StorageInstance struct {
 	WriteTimeout    time.Duration
 	storePath       string
 	stopGC          chan struct{}
+	gcDone          chan struct{}
+	closeOnce       *sync.Once
 
 	// degraded is set to 1 after a write timeout to reject further writes
 	// and prevent goroutine accumulation from a hung BadgerDB.
@@ -76,9 +83,27 @@ type StorageInstance struct {
 // database operations.
 type Storage interface {
 	GetJSON(ctx context.Context, downloadKey string) ([]byte, error)
+	GetJSONWithExpiry(ctx context.Context, downloadKey string) ([]byte, time.Time, error)
 	Delete(ctx context.Context, downloadKey string) error
 	Store(ctx context.Context, downloadKey string, dataToStore map[string]interface{}) error
 	Retrieve(ctx context.Context, downloadKey string) (map[string]interface{}, error)
+	Touch(ctx context.Context, downloadKey string) (time.Time, error)
+	GetJSONMany(ctx context.Context, downloadKeys []string) ([]JSONEntry, error)
+	StoreMany(ctx context.Context, entries []StoreEntry, renew ...string) error
+}
+
+// StoreEntry is a single document written by StoreMany.
+type StoreEntry struct {
+	Key  string
+	Data map[string]interface{}
+}
+
+// JSONEntry is the result for a single key of GetJSONMany. Err is
+// badger.ErrKeyNotFound if the key does not exist.
+type JSONEntry struct {
+	Data      []byte
+	ExpiresAt time.Time
+	Err       error
 }
 
 // HealthChecker reports the health of the storage backend.
@@ -94,20 +119,28 @@ type HealthStatus struct {
 	DiskFreeBytes int64  `json:"disk_free_bytes,omitempty"`
 }
 
-// Close stops the periodic value log GC goroutine (if running) and closes the
-// underlying BadgerDB.
+// Close stops the periodic value log GC goroutine (if running), waits for a
+// running GC pass to finish and closes the underlying BadgerDB, which flushes
+// the memtables to disk. Calling Close again returns nil.
 func (c *StorageInstance) Close() error {
-	if c.stopGC != nil {
-		close(c.stopGC)
-	}
-	return c.Db.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		if c.stopGC != nil {
+			close(c.stopGC)
+			<-c.gcDone
+		}
+		err = c.Db.Close()
+	})
+	return err
 }
 
 // startValueLogGC starts a background goroutine that periodically runs
 // Badger's value log garbage collection. The goroutine stops when stopCh
-// is closed.
-func startValueLogGC(db *badger.DB, interval time.Duration, stopCh chan struct{}) {
+// is closed and closes the returned channel when it has returned.
+func startValueLogGC(db *badger.DB, interval time.Duration, stopCh chan struct{}) chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
@@ -123,6 +156,7 @@ func startValueLogGC(db *badger.DB, interval time.Duration, stopCh chan struct{}
 			}
 		}
 	}()
+	return done
 }
 
 func NewInMemoryStorage() StorageInstance {
@@ -135,6 +169,7 @@ func NewInMemoryStorage() StorageInstance {
 		Db:              db,
 		PersistDuration: 1 * time.Minute,
 		WriteTimeout:    defaultWriteTimeout,
+		closeOnce:       &sync.Once{},
 	}
 }
 
@@ -157,7 +192,7 @@ func NewPersistentStorage(storePath string, persistDuration time.Duration) Stora
 	}
 
 	stopCh := make(chan struct{})
-	startValueLogGC(db, 5*time.Minute, stopCh)
+	gcDone := startValueLogGC(db, 5*time.Minute, stopCh)
 
 	return StorageInstance{
 		Db:              db,
@@ -165,6 +200,8 @@ func NewPersistentStorage(storePath string, persistDuration time.Duration) Stora
 		WriteTimeout:    defaultWriteTimeout,
 		storePath:       absStorePath,
 		stopGC:          stopCh,
+		gcDone:          gcDone,
+		closeOnce:       &sync.Once{},
 	}
 }
 
@@ -264,6 +301,58 @@ func (c *StorageInstance) GetJSON(ctx context.Context, downloadKey string) ([]by
 	return jsonData, err
 }
 
+// GetJSONWithExpiry returns the raw JSON bytes for downloadKey together with
+// the time at which Badger will expire the entry. The returned time is zero
+// if the entry has no TTL.
+func (c *StorageInstance) GetJSONWithExpiry(ctx context.Context, downloadKey string) ([]byte, time.Time, error) {
+	var jsonData []byte
+	var expiresAt time.Time
+	err := c.viewWithContext(ctx, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(downloadKey))
+		if err != nil {
+			return err
+		}
+		if ts := item.ExpiresAt(); ts > 0 {
+			expiresAt = time.Unix(int64(ts), 0).UTC()
+		}
+		jsonData, err = item.ValueCopy(nil)
+		return err
+	})
+	return jsonData, expiresAt, err
+}
+
+// GetJSONMany reads the raw JSON bytes and expiry times of several keys in a
+// single read transaction, so all entries reflect the same snapshot. The
+// returned entries are in the order of downloadKeys; missing keys are
+// reported per entry. The error is only set if the transaction itself fails.
+func (c *StorageInstance) GetJSONMany(ctx context.Context, downloadKeys []string) ([]JSONEntry, error) {
+	entries := make([]JSONEntry, len(downloadKeys))
+	err := c.viewWithContext(ctx, func(txn *badger.Txn) error {
+		for i, key := range downloadKeys {
+			item, err := txn.Get([]byte(key))
+			if err != nil {
+				if err == badger.ErrKeyNotFound {
+					entries[i].Err = err
+					continue
+				}
+				return err
+			}
+			if ts := item.ExpiresAt(); ts > 0 {
+				entries[i].ExpiresAt = time.Unix(int64(ts), 0).UTC()
+			}
+			entries[i].Data, err = item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 func (c *StorageInstance) Store(ctx context.Context, downloadKey string, dataToStore map[string]interface{}) error {
 	updatedJSONData, err := json.Marshal(dataToStore)
 	if err != nil {
@@ -276,6 +365,46 @@ func (c *StorageInstance) Store(ctx context.Context, downloadKey string, dataToS
 	})
 }
 
+// StoreMany writes several documents in a single transaction, so either all
+// or none of them are stored. The TTL of the existing entries in renew is
+// extended in the same transaction; missing ones are skipped.
+func (c *StorageInstance) StoreMany(ctx context.Context, entries []StoreEntry, renew ...string) error {
+	encoded := make([][]byte, len(entries))
+	for i, entry := range entries {
+		jsonData, err := json.Marshal(entry.Data)
+		if err != nil {
+			return errors.New("error encoding data to JSON")
+		}
+		encoded[i] = jsonData
+	}
+
+	return c.updateWithContext(ctx, func(txn *badger.Txn) error {
+		for i, entry := range entries {
+			e := badger.NewEntry([]byte(entry.Key), encoded[i]).WithTTL(c.PersistDuration)
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		for _, key := range renew {
+			item, err := txn.Get([]byte(key))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := txn.SetEntry(badger.NewEntry([]byte(key), value).WithTTL(c.PersistDuration)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (c *StorageInstance) Retrieve(ctx context.Context, downloadKey string) (map[string]interface{}, error) {
 	var existingData map[string]interface{}
 	err := c.viewWithContext(ctx, func(txn *badger.Txn) error {
@@ -305,6 +434,27 @@ func (c *StorageInstance) Delete(ctx context.Context, downloadKey string) error
 	})
 }
 
+// Touch renews the TTL of an existing entry without changing its value and
+// returns the new expiry time. It returns badger.ErrKeyNotFound if the entry
+// does not exist.
+func (c *StorageInstance) Touch(ctx context.Context, downloadKey string) (time.Time, error) {
+	var expiresAt time.Time
+	err := c.updateWithContext(ctx, func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(downloadKey))
+		if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		e := badger.NewEntry([]byte(downloadKey), value).WithTTL(c.PersistDuration)
+		expiresAt = time.Unix(int64(e.ExpiresAt), 0).UTC()
+		return txn.SetEntry(e)
+	})
+	return expiresAt, err
+}
+
 func (c *StorageInstance) StoreRawForTesting(downloadKey string, data []byte) error {
 	return c.updateWithContext(context.Background(), func(txn *badger.Txn) error {
 		e := badger.NewEntry([]byte(downloadKey), data).WithTTL(c.PersistDuration)
@@ -9,6 +9,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/dgraph-io/badger/v4"
 )
 
 func TestInMemoryStorage(t *testing.T) {
@@ -160,6 +162,169 @@ func TestGetJSON(t *testing.T) {
 	})
 }
 
+func TestGetJSONWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	defer storage.Close()
+
+	before := time.Now().Add(storage.PersistDuration).Add(-time.Second)
+	if err := storage.Store(ctx, "expiry_key", map[string]interface{}{"field": "value"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+	after := time.Now().Add(storage.PersistDuration).Add(time.Second)
+
+	jsonData, expiresAt, err := storage.GetJSONWithExpiry(ctx, "expiry_key")
+	if err != nil {
+		t.Fatalf("Failed to get JSON data: %v", err)
+	}
+	if string(jsonData) != `{"field":"value"}` {
+		t.Errorf("Unexpected JSON %s", string(jsonData))
+	}
+	if expiresAt.Before(before) || expiresAt.After(after) {
+		t.Errorf("Expected expiry between %v and %v, got %v", before, after, expiresAt)
+	}
+
+	if _, _, err := storage.GetJSONWithExpiry(ctx, "missing_key"); err == nil {
+		t.Error("Expected error for missing key")
+	}
+}
+
+func TestGetJSONMany(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	defer storage.Close()
+
+	if err := storage.Store(ctx, "key_a", map[string]interface{}{"field": "a"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+	if err := storage.Store(ctx, "key_b", map[string]interface{}{"field": "b"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+
+	entries, err := storage.GetJSONMany(ctx, []string{"key_b", "missing_key", "key_a"})
+	if err != nil {
+		t.Fatalf("GetJSONMany failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if string(entries[0].Data) != `{"field":"b"}` || entries[0].Err != nil || entries[0].ExpiresAt.IsZero() {
+		t.Errorf("Unexpected entry for key_b: %+v", entries[0])
+	}
+	if !errors.Is(entries[1].Err, badger.ErrKeyNotFound) || entries[1].Data != nil {
+		t.Errorf("Expected ErrKeyNotFound for missing key, got %+v", entries[1])
+	}
+	if string(entries[2].Data) != `{"field":"a"}` || entries[2].Err != nil {
+		t.Errorf("Unexpected entry for key_a: %+v", entries[2])
+	}
+}
+
+func TestStoreMany(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	defer storage.Close()
+
+	err := storage.StoreMany(ctx, []StoreEntry{
+		{Key: "many_a", Data: map[string]interface{}{"field": "a"}},
+		{Key: "many_b", Data: map[string]interface{}{"field": "b"}},
+	})
+	if err != nil {
+		t.Fatalf("StoreMany failed: %v", err)
+	}
+
+	for key, want := range map[string]string{"many_a": `{"field":"a"}`, "many_b": `{"field":"b"}`} {
+		jsonData, expiresAt, err := storage.GetJSONWithExpiry(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		if string(jsonData) != want {
+			t.Errorf("Expected %s for %s, got %s", want, key, string(jsonData))
+		}
+		if expiresAt.IsZero() {
+			t.Errorf("Expected TTL for %s", key)
+		}
+	}
+
+	err = storage.StoreMany(ctx, []StoreEntry{
+		{Key: "many_c", Data: map[string]interface{}{"field": "c"}},
+		{Key: "many_d", Data: map[string]interface{}{"bad": make(chan int)}},
+	})
+	if err == nil {
+		t.Fatal("Expected error for unencodable data")
+	}
+	if _, err := storage.GetJSON(ctx, "many_c"); err == nil {
+		t.Error("Expected no entry to be stored when encoding fails")
+	}
+}
+
+func TestStoreManyRenew(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	defer storage.Close()
+
+	storage.PersistDuration = time.Minute
+	if err := storage.Store(ctx, "renew_key", map[string]interface{}{"field": "value"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+	_, before, _ := storage.GetJSONWithExpiry(ctx, "renew_key")
+
+	storage.PersistDuration = time.Hour
+	err := storage.StoreMany(ctx, []StoreEntry{{Key: "many_e", Data: map[string]interface{}{"field": "e"}}}, "renew_key", "missing_key")
+	if err != nil {
+		t.Fatalf("StoreMany failed: %v", err)
+	}
+
+	jsonData, after, err := storage.GetJSONWithExpiry(ctx, "renew_key")
+	if err != nil {
+		t.Fatalf("Failed to get renew_key: %v", err)
+	}
+	if string(jsonData) != `{"field":"value"}` {
+		t.Errorf("Expected value to be unchanged, got %s", string(jsonData))
+	}
+	if !after.After(before) {
+		t.Errorf("Expected expiry %v to be extended beyond %v", after, before)
+	}
+	if _, err := storage.GetJSON(ctx, "missing_key"); err == nil {
+		t.Error("Expected missing renew key not to be created")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	defer storage.Close()
+
+	storage.PersistDuration = time.Minute
+	if err := storage.Store(ctx, "touch_key", map[string]interface{}{"field": "value"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+	_, before, _ := storage.GetJSONWithExpiry(ctx, "touch_key")
+
+	storage.PersistDuration = time.Hour
+	expiresAt, err := storage.Touch(ctx, "touch_key")
+	if err != nil {
+		t.Fatalf("Failed to touch entry: %v", err)
+	}
+	if !expiresAt.After(before) {
+		t.Errorf("Expected new expiry %v to be after %v", expiresAt, before)
+	}
+
+	jsonData, stored, err := storage.GetJSONWithExpiry(ctx, "touch_key")
+	if err != nil {
+		t.Fatalf("Failed to get JSON data: %v", err)
+	}
+	if !stored.Equal(expiresAt) {
+		t.Errorf("Expected stored expiry %v, got %v", expiresAt, stored)
+	}
+	if string(jsonData) != `{"field":"value"}` {
+		t.Errorf("Expected value to be unchanged, got %s", string(jsonData))
+	}
+
+	if _, err := storage.Touch(ctx, "missing_key"); err == nil {
+		t.Error("Expected error for missing key")
+	}
+}
+
 func TestStoreJSONEncodingError(t *testing.T) {
 	storage := NewInMemoryStorage()
 	defer storage.Close()
@@ -257,6 +422,37 @@ func TestClose_persistent(t *testing.T) {
 	}
 }
 
+func TestClose_persistsAndIsIdempotent(t *testing.T) {
+	dir, err := os.MkdirTemp("", "close-reopen-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	ctx := context.Background()
+
+	s := NewPersistentStorage(dir, time.Minute)
+	if err := s.Store(ctx, "close_key", map[string]interface{}{"value": "42"}); err != nil {
+		t.Fatalf("Failed to store data: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	// A deferred Close after an explicit one must not fail or panic.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Second Close returned error: %v", err)
+	}
+
+	reopened := NewPersistentStorage(dir, time.Minute)
+	defer reopened.Close()
+	retrieved, err := reopened.Retrieve(ctx, "close_key")
+	if err != nil {
+		t.Fatalf("Failed to retrieve data after reopening: %v", err)
+	}
+	if retrieved["value"] != "42" {
+		t.Errorf("Expected value=42, got %v", retrieved["value"])
+	}
+}
+
 func TestValueLogGC_stopsOnClose(t *testing.T) {
 	dir, err := os.MkdirTemp("", "gc-stop-test-*")
 	if err != nil {